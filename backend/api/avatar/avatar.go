@@ -0,0 +1,119 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // アップロードを受け付ける形式
+	_ "image/jpeg"
+	"image/png"
+	"io"
+)
+
+const (
+	// Size は保存するアバター画像の一辺のピクセル数です。
+	Size = 256
+	// MaxBytes はアップロードを受け付ける画像ファイルの大きさの上限です。
+	MaxBytes = 1 << 20
+	// 展開後のメモリ使用量を抑えるため、縦横それぞれこれより大きい画像は受け付けない
+	maxDimension = 4096
+)
+
+var (
+	ErrTooLarge    = errors.New("avatar image is too large")
+	ErrUnsupported = errors.New("unsupported avatar image")
+)
+
+// Process はアップロードされた画像を検証し、中央を正方形に切り抜いて Size 四方のPNGに変換します。
+// 対応している形式は PNG, JPEG, GIF です。GIF アニメーションは最初のフレームだけを使います。
+func Process(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	// 画素を展開する前に形式と大きさだけを確認する
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if format != "png" && format != "jpeg" && format != "gif" {
+		return nil, ErrUnsupported
+	}
+	if config.Width < 1 || config.Height < 1 {
+		return nil, ErrUnsupported
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resize(cropSquare(src), Size)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cropSquare は画像の中央を短い辺に合わせた正方形に切り抜きます。
+func cropSquare(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), src, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// resize は正方形の画像を size 四方に拡大・縮小します。
+// 縮小するときは対応する範囲の画素の平均を、拡大するときは最も近い画素を使います。
+func resize(src *image.RGBA, size int) *image.RGBA {
+	n := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := span(dy, n, size)
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := span(dx, n, size)
+
+			var r, g, b, a, count uint32
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					b += uint32(p[2])
+					a += uint32(p[3])
+					count++
+				}
+			}
+			i := dy*dst.Stride + dx*4
+			dst.Pix[i] = uint8(r / count)
+			dst.Pix[i+1] = uint8(g / count)
+			dst.Pix[i+2] = uint8(b / count)
+			dst.Pix[i+3] = uint8(a / count)
+		}
+	}
+	return dst
+}
+
+// span は変換後の d 番目の画素に対応する、元画像の画素の範囲 [start, end) を返します。
+func span(d, n, size int) (int, int) {
+	start := d * n / size
+	end := (d + 1) * n / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
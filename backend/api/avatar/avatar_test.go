@@ -0,0 +1,84 @@
+package avatar_test
+
+import (
+	"bytes"
+	"gityard-api/avatar"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("crop center and resize", func(t *testing.T) {
+		// 左右の余白は赤、中央の正方形は青の横長画像
+		src := image.NewRGBA(image.Rect(0, 0, 600, 300))
+		for y := 0; y < 300; y++ {
+			for x := 0; x < 600; x++ {
+				c := color.RGBA{R: 255, A: 255}
+				if x >= 150 && x < 450 {
+					c = color.RGBA{B: 255, A: 255}
+				}
+				src.Set(x, y, c)
+			}
+		}
+
+		out, err := avatar.Process(bytes.NewReader(encodePNG(t, src)))
+		assert.Nil(t, err)
+		img, err := png.Decode(bytes.NewReader(out))
+		assert.Nil(t, err)
+		assert.Equal(t, image.Rect(0, 0, avatar.Size, avatar.Size), img.Bounds())
+		for _, p := range []image.Point{{0, 0}, {avatar.Size - 1, avatar.Size - 1}, {avatar.Size / 2, avatar.Size / 2}} {
+			r, _, b, _ := img.At(p.X, p.Y).RGBA()
+			assert.Equal(t, uint32(0), r)
+			assert.Equal(t, uint32(0xffff), b)
+		}
+	})
+
+	t.Run("enlarge small image", func(t *testing.T) {
+		out, err := avatar.Process(bytes.NewReader(encodePNG(t, image.NewRGBA(image.Rect(0, 0, 3, 5)))))
+		assert.Nil(t, err)
+		config, err := png.DecodeConfig(bytes.NewReader(out))
+		assert.Nil(t, err)
+		assert.Equal(t, avatar.Size, config.Width)
+		assert.Equal(t, avatar.Size, config.Height)
+	})
+
+	t.Run("reject invalid images", func(t *testing.T) {
+		_, err := avatar.Process(bytes.NewReader([]byte("<svg></svg>")))
+		assert.ErrorIs(t, err, avatar.ErrUnsupported)
+
+		_, err = avatar.Process(bytes.NewReader(make([]byte, avatar.MaxBytes+1)))
+		assert.ErrorIs(t, err, avatar.ErrTooLarge)
+
+		// ファイルは小さくても展開すると大きい画像
+		_, err = avatar.Process(bytes.NewReader(encodePNG(t, image.NewGray(image.Rect(0, 0, 5000, 1)))))
+		assert.ErrorIs(t, err, avatar.ErrTooLarge)
+	})
+}
+
+func TestIdenticon(t *testing.T) {
+	a1, err := avatar.Identicon("1")
+	assert.Nil(t, err)
+	a2, err := avatar.Identicon("1")
+	assert.Nil(t, err)
+	b, err := avatar.Identicon("2")
+	assert.Nil(t, err)
+
+	// 同じ seed からは同じ画像、異なる seed からは異なる画像になる
+	assert.Equal(t, a1, a2)
+	assert.NotEqual(t, a1, b)
+
+	config, err := png.DecodeConfig(bytes.NewReader(a1))
+	assert.Nil(t, err)
+	assert.Equal(t, avatar.Size, config.Width)
+	assert.Equal(t, avatar.Size, config.Height)
+}
@@ -0,0 +1,86 @@
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+const (
+	// アイデンティコンは5x5のマスを左右対称に塗る
+	identiconCells = 5
+	identiconCell  = 42
+	identiconInset = (Size - identiconCells*identiconCell) / 2
+)
+
+var identiconBackground = color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+// Identicon は seed から決まるアイデンティコンを Size 四方のPNGで返します。
+// 同じ seed からは常に同じ画像になるので、作り直しても見た目は変わりません。
+func Identicon(seed string) ([]byte, error) {
+	sum := sha256.Sum256([]byte("gityard-identicon:" + seed))
+
+	// 色相は全体から、彩度と明度は背景と見分けがつく範囲から選ぶ
+	hue := float64(uint16(sum[0])<<8|uint16(sum[1])) / 65536 * 360
+	saturation := 0.45 + float64(sum[2])/255*0.25
+	lightness := 0.45 + float64(sum[3])/255*0.15
+	fill := image.NewUniform(hslToRGB(hue, saturation, lightness))
+
+	img := image.NewRGBA(image.Rect(0, 0, Size, Size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(identiconBackground), image.Point{}, draw.Src)
+
+	half := (identiconCells + 1) / 2
+	for y := 0; y < identiconCells; y++ {
+		for x := 0; x < half; x++ {
+			// 4バイト目以降の各バイトの最下位ビットで塗るかを決める
+			if sum[4+y*half+x]&1 == 0 {
+				continue
+			}
+			for _, cx := range []int{x, identiconCells - 1 - x} {
+				cell := image.Rect(0, 0, identiconCell, identiconCell).
+					Add(image.Pt(identiconInset+cx*identiconCell, identiconInset+y*identiconCell))
+				draw.Draw(img, cell, fill, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hslToRGB は色相 h (0-360)、彩度 s と明度 l (0-1) の色を RGB に変換します。
+func hslToRGB(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := h / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g, b = c, x, 0
+	case hp < 2:
+		r, g, b = x, c, 0
+	case hp < 3:
+		r, g, b = 0, c, x
+	case hp < 4:
+		r, g, b = 0, x, c
+	case hp < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	m := l - c/2
+	return color.RGBA{
+		R: uint8((r + m) * 255),
+		G: uint8((g + m) * 255),
+		B: uint8((b + m) * 255),
+		A: 0xff,
+	}
+}
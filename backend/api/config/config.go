@@ -1,23 +1,330 @@
 package config
 
 import (
-	"log/slog"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-// Config func to get env value
-func Config(key string) string {
-	// load .env file
-	err := godotenv.Load(".env")
-	if err != nil {
-		slog.Debug("failed to load .env, load os env directly")
+// Config はAPIサーバの設定です。起動時に Load で一度だけ読み込み、各パッケージの Setup に渡します。
+//
+// 値は 既定値 < 設定ファイル (YAML) < 環境変数 (.env を含む) の順に上書きされます。
+// 環境変数名は各フィールドの env タグ、設定ファイルのキーは yaml タグです。
+type Config struct {
+	ListenAddr       string `yaml:"listen_addr" env:"LISTEN_ADDR"`
+	WebBaseURL       string `yaml:"web_base_url" env:"WEB_BASE_URL"`
+	Secret           string `yaml:"secret" env:"SECRET"`
+	InternalAPIToken string `yaml:"internal_api_token" env:"INTERNAL_API_TOKEN"`
+
+	Database DatabaseConfig `yaml:"database"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Git      GitConfig      `yaml:"git"`
+	Mail     MailConfig     `yaml:"mail"`
+	Token    TokenConfig    `yaml:"token"`
+	Account  AccountConfig  `yaml:"account"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Cookie   CookieConfig   `yaml:"cookie"`
+	WebAuthn WebAuthnConfig `yaml:"webauthn"`
+}
+
+// DatabaseConfig はデータベースの接続先です。
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver" env:"DB_DRIVER"` // mysql か sqlite
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	Path     string `yaml:"path" env:"DB_PATH"` // sqlite のデータベースファイル
+}
+
+// StorageConfig はリポジトリとアバターなどのファイルの置き場です。
+type StorageConfig struct {
+	GitRoot  string `yaml:"git_root" env:"GIT_STORAGE_ROOT"`
+	Blob     string `yaml:"blob" env:"BLOB_STORAGE"` // local
+	BlobRoot string `yaml:"blob_root" env:"BLOB_STORAGE_ROOT"`
+}
+
+// GitConfig はgit over HTTPの設定です。
+type GitConfig struct {
+	// MaxRequestBytes はpushやfetchのリクエストボディの上限のバイト数です。gzipの場合は展開後の大きさで数えます
+	MaxRequestBytes int `yaml:"max_request_bytes" env:"GIT_MAX_REQUEST_BYTES"`
+}
+
+// MailConfig はメールの送信方法です。
+type MailConfig struct {
+	Mailer       string `yaml:"mailer" env:"MAILER"` // smtp か log
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	LogDir       string `yaml:"log_dir" env:"MAIL_LOG_DIR"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     string `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	// PollInterval は送信待ちのメールを探して送信する間隔です
+	PollInterval time.Duration `yaml:"poll_interval" env:"MAIL_POLL_INTERVAL"`
+}
+
+// TokenConfig はトークンやチャレンジの有効期間です。
+type TokenConfig struct {
+	AccessTokenTTL            time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL           time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+	TwoFactorChallengeTTL     time.Duration `yaml:"two_factor_challenge_ttl" env:"TWO_FACTOR_CHALLENGE_TTL"`
+	WebAuthnCeremonyTTL       time.Duration `yaml:"webauthn_ceremony_ttl" env:"WEBAUTHN_CEREMONY_TTL"`
+	EmailVerificationTokenTTL time.Duration `yaml:"email_verification_token_ttl" env:"EMAIL_VERIFICATION_TOKEN_TTL"`
+	PasswordResetTokenTTL     time.Duration `yaml:"password_reset_token_ttl" env:"PASSWORD_RESET_TOKEN_TTL"`
+}
+
+// AccountConfig は退会の設定です。
+type AccountConfig struct {
+	// DeletionGracePeriod は退会を申し込んでから実際に削除するまでの、取り消せる期間です
+	DeletionGracePeriod time.Duration `yaml:"deletion_grace_period" env:"ACCOUNT_DELETION_GRACE_PERIOD"`
+	// DeletionPollInterval は猶予期間が過ぎたユーザを探して退会させる間隔です
+	DeletionPollInterval time.Duration `yaml:"deletion_poll_interval" env:"ACCOUNT_DELETION_POLL_INTERVAL"`
+}
+
+// JWTConfig はアクセストークンの署名鍵です。鍵はPEMのファイルで、Ed25519 か P-256 のECDSAに対応します。
+// SigningKeyFile が空なら SECRET から Ed25519 の鍵を導出します。
+type JWTConfig struct {
+	SigningKeyFile string `yaml:"signing_key_file" env:"JWT_SIGNING_KEY_FILE"`
+	// RetiredKeyFiles は以前の署名鍵です。発行済みのトークンの検証にだけ使います。環境変数ではカンマ区切り
+	RetiredKeyFiles []string `yaml:"retired_key_files" env:"JWT_RETIRED_KEY_FILES"`
+}
+
+// CookieConfig はリフレッシュトークンを入れるクッキーの属性です。
+type CookieConfig struct {
+	// Secure はHTTPSでだけ送るかどうか。HTTPで動かすローカル開発のときだけ false にしてください
+	Secure bool `yaml:"secure" env:"COOKIE_SECURE"`
+}
+
+// WebAuthnConfig はWebAuthnのRelying Partyです。
+type WebAuthnConfig struct {
+	RPID      string   `yaml:"rp_id" env:"WEBAUTHN_RP_ID"`
+	RPOrigins []string `yaml:"rp_origins" env:"WEBAUTHN_RP_ORIGINS"` // 環境変数ではカンマ区切り
+}
+
+// SecretMinLength はトークンの署名鍵を導出する SECRET の最小のバイト数
+const SecretMinLength = 32
+
+// Default は既定値の設定を返します。SECRET と署名鍵には既定値がないので、そのままでは Validate を通りません。
+func Default() Config {
+	return Config{
+		ListenAddr: ":8000",
+		WebBaseURL: "http://localhost:3000",
+		Database: DatabaseConfig{
+			Driver: "mysql",
+			Port:   3306,
+			Path:   "./data/gityard.db",
+		},
+		Storage: StorageConfig{
+			GitRoot:  "./data/repositories",
+			Blob:     "local",
+			BlobRoot: "./data/blobs",
+		},
+		Git: GitConfig{
+			MaxRequestBytes: 1 << 30, // 1GiB
+		},
+		Mail: MailConfig{
+			Mailer:       "log",
+			From:         "gityard <noreply@localhost>",
+			PollInterval: 5 * time.Second,
+		},
+		Token: TokenConfig{
+			AccessTokenTTL:            15 * time.Minute,
+			RefreshTokenTTL:           7 * 24 * time.Hour,
+			TwoFactorChallengeTTL:     5 * time.Minute,
+			WebAuthnCeremonyTTL:       5 * time.Minute,
+			EmailVerificationTokenTTL: 24 * time.Hour,
+			PasswordResetTokenTTL:     time.Hour,
+		},
+		Account: AccountConfig{
+			DeletionGracePeriod:  14 * 24 * time.Hour,
+			DeletionPollInterval: 10 * time.Minute,
+		},
+		Cookie: CookieConfig{
+			Secure: true,
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:      "localhost",
+			RPOrigins: []string{"http://localhost:8000"},
+		},
+	}
+}
+
+// Load は既定値に path の設定ファイルと環境変数を重ねて読み込み、検証します。path が空なら設定ファイルは読みません。
+// .env があれば環境変数として読み込みますが、すでに設定されている環境変数は上書きしません。
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(body))
+		decoder.KnownFields(true) // 綴りを間違えたキーを黙って無視しない
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env: %w", err)
 	}
-	return os.Getenv(key)
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyEnv は env タグの付いたフィールドに、設定されている環境変数の値を入れます。
+func applyEnv(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := v.Type().Field(i).Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		// 空の値は未設定として扱い、既定値や設定ファイルの値を残す
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(value)
+		case int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer: %q", key, value)
+			}
+			field.SetInt(int64(n))
+		case bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false: %q", key, value)
+			}
+			field.SetBool(b)
+		case time.Duration:
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a duration like 15m or 168h: %q", key, value)
+			}
+			field.SetInt(int64(d))
+		case []string:
+			var values []string
+			for _, s := range strings.Split(value, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					values = append(values, s)
+				}
+			}
+			field.Set(reflect.ValueOf(values))
+		default:
+			return fmt.Errorf("unsupported config type for %s", key)
+		}
+	}
+	return nil
+}
+
+// Validate は設定の値を確認し、問題をまとめて返します。
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.ListenAddr != "", "LISTEN_ADDR is required")
+	check(isAbsoluteURL(c.WebBaseURL), "WEB_BASE_URL must be an absolute url: %q", c.WebBaseURL)
+	if c.JWT.SigningKeyFile == "" {
+		check(len(c.Secret) >= SecretMinLength, "SECRET must be at least %d bytes unless JWT_SIGNING_KEY_FILE is set", SecretMinLength)
+	}
+
+	switch c.Database.Driver {
+	case "mysql":
+		check(c.Database.Host != "", "DB_HOST is required for mysql")
+		check(c.Database.User != "", "DB_USER is required for mysql")
+		check(c.Database.Name != "", "DB_NAME is required for mysql")
+		check(0 < c.Database.Port && c.Database.Port <= 65535, "DB_PORT must be between 1 and 65535: %d", c.Database.Port)
+	case "sqlite":
+		check(c.Database.Path != "", "DB_PATH is required for sqlite")
+	default:
+		check(false, "DB_DRIVER must be mysql or sqlite: %q", c.Database.Driver)
+	}
+
+	check(c.Storage.GitRoot != "", "GIT_STORAGE_ROOT is required")
+	check(c.Storage.Blob == "local", "BLOB_STORAGE must be local: %q", c.Storage.Blob)
+	check(c.Storage.BlobRoot != "", "BLOB_STORAGE_ROOT is required")
+
+	check(c.Git.MaxRequestBytes > 0, "GIT_MAX_REQUEST_BYTES must be positive: %d", c.Git.MaxRequestBytes)
+
+	switch c.Mail.Mailer {
+	case "smtp":
+		check(c.Mail.SMTPHost != "", "SMTP_HOST is required for smtp")
+		check(c.Mail.SMTPPort != "", "SMTP_PORT is required for smtp")
+	case "log":
+	default:
+		check(false, "MAILER must be smtp or log: %q", c.Mail.Mailer)
+	}
+	check(c.Mail.From != "", "MAIL_FROM is required")
+
+	for _, ttl := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ACCESS_TOKEN_TTL", c.Token.AccessTokenTTL},
+		{"REFRESH_TOKEN_TTL", c.Token.RefreshTokenTTL},
+		{"TWO_FACTOR_CHALLENGE_TTL", c.Token.TwoFactorChallengeTTL},
+		{"WEBAUTHN_CEREMONY_TTL", c.Token.WebAuthnCeremonyTTL},
+		{"EMAIL_VERIFICATION_TOKEN_TTL", c.Token.EmailVerificationTokenTTL},
+		{"PASSWORD_RESET_TOKEN_TTL", c.Token.PasswordResetTokenTTL},
+		{"MAIL_POLL_INTERVAL", c.Mail.PollInterval},
+		{"ACCOUNT_DELETION_GRACE_PERIOD", c.Account.DeletionGracePeriod},
+		{"ACCOUNT_DELETION_POLL_INTERVAL", c.Account.DeletionPollInterval},
+	} {
+		check(ttl.value > 0, "%s must be positive: %s", ttl.name, ttl.value)
+	}
+	check(c.Token.AccessTokenTTL < c.Token.RefreshTokenTTL, "ACCESS_TOKEN_TTL must be shorter than REFRESH_TOKEN_TTL")
+
+	check(c.WebAuthn.RPID != "", "WEBAUTHN_RP_ID is required")
+	check(len(c.WebAuthn.RPOrigins) > 0, "WEBAUTHN_RP_ORIGINS is required")
+	for _, origin := range c.WebAuthn.RPOrigins {
+		check(isAbsoluteURL(origin), "WEBAUTHN_RP_ORIGINS must be absolute urls: %q", origin)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
 
 const (
-	AccessTokenActiveDurationMinutes  = 15          // 15mins
-	RefreshTokenActiveDurationMinutes = 60 * 24 * 7 // 7days
+	HandlenameRedirectActiveDurationDays = 90 // 90days
 )
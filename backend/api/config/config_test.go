@@ -0,0 +1,109 @@
+package config_test
+
+import (
+	"gityard-api/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// loadIn は .env を読まないよう空のディレクトリで Load します。
+func loadIn(t *testing.T, path string) (*config.Config, error) {
+	t.Chdir(t.TempDir())
+	return config.Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("env overrides file and defaults", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gityard.yaml")
+		assert.Nil(t, os.WriteFile(path, []byte(`
+listen_addr: ":9000"
+database:
+  driver: sqlite
+token:
+  access_token_ttl: 5m
+  refresh_token_ttl: 24h
+`), 0o600))
+		t.Setenv("SECRET", testSecret)
+		t.Setenv("LISTEN_ADDR", ":9100")
+		t.Setenv("COOKIE_SECURE", "false")
+		t.Setenv("WEBAUTHN_RP_ORIGINS", "https://a.example.com, https://b.example.com")
+
+		cfg, err := loadIn(t, path)
+		assert.Nil(t, err)
+		assert.Equal(t, ":9100", cfg.ListenAddr)
+		assert.Equal(t, "sqlite", cfg.Database.Driver)
+		assert.Equal(t, "./data/gityard.db", cfg.Database.Path)
+		assert.Equal(t, 5*time.Minute, cfg.Token.AccessTokenTTL)
+		assert.Equal(t, 24*time.Hour, cfg.Token.RefreshTokenTTL)
+		assert.Equal(t, time.Hour, cfg.Token.PasswordResetTokenTTL)
+		assert.False(t, cfg.Cookie.Secure)
+		assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.WebAuthn.RPOrigins)
+	})
+
+	t.Run("unknown keys in file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gityard.yaml")
+		assert.Nil(t, os.WriteFile(path, []byte("listen_adr: \":9000\"\n"), 0o600))
+		t.Setenv("SECRET", testSecret)
+		t.Setenv("DB_DRIVER", "sqlite")
+
+		_, err := loadIn(t, path)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("invalid env", func(t *testing.T) {
+		t.Setenv("SECRET", testSecret)
+		t.Setenv("DB_DRIVER", "sqlite")
+		t.Setenv("ACCESS_TOKEN_TTL", "15")
+
+		_, err := loadIn(t, "")
+		assert.ErrorContains(t, err, "ACCESS_TOKEN_TTL")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() config.Config {
+		cfg := config.Default()
+		cfg.Secret = testSecret
+		cfg.Database.Driver = "sqlite"
+		return cfg
+	}
+	cfg := valid()
+	assert.Nil(t, cfg.Validate())
+
+	// 署名鍵のファイルがあれば SECRET はいらない
+	cfg.Secret = ""
+	cfg.JWT.SigningKeyFile = "jwt.pem"
+	assert.Nil(t, cfg.Validate())
+
+	for name, tc := range map[string]struct {
+		modify  func(cfg *config.Config)
+		message string
+	}{
+		"short secret":       {func(cfg *config.Config) { cfg.Secret = "short" }, "SECRET"},
+		"no signing key":     {func(cfg *config.Config) { cfg.Secret = "" }, "JWT_SIGNING_KEY_FILE"},
+		"mysql needs host":   {func(cfg *config.Config) { cfg.Database.Driver = "mysql" }, "DB_HOST"},
+		"unknown driver":     {func(cfg *config.Config) { cfg.Database.Driver = "postgres" }, "DB_DRIVER"},
+		"smtp needs host":    {func(cfg *config.Config) { cfg.Mail.Mailer = "smtp" }, "SMTP_HOST"},
+		"zero ttl":           {func(cfg *config.Config) { cfg.Token.TwoFactorChallengeTTL = 0 }, "TWO_FACTOR_CHALLENGE_TTL"},
+		"access outlives":    {func(cfg *config.Config) { cfg.Token.AccessTokenTTL = 30 * 24 * time.Hour }, "ACCESS_TOKEN_TTL"},
+		"relative origin":    {func(cfg *config.Config) { cfg.WebAuthn.RPOrigins = []string{"localhost"} }, "WEBAUTHN_RP_ORIGINS"},
+		"relative base url":  {func(cfg *config.Config) { cfg.WebBaseURL = "/web" }, "WEB_BASE_URL"},
+		"empty listen addr":  {func(cfg *config.Config) { cfg.ListenAddr = "" }, "LISTEN_ADDR"},
+		"empty storage root": {func(cfg *config.Config) { cfg.Storage.GitRoot = "" }, "GIT_STORAGE_ROOT"},
+		"zero git limit":     {func(cfg *config.Config) { cfg.Git.MaxRequestBytes = 0 }, "GIT_MAX_REQUEST_BYTES"},
+		"zero mail interval": {func(cfg *config.Config) { cfg.Mail.PollInterval = 0 }, "MAIL_POLL_INTERVAL"},
+		"zero grace period":  {func(cfg *config.Config) { cfg.Account.DeletionGracePeriod = 0 }, "ACCOUNT_DELETION_GRACE_PERIOD"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := valid()
+			tc.modify(&cfg)
+			assert.ErrorContains(t, cfg.Validate(), tc.message)
+		})
+	}
+}
@@ -1,47 +1,87 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"gityard-api/config"
 	"log/slog"
-	"strconv"
+	"net/url"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
-func ConnectDB() {
+// Connect は cfg.Driver で選んだデータベースに接続します。
+// mysql は Host などの接続情報を、sqlite は Path のファイルを使います。
+func Connect(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	slog.Info("try connect to database")
 
+	var db *gorm.DB
 	var err error
-	p := config.Config("DB_PORT")
-	port, err := strconv.ParseUint(p, 10, 32)
+	switch cfg.Driver {
+	case "mysql":
+		db, err = openMySQL(cfg)
+	case "sqlite":
+		db, err = OpenSQLite(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", cfg.Driver)
+	}
 	if err != nil {
-		slog.Error("failed to parse database port", "detail", err)
-		panic("failed to parse database port")
+		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
+	slog.Info("connection opened to database", "driver", db.Dialector.Name())
+	return db, nil
+}
+
+func openMySQL(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	utc, err := time.LoadLocation("UTC")
 	if err != nil {
-		slog.Error("failed to load utc tz", "detail", err)
-		panic("failed to load utc tz")
+		return nil, fmt.Errorf("failed to load utc tz: %w", err)
 	}
 
 	dsn := fmt.Sprintf(
 		"%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=%s",
-		config.Config("DB_USER"),
-		config.Config("DB_PASSWORD"),
-		config.Config("DB_HOST"),
-		port,
-		config.Config("DB_NAME"),
+		cfg.User,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.Name,
 		utc,
 	)
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		slog.Error("failed to connect database", "detail", err)
-		panic("failed to connect database")
+	return gorm.Open(mysql.Open(dsn), &gorm.Config{
+		TranslateError: true, // 一意制約違反を gorm.ErrDuplicatedKey として扱うため
+	})
+}
+
+// OpenSQLite は path のSQLiteデータベースを開きます。ファイルがなければ作成します。
+// 1台で動かす小さな環境とテスト向けです。
+func OpenSQLite(path string) (*gorm.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
 	}
 
-	slog.Info("connection opened to database")
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	// 読み取りから書き込みに移るときのロックの取り合いで失敗しないよう、トランザクションの開始時に書き込みのロックを取る
+	query.Set("_txlock", "immediate")
+
+	sqlDB, err := sql.Open(sqlite.DriverName, path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(&sqlite.Dialector{Conn: &utcConnPool{db: sqlDB}}, &gorm.Config{
+		TranslateError: true, // 一意制約違反を gorm.ErrDuplicatedKey として扱うため
+	})
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
 }
@@ -0,0 +1,30 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSQLiteStoresTimesInUTC(t *testing.T) {
+	db := openTestDB(t)
+	assert.Nil(t, db.Exec("create table events (id integer primary key, at datetime)").Error)
+
+	jst := time.FixedZone("JST", 9*60*60)
+	at := time.Date(2026, 1, 2, 9, 0, 0, 0, jst)
+	assert.Nil(t, db.Exec("insert into events (id, at) values (1, ?)", at).Error)
+	assert.Nil(t, db.Transaction(func(tx *gorm.DB) error {
+		return tx.Exec("insert into events (id, at) values (2, ?)", &at).Error
+	}))
+
+	var stored []string
+	assert.Nil(t, db.Raw("select at || '' from events order by id").Scan(&stored).Error)
+	assert.Equal(t, []string{"2026-01-02 00:00:00+00:00", "2026-01-02 00:00:00+00:00"}, stored)
+
+	// オフセットの違う時刻で比べても、同じ時刻として扱われる
+	var count int64
+	assert.Nil(t, db.Raw("select count(*) from events where at < ?", at.Add(time.Second).In(time.FixedZone("EST", -5*60*60))).Scan(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
@@ -1,6 +0,0 @@
-package database
-
-import "gorm.io/gorm"
-
-// DB gorm connector
-var DB *gorm.DB
@@ -0,0 +1,312 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// マイグレーションはデータベースの種類ごとのディレクトリに、バージョンと名前を付けたSQLファイルとして置く。
+//
+//	migrations/<dialect>/<version>_<name>.up.sql    適用するときに実行する
+//	migrations/<dialect>/<version>_<name>.down.sql  取り消すときに実行する
+//
+// バージョンは適用する順に1から振り、一度リリースしたファイルは書き換えずに新しいバージョンを追加する。
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration はスキーマの1回分の変更です。
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus はマイグレーションと、適用済みならその日時の組です。
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration は適用済みのマイグレーションを記録する行です。
+type schemaMigration struct {
+	Version   uint      `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// LoadMigrations は fsys の migrations/<dialect> からマイグレーションをバージョン順に読み込みます。
+func LoadMigrations(fsys fs.FS, dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		versionText, migrationName, ok := strings.Cut(strings.TrimSuffix(name, "."+direction+".sql"), "_")
+		version, err := strconv.ParseUint(versionText, 10, 32)
+		if !ok || err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[uint(version)]
+		if !exists {
+			m = &Migration{Version: uint(version), Name: migrationName}
+			byVersion[uint(version)] = m
+		}
+		if m.Name != migrationName {
+			return nil, fmt.Errorf("migration version %d has different names: %s, %s", version, m.Name, migrationName)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != uint(i+1) {
+			return nil, fmt.Errorf("migration version %d is missing", i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrator はデータベースにマイグレーションを適用します。
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator は埋め込まれたマイグレーションのうち、db の種類に合うものを使う Migrator を返します。
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationFiles, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// prepare は記録用のテーブルを用意し、適用済みのバージョンを返します。
+func (m *Migrator) prepare() (map[uint]schemaMigration, error) {
+	// マイグレーションを導入する前に create-table.sql で作ったデータベースは、初期スキーマ (00001) が適用済みとして記録する。
+	// 記録用のテーブルを作ると区別できなくなるので、その前に確認する
+	adopt := !m.db.Migrator().HasTable(&schemaMigration{}) && m.db.Migrator().HasTable("users")
+	if err := m.db.Exec("create table if not exists schema_migrations (" +
+		"version bigint unsigned not null primary key, " +
+		"name varchar(255) not null, " +
+		"applied_at datetime not null)").Error; err != nil {
+		return nil, err
+	}
+	if adopt {
+		initial := m.migrations[0]
+		if err := m.db.Create(&schemaMigration{Version: initial.Version, Name: initial.Name, AppliedAt: time.Now()}).Error; err != nil {
+			return nil, err
+		}
+		slog.Info("existing schema recorded as migration", "version", initial.Version, "name", initial.Name)
+	}
+
+	var rows []schemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := map[uint]schemaMigration{}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	for version := range applied {
+		if version > uint(len(m.migrations)) {
+			return nil, fmt.Errorf("database has migration %d which this binary does not know", version)
+		}
+	}
+	return applied, nil
+}
+
+// Status はすべてのマイグレーションと適用状況を返します。
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending はまだ適用していないマイグレーションを返します。
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up はまだ適用していないマイグレーションをすべて順に適用し、適用したものを返します。
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		err := m.run(migration.Up, func(tx *gorm.DB) error {
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		slog.Info("migration applied", "version", migration.Version, "name", migration.Name)
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down は適用済みのマイグレーションを新しいものから steps 個取り消し、取り消したものを返します。
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.run(migration.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		slog.Info("migration reverted", "version", migration.Version, "name", migration.Name)
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// run はSQLの文を順に実行してから record で記録を更新します。
+// MySQLではDDLが暗黙にコミットされるため、途中で失敗すると実行済みの文は戻りません。
+func (m *Migrator) run(sql string, record func(tx *gorm.DB) error) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(sql) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
+
+// splitStatements はSQLを文ごとに分けます。文字列の中の ; と -- から行末までのコメントは区切りとして扱いません。
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+			continue
+		case c == ';':
+			if s := strings.TrimSpace(current.String()); s != "" {
+				statements = append(statements, s)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	if s := strings.TrimSpace(current.String()); s != "" {
+		statements = append(statements, s)
+	}
+	return statements
+}
+
+// ErrPendingMigrations は適用していないマイグレーションがあることを表します。
+var ErrPendingMigrations = errors.New("database schema is out of date, run `migrate up`")
+
+// EnsureMigrated はすべてのマイグレーションが適用済みであることを確認します。
+func EnsureMigrated(db *gorm.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending", ErrPendingMigrations, len(pending))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package database_test
+
+import (
+	"gityard-api/database"
+	"gityard-api/model"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// モデルとマイグレーションで作ったスキーマがずれていないかを確認する対象
+var models = []schema.Tabler{
+	&model.User{}, &model.UserCredential{}, &model.UserVerificationToken{}, &model.UserRefreshToken{},
+	&model.UserRotatedRefreshToken{}, &model.UserAccessToken{}, &model.UserTwoFactor{}, &model.UserRecoveryCode{},
+	&model.UserTwoFactorChallenge{}, &model.UserWebAuthnCredential{}, &model.WebAuthnCeremony{}, &model.UserPublicKey{},
+	&model.Handlename{}, &model.Account{}, &model.AccountProfile{}, &model.OrganizationMember{}, &model.Organization{},
+	&model.Team{}, &model.TeamMember{}, &model.Repository{}, &model.RepositoryCollaborator{}, &model.RepositoryInvitation{},
+	&model.TeamRepository{}, &model.MailOutbox{}, &model.RevokedAccessToken{},
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "gityard.db"))
+	assert.Nil(t, err)
+	return db
+}
+
+func TestMigrations(t *testing.T) {
+	db := openTestDB(t)
+	migrator, err := database.NewMigrator(db)
+	assert.Nil(t, err)
+
+	t.Run("apply all and match models", func(t *testing.T) {
+		applied, err := migrator.Up()
+		assert.Nil(t, err)
+		assert.NotEmpty(t, applied)
+
+		for _, m := range models {
+			assert.True(t, db.Migrator().HasTable(m), m.TableName())
+			s, err := schema.Parse(m, &sync.Map{}, db.NamingStrategy)
+			assert.Nil(t, err)
+			for _, field := range s.Fields {
+				if field.DBName == "" {
+					continue
+				}
+				assert.True(t, db.Migrator().HasColumn(m, field.DBName), m.TableName()+"."+field.DBName)
+			}
+			for _, index := range s.ParseIndexes() {
+				assert.True(t, db.Migrator().HasIndex(m, index.Name), m.TableName()+" "+index.Name)
+			}
+		}
+
+		pending, err := migrator.Pending()
+		assert.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("revert all and apply again", func(t *testing.T) {
+		statuses, err := migrator.Status()
+		assert.Nil(t, err)
+
+		reverted, err := migrator.Down(len(statuses))
+		assert.Nil(t, err)
+		assert.Len(t, reverted, len(statuses))
+		assert.False(t, db.Migrator().HasTable("users"))
+
+		applied, err := migrator.Up()
+		assert.Nil(t, err)
+		assert.Len(t, applied, len(statuses))
+	})
+
+	t.Run("dialects have the same versions", func(t *testing.T) {
+		fsys := os.DirFS(".")
+		mysql, err := database.LoadMigrations(fsys, "mysql")
+		assert.Nil(t, err)
+		sqlite, err := database.LoadMigrations(fsys, "sqlite")
+		assert.Nil(t, err)
+
+		assert.Equal(t, len(mysql), len(sqlite))
+		for i := range min(len(mysql), len(sqlite)) {
+			assert.Equal(t, mysql[i].Name, sqlite[i].Name)
+		}
+	})
+}
+
+func TestAdoptUnmanagedSchema(t *testing.T) {
+	// マイグレーションを導入する前の create-table.sql で作ったデータベース
+	db := openTestDB(t)
+	migrations, err := database.LoadMigrations(os.DirFS("."), "sqlite")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Exec(migrations[0].Up).Error)
+	assert.Nil(t, db.Exec("insert into users (email) values ('alice@example.com')").Error)
+
+	migrator, err := database.NewMigrator(db)
+	assert.Nil(t, err)
+	applied, err := migrator.Up()
+	assert.Nil(t, err)
+	assert.Len(t, applied, len(migrations)-1)
+	assert.Equal(t, uint(2), applied[0].Version)
+
+	// 初期スキーマは適用済みとして記録し、データはそのまま残る
+	statuses, err := migrator.Status()
+	assert.Nil(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	var count int64
+	assert.Nil(t, db.Table("users").Where("email = ?", "alice@example.com").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	for _, m := range models {
+		assert.True(t, db.Migrator().HasTable(m), m.TableName())
+	}
+	assert.Nil(t, database.EnsureMigrated(db))
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("needs both directions", func(t *testing.T) {
+		_, err := database.LoadMigrations(fstest.MapFS{
+			"migrations/x/00001_init.up.sql": {Data: []byte("create table a (id int);")},
+		}, "x")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("versions must be contiguous", func(t *testing.T) {
+		_, err := database.LoadMigrations(fstest.MapFS{
+			"migrations/x/00001_init.up.sql":   {Data: []byte("create table a (id int);")},
+			"migrations/x/00001_init.down.sql": {Data: []byte("drop table a;")},
+			"migrations/x/00003_b.up.sql":      {Data: []byte("create table b (id int);")},
+			"migrations/x/00003_b.down.sql":    {Data: []byte("drop table b;")},
+		}, "x")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,9 @@
+-- 参照している側のテーブルから消すため、作成と逆の順に削除する
+drop table repositories;
+drop table account_profiles;
+drop table accounts;
+drop table handlenames;
+drop table user_publickeys;
+drop table user_refresh_tokens;
+drop table user_credentials;
+drop table users;
@@ -1,4 +1,4 @@
--- using mysql
+-- 初期スキーマ。以前 database/init/create-table.sql としてMySQLの初回起動時に実行していたもの
 
 create table users (
     id bigint unsigned not null auto_increment,
@@ -94,4 +94,4 @@ create table repositories (
     primary key(id),
     foreign key(owner_account_id) references accounts(id) on delete restrict,
     unique index uq_idx_repositories_owner_account_id_and_name (owner_account_id, name) -- disallow same name per account
-);
\ No newline at end of file
+);
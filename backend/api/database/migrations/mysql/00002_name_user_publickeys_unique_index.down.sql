@@ -0,0 +1 @@
+alter table user_publickeys rename index uq_idx_user_publickeys_user_id_fingerprint to user_id;
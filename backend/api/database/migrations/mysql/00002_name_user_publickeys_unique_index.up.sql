@@ -0,0 +1,2 @@
+-- 00001 では名前を付けていなかったので、MySQLが最初の列名から付けた名前になっている
+alter table user_publickeys rename index user_id to uq_idx_user_publickeys_user_id_fingerprint;
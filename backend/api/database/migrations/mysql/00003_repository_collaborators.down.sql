@@ -0,0 +1,2 @@
+drop table repository_invitations;
+drop table repository_collaborators;
@@ -0,0 +1,2 @@
+drop table organization_members;
+alter table accounts modify user_id bigint unsigned not null;
@@ -0,0 +1,13 @@
+alter table accounts modify user_id bigint unsigned; -- 組織アカウントは特定のユーザに属さないためnull
+create table organization_members (
+    organization_account_id bigint unsigned not null,
+    user_id bigint unsigned not null,
+    role smallint not null default 1, -- 1=メンバー, 2=オーナー
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp on update current_timestamp,
+
+    primary key(organization_account_id, user_id),
+    index idx_organization_members_user_id (user_id), -- for user's organizations
+    foreign key(organization_account_id) references accounts(id) on delete cascade,
+    foreign key(user_id) references users(id) on delete cascade
+);
@@ -0,0 +1,4 @@
+drop table team_repositories;
+drop table team_members;
+drop table teams;
+drop table organizations;
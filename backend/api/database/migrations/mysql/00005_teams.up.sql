@@ -0,0 +1,46 @@
+create table organizations (
+    account_id bigint unsigned not null,
+    base_role smallint not null default 1, -- メンバー全員が持つ役割。0=なし, 以降は repository_collaborators.role と同じ
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp on update current_timestamp,
+
+    primary key(account_id),
+    foreign key(account_id) references accounts(id) on delete cascade
+);
+create table teams (
+    id bigint unsigned not null auto_increment,
+    organization_account_id bigint unsigned not null,
+    parent_team_id bigint unsigned, -- 親チームがなければnull
+    name varchar(100) not null,
+    description varchar(255) not null default '',
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp on update current_timestamp,
+
+    primary key(id),
+    index idx_teams_parent_team_id (parent_team_id),
+    foreign key(organization_account_id) references accounts(id) on delete cascade,
+    foreign key(parent_team_id) references teams(id) on delete set null,
+    unique index uq_idx_teams_organization_account_id_and_name (organization_account_id, name) -- disallow same name per organization
+);
+create table team_members (
+    team_id bigint unsigned not null,
+    user_id bigint unsigned not null,
+    created_at datetime default current_timestamp,
+
+    primary key(team_id, user_id),
+    index idx_team_members_user_id (user_id), -- for user's teams
+    foreign key(team_id) references teams(id) on delete cascade,
+    foreign key(user_id) references users(id) on delete cascade
+);
+create table team_repositories (
+    team_id bigint unsigned not null,
+    repository_id bigint unsigned not null,
+    role smallint not null, -- repository_collaborators.role と同じ
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp on update current_timestamp,
+
+    primary key(team_id, repository_id),
+    index idx_team_repositories_repository_id (repository_id),
+    foreign key(team_id) references teams(id) on delete cascade,
+    foreign key(repository_id) references repositories(id) on delete cascade
+);
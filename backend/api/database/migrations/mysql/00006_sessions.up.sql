@@ -0,0 +1,18 @@
+-- 主キーが変わるので作り直す。それまでのログインは破棄するので、利用者はログインし直す
+drop table user_refresh_tokens;
+create table user_refresh_tokens ( -- 1行が1セッション
+    id bigint unsigned not null auto_increment,
+    user_id bigint unsigned not null,
+    hashed_refresh_token varchar(255) not null,
+    user_agent varchar(255) not null default '',
+    ip_address varchar(45) not null default '', -- IPv6まで入る長さ
+    expires_at datetime not null, -- 定期的にDBスキャンして期限切れを削除するため
+    last_used_at datetime default current_timestamp,
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp on update current_timestamp,
+
+    primary key(id),
+    index idx_user_refresh_tokens_user_id (user_id), -- for user's sessions
+    unique index uq_idx_users_hashed_refresh_token (hashed_refresh_token),
+    foreign key(user_id) references users(id) on delete cascade -- ユーザ削除時に一緒に消す
+);
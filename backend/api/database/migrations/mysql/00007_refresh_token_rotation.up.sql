@@ -0,0 +1,10 @@
+create table user_rotated_refresh_tokens ( -- ローテーション済みのトークン。再利用の検知に使う
+    hashed_refresh_token varchar(255) not null,
+    refresh_token_id bigint unsigned not null, -- 同じセッション(ファミリー)の user_refresh_tokens.id
+    expires_at datetime not null,
+    created_at datetime default current_timestamp,
+
+    primary key(hashed_refresh_token),
+    index idx_user_rotated_refresh_tokens_refresh_token_id (refresh_token_id),
+    foreign key(refresh_token_id) references user_refresh_tokens(id) on delete cascade -- セッション削除時に一緒に消す
+);
@@ -0,0 +1,15 @@
+create table user_access_tokens ( -- パーソナルアクセストークン
+    id bigint unsigned not null auto_increment,
+    user_id bigint unsigned not null,
+    name varchar(255) not null,
+    hashed_token varchar(255) not null,
+    scopes varchar(255) not null, -- 空白区切り。例: "repo:read keys:admin"
+    expires_at datetime, -- 無期限ならnull
+    last_used_at datetime,
+    created_at datetime default current_timestamp,
+
+    primary key(id),
+    index idx_user_access_tokens_user_id (user_id),
+    unique index uq_idx_user_access_tokens_hashed_token (hashed_token),
+    foreign key(user_id) references users(id) on delete cascade
+);
@@ -0,0 +1,3 @@
+drop table user_two_factor_challenges;
+drop table user_recovery_codes;
+drop table user_two_factors;
@@ -0,0 +1,36 @@
+create table user_two_factors ( -- TOTPによる二要素認証
+    user_id bigint unsigned not null,
+    secret varchar(64) not null, -- Base32。コードの検証に使うため平文
+    is_enabled tinyint(1) not null default 0, -- 0=登録中、1=有効
+    last_used_step bigint not null default 0, -- 最後に受け付けたコードのステップ。同じコードの再利用を防ぐ
+    enabled_at datetime,
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp on update current_timestamp,
+
+    primary key(user_id),
+    foreign key(user_id) references users(id) on delete cascade
+);
+create table user_recovery_codes ( -- 1回だけ使えるリカバリーコード
+    id bigint unsigned not null auto_increment,
+    user_id bigint unsigned not null,
+    hashed_code varchar(255) not null,
+    used_at datetime, -- 未使用ならnull
+    created_at datetime default current_timestamp,
+
+    primary key(id),
+    index idx_user_recovery_codes_user_id (user_id),
+    foreign key(user_id) references users(id) on delete cascade
+);
+create table user_two_factor_challenges ( -- パスワード確認済みで二要素認証のコードを待っているログイン
+    id bigint unsigned not null auto_increment,
+    user_id bigint unsigned not null,
+    hashed_token varchar(255) not null,
+    failed_attempts int not null default 0, -- 上限に達したらチャレンジを破棄する
+    expires_at datetime not null,
+    created_at datetime default current_timestamp,
+
+    primary key(id),
+    index idx_user_two_factor_challenges_user_id (user_id),
+    unique index uq_idx_user_two_factor_challenges_hashed_token (hashed_token),
+    foreign key(user_id) references users(id) on delete cascade
+);
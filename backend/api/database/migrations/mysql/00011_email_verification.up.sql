@@ -0,0 +1,15 @@
+alter table users add column email_verified_at datetime after is_deleted; -- 確認メールのリンクを開くまではnull
+create table user_verification_tokens ( -- メールで送ったリンクに載せる一度きりのトークン
+    id bigint unsigned not null auto_increment,
+    user_id bigint unsigned not null,
+    kind int not null, -- 1=メールアドレスの確認、2=パスワードの再設定
+    hashed_token varchar(255) not null,
+    email varchar(255) not null, -- 送り先。メールアドレスが変わったら使えない
+    expires_at datetime not null,
+    created_at datetime default current_timestamp,
+
+    primary key(id),
+    index idx_user_verification_tokens_user_id (user_id),
+    unique index uq_idx_user_verification_tokens_hashed_token (hashed_token),
+    foreign key(user_id) references users(id) on delete cascade
+);
@@ -0,0 +1,3 @@
+alter table handlenames add column redirect_account_id bigint unsigned after handlename; -- 改名前のハンドルネームなら改名したアカウント。使用中ならnull
+alter table handlenames add column redirect_expires_at datetime after redirect_account_id; -- 転送をやめて解放する日時
+create index idx_handlenames_redirect_account_id on handlenames (redirect_account_id); -- for account's old handlenames
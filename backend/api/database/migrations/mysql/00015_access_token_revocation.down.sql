@@ -0,0 +1,2 @@
+drop table revoked_access_tokens;
+alter table users drop column token_generation;
@@ -0,0 +1,11 @@
+alter table users add column token_generation int unsigned not null default 0 after deletion_scheduled_at; -- 増やすとそれまでに発行したアクセストークンがすべて無効になる
+create table revoked_access_tokens ( -- ログアウトで失効させたアクセストークン。期限を過ぎたら消してよい
+    jti varchar(64) not null,
+    user_id bigint unsigned not null,
+    expires_at datetime not null,
+    created_at datetime default current_timestamp,
+
+    primary key(jti),
+    index idx_revoked_access_tokens_expires_at (expires_at), -- 期限切れの削除のため
+    foreign key(user_id) references users(id) on delete cascade
+);
@@ -0,0 +1 @@
+-- SQLite では 00001 で名前を付けて作っているので何もしない。バージョンをMySQLとそろえるためのファイル
@@ -0,0 +1,28 @@
+create table repository_collaborators (
+    repository_id bigint unsigned not null,
+    user_id bigint unsigned not null,
+    role smallint not null, -- 1=read, 2=triage, 3=write, 4=maintain, 5=admin
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp,
+
+    primary key(repository_id, user_id),
+    foreign key(repository_id) references repositories(id) on delete cascade,
+    foreign key(user_id) references users(id) on delete cascade
+);
+create index idx_repository_collaborators_user_id on repository_collaborators (user_id); -- for user's accessible repositories
+create table repository_invitations (
+    id integer primary key autoincrement,
+    repository_id bigint unsigned not null,
+    invitee_user_id bigint unsigned not null,
+    inviter_user_id bigint unsigned not null,
+    role smallint not null, -- repository_collaborators.role と同じ
+    status smallint not null default 1, -- 1=保留中, 2=承認, 3=辞退
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp,
+
+    foreign key(repository_id) references repositories(id) on delete cascade,
+    foreign key(invitee_user_id) references users(id) on delete cascade,
+    foreign key(inviter_user_id) references users(id) on delete cascade
+);
+create index idx_repository_invitations_repository_id on repository_invitations (repository_id);
+create index idx_repository_invitations_invitee_user_id on repository_invitations (invitee_user_id);
@@ -0,0 +1,18 @@
+-- accounts を作り直すので、データがあると account_profiles の行が消えるか、repositories の参照で失敗する
+drop table organization_members;
+create table accounts_new (
+    id integer primary key autoincrement,
+    user_id bigint unsigned not null,
+    handlename_id bigint unsigned,
+    kind smallint not null default 1, -- 1=個人, 2=組織
+    is_deleted tinyint(1) not null default 0, -- 0=有効、1=退会済み
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp,
+
+    foreign key(user_id) references users(id) on delete restrict,
+    foreign key(handlename_id) references handlenames(id) on delete restrict
+);
+insert into accounts_new select id, user_id, handlename_id, kind, is_deleted, created_at, updated_at from accounts;
+drop table accounts;
+alter table accounts_new rename to accounts;
+create unique index uq_idx_accounts_handlename_id on accounts (handlename_id);
@@ -0,0 +1,29 @@
+-- SQLiteは列のnot nullを変えられないので作り直す。SQLiteのデータベースは最初から migrate up で作るので、ここではまだ空
+create table accounts_new (
+    id integer primary key autoincrement,
+    user_id bigint unsigned, -- 組織アカウントは特定のユーザに属さないためnull
+    handlename_id bigint unsigned,
+    kind smallint not null default 1, -- 1=個人, 2=組織
+    is_deleted tinyint(1) not null default 0, -- 0=有効、1=退会済み
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp,
+
+    foreign key(user_id) references users(id) on delete restrict,
+    foreign key(handlename_id) references handlenames(id) on delete restrict
+);
+insert into accounts_new select id, user_id, handlename_id, kind, is_deleted, created_at, updated_at from accounts;
+drop table accounts;
+alter table accounts_new rename to accounts;
+create unique index uq_idx_accounts_handlename_id on accounts (handlename_id);
+create table organization_members (
+    organization_account_id bigint unsigned not null,
+    user_id bigint unsigned not null,
+    role smallint not null default 1, -- 1=メンバー, 2=オーナー
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp,
+
+    primary key(organization_account_id, user_id),
+    foreign key(organization_account_id) references accounts(id) on delete cascade,
+    foreign key(user_id) references users(id) on delete cascade
+);
+create index idx_organization_members_user_id on organization_members (user_id); -- for user's organizations
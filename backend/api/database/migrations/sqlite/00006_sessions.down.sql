@@ -0,0 +1,12 @@
+drop table user_refresh_tokens;
+create table user_refresh_tokens (
+    user_id bigint unsigned not null,
+    hashed_refresh_token varchar(255) not null,
+    expires_at datetime not null, -- 定期的にDBスキャンして期限切れを削除するため
+    created_at datetime default current_timestamp,
+    updated_at datetime default current_timestamp,
+
+    primary key(user_id),
+    foreign key(user_id) references users(id) on delete cascade -- ユーザ削除時に一緒に消す
+);
+create unique index uq_idx_users_hashed_refresh_token on user_refresh_tokens (hashed_refresh_token);
@@ -0,0 +1 @@
+drop table user_rotated_refresh_tokens;
@@ -0,0 +1,14 @@
+create table user_access_tokens ( -- パーソナルアクセストークン
+    id integer primary key autoincrement,
+    user_id bigint unsigned not null,
+    name varchar(255) not null,
+    hashed_token varchar(255) not null,
+    scopes varchar(255) not null, -- 空白区切り。例: "repo:read keys:admin"
+    expires_at datetime, -- 無期限ならnull
+    last_used_at datetime,
+    created_at datetime default current_timestamp,
+
+    foreign key(user_id) references users(id) on delete cascade
+);
+create index idx_user_access_tokens_user_id on user_access_tokens (user_id);
+create unique index uq_idx_user_access_tokens_hashed_token on user_access_tokens (hashed_token);
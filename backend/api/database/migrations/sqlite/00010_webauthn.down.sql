@@ -0,0 +1,3 @@
+drop table webauthn_ceremonies;
+drop table user_webauthn_credentials;
+alter table user_two_factor_challenges drop column webauthn_session_data;
@@ -0,0 +1,32 @@
+alter table user_two_factor_challenges add column webauthn_session_data text; -- WebAuthnで応答する場合のセレモニーのセッション
+create table user_webauthn_credentials ( -- セキュリティキーやパスキー
+    id integer primary key autoincrement,
+    user_id bigint unsigned not null,
+    name varchar(255) not null,
+    credential_id varchar(255) not null, -- base64url
+    public_key blob not null, -- COSE形式
+    attestation_type varchar(32) not null default '',
+    transports varchar(255) not null default '', -- 空白区切り。例: "usb nfc"
+    aaguid varbinary(16),
+    flags tinyint unsigned not null default 0, -- 登録時の認証器データのフラグ
+    sign_count int unsigned not null default 0, -- 署名カウンタ。巻き戻ったらクローンを疑う
+    clone_warning tinyint(1) not null default 0,
+    last_used_at datetime,
+    created_at datetime default current_timestamp,
+
+    foreign key(user_id) references users(id) on delete cascade
+);
+create index idx_user_webauthn_credentials_user_id on user_webauthn_credentials (user_id);
+create unique index uq_idx_user_webauthn_credentials_credential_id on user_webauthn_credentials (credential_id);
+create table webauthn_ceremonies ( -- 開始してから検証されるまでのWebAuthnのセレモニー
+    id integer primary key autoincrement,
+    user_id bigint unsigned, -- パスワードレスのログインではまだ分からないのでnull
+    hashed_token varchar(255) not null,
+    kind int not null, -- 1=登録、2=パスワードレスのログイン
+    session_data text not null,
+    expires_at datetime not null,
+    created_at datetime default current_timestamp,
+
+    foreign key(user_id) references users(id) on delete cascade
+);
+create unique index uq_idx_webauthn_ceremonies_hashed_token on webauthn_ceremonies (hashed_token);
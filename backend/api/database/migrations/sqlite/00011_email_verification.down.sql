@@ -0,0 +1,2 @@
+drop table user_verification_tokens;
+alter table users drop column email_verified_at;
@@ -0,0 +1,2 @@
+drop table mail_outbox;
+alter table users drop column locale;
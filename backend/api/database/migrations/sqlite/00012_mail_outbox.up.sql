@@ -0,0 +1,14 @@
+alter table users add column locale varchar(8) not null default 'en'; -- メールの言語。en, ja
+create table mail_outbox ( -- 送信待ちのメール。業務の変更と同じトランザクションで書き込み、ワーカーが送信する
+    id integer primary key autoincrement,
+    to_address varchar(255) not null,
+    subject varchar(1000) not null,
+    text_body text not null,
+    html_body text not null,
+    attempts int not null default 0, -- 送信を試みた回数
+    next_attempt_at datetime not null, -- この時刻を過ぎたら送信する。失敗したら間隔を空けて再送する
+    last_error varchar(1000) not null default '',
+    failed_at datetime, -- 再送の上限に達して諦めた時刻
+    created_at datetime default current_timestamp
+);
+create index idx_mail_outbox_next_attempt_at on mail_outbox (next_attempt_at);
@@ -0,0 +1,2 @@
+drop index idx_users_deletion_scheduled_at;
+alter table users drop column deletion_scheduled_at;
@@ -0,0 +1,2 @@
+alter table users add column deletion_scheduled_at datetime; -- 退会の予定日時。猶予期間中なら取り消せる
+create index idx_users_deletion_scheduled_at on users (deletion_scheduled_at); -- for deletion worker
@@ -0,0 +1,3 @@
+drop index idx_handlenames_redirect_account_id;
+alter table handlenames drop column redirect_expires_at;
+alter table handlenames drop column redirect_account_id;
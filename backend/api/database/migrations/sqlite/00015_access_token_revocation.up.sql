@@ -0,0 +1,11 @@
+alter table users add column token_generation integer not null default 0; -- 増やすとそれまでに発行したアクセストークンがすべて無効になる
+create table revoked_access_tokens ( -- ログアウトで失効させたアクセストークン。期限を過ぎたら消してよい
+    jti varchar(64) not null,
+    user_id bigint unsigned not null,
+    expires_at datetime not null,
+    created_at datetime default current_timestamp,
+
+    primary key(jti),
+    foreign key(user_id) references users(id) on delete cascade
+);
+create index idx_revoked_access_tokens_expires_at on revoked_access_tokens (expires_at); -- 期限切れの削除のため
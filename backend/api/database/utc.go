@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// utcConnPool はクエリに渡す時刻をUTCにそろえる gorm.ConnPool です。
+// SQLiteは時刻をオフセット付きの文字列で保存して文字列のまま比較するので、オフセットが混ざると大小が狂います。
+// MySQLに loc=UTC で接続しているのと同じ扱いにするためのもので、プロセスの time.Local は変えません。
+type utcConnPool struct {
+	db *sql.DB
+}
+
+func (p *utcConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, query)
+}
+
+func (p *utcConnPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return p.db.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return p.db.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return p.db.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &utcTx{tx: tx}, nil
+}
+
+// GetDBConn は gorm.DB.DB() で *sql.DB を取り出せるようにします。
+func (p *utcConnPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+func (p *utcConnPool) Ping() error {
+	return p.db.Ping()
+}
+
+// utcTx は utcConnPool で始めたトランザクションです。
+type utcTx struct {
+	tx *sql.Tx
+}
+
+func (t *utcTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.tx.PrepareContext(ctx, query)
+}
+
+func (t *utcTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (t *utcTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (t *utcTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+func (t *utcTx) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	return t.tx.StmtContext(ctx, stmt)
+}
+
+func (t *utcTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *utcTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// utcArgs は時刻の引数をUTCにしたものを返します。gorm.DeletedAt のような driver.Valuer も値が時刻ならUTCにします。
+func utcArgs(args []any) []any {
+	var converted []any
+	for i, arg := range args {
+		value, ok := utcValue(arg)
+		if !ok {
+			continue
+		}
+		if converted == nil {
+			converted = append([]any(nil), args...)
+		}
+		converted[i] = value
+	}
+	if converted == nil {
+		return args
+	}
+	return converted
+}
+
+func utcValue(arg any) (any, bool) {
+	switch v := arg.(type) {
+	case time.Time:
+		return v.UTC(), true
+	case *time.Time:
+		if v == nil {
+			return nil, false
+		}
+		return v.UTC(), true
+	case driver.Valuer:
+		value, err := v.Value()
+		if err != nil {
+			return nil, false // 実行したときに同じエラーになる
+		}
+		if t, ok := value.(time.Time); ok {
+			return t.UTC(), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,79 @@
+package gitcmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	UploadPack  = "git-upload-pack"
+	ReceivePack = "git-receive-pack"
+)
+
+// IsSupportedService はSmart HTTPで提供するサービス名かを返します。
+func IsSupportedService(service string) bool {
+	return service == UploadPack || service == ReceivePack
+}
+
+// IsProtocolV2 は Git-Protocol ヘッダーでプロトコルv2が要求されているかを返します。
+func IsProtocolV2(gitProtocol string) bool {
+	for _, p := range strings.Split(gitProtocol, ":") {
+		if p == "version=2" {
+			return true
+		}
+	}
+	return false
+}
+
+func command(ctx context.Context, service, gitProtocol string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", append([]string{strings.TrimPrefix(service, "git-")}, args...)...)
+	cmd.Env = os.Environ()
+	if gitProtocol != "" {
+		cmd.Env = append(cmd.Env, "GIT_PROTOCOL="+gitProtocol)
+	}
+	return cmd
+}
+
+// AdvertiseRefs は info/refs のレスポンスを書き込みます。
+func AdvertiseRefs(ctx context.Context, repoPath, service, gitProtocol string, w io.Writer) error {
+	// v2 ではサービス名の行を付けない (git http-backend と同じ振る舞い)
+	if !IsProtocolV2(gitProtocol) {
+		if _, err := io.WriteString(w, PktLine(fmt.Sprintf("# service=%s\n", service))+FlushPkt); err != nil {
+			return err
+		}
+	}
+
+	cmd := command(ctx, service, gitProtocol, "--stateless-rpc", "--advertise-refs", repoPath)
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s --advertise-refs failed: %w: %s", service, err, stderr.String())
+	}
+	return nil
+}
+
+// ServiceRPC はクライアントのリクエストを git-upload-pack / git-receive-pack に渡してレスポンスを書き込みます。
+func ServiceRPC(ctx context.Context, repoPath, service, gitProtocol string, r io.Reader, w io.Writer) error {
+	cmd := command(ctx, service, gitProtocol, "--stateless-rpc", repoPath)
+	var stderr bytes.Buffer
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s --stateless-rpc failed: %w: %s", service, err, stderr.String())
+	}
+	return nil
+}
+
+// PktLine は文字列をpkt-line形式に変換します。
+func PktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+const FlushPkt = "0000"
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/service"
+	"log/slog"
+	"time"
+)
+
+type accountDeletionResponse struct {
+	Scheduled bool       `json:"scheduled"`
+	DeleteAt  *time.Time `json:"delete_at"` // この日時を過ぎると退会する。取り消せるのはそれまで
+}
+
+// GetAccountDeletion handler for GET /settings/account/deletion
+func (h *Handler) GetAccountDeletion(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	deleteAt, err := h.Accounts.GetAccountDeletion(userId)
+	if err != nil {
+		slog.Error("failed to get account deletion", "detail", err)
+		return InternalError(c)
+	}
+
+	return c.JSON(accountDeletionResponse{
+		Scheduled: deleteAt != nil,
+		DeleteAt:  deleteAt,
+	})
+}
+
+// ScheduleAccountDeletion handler for POST /settings/account/deletion
+func (h *Handler) ScheduleAccountDeletion(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+	sessionId, ok := c.Locals("session_id").(uint)
+	if !ok {
+		slog.Error("session_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Password string `json:"password" validate:"required"`
+		Code     string `json:"code" validate:"max=32"` // 二要素認証を有効にしている場合だけ必要。TOTPかリカバリーコード
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	deleteAt, err := h.Accounts.ScheduleAccountDeletion(userId, sessionId, req.Password, req.Code)
+	if err != nil {
+		var passwordMissMatchErr *service.ErrPasswordMissMatch
+		if errors.As(err, &passwordMissMatchErr) {
+			slog.Warn("account deletion rejected", "reason", "password miss match", "userId", userId)
+			return c.Status(422).JSON(fiber.Map{"message": "invalid password"})
+		}
+
+		var invalidCodeErr *service.ErrInvalidTwoFactorCode
+		if errors.As(err, &invalidCodeErr) {
+			slog.Warn("account deletion rejected", "reason", "invalid two-factor code", "userId", userId)
+			return c.Status(422).JSON(fiber.Map{"message": "invalid code"})
+		}
+
+		var alreadyScheduledErr *service.ErrAccountDeletionAlreadyScheduled
+		if errors.As(err, &alreadyScheduledErr) {
+			slog.Info("account deletion rejected", "reason", "already scheduled", "userId", userId)
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "account deletion already scheduled"})
+		}
+
+		var lastOwnerErr *service.ErrLastOrganizationOwner
+		if errors.As(err, &lastOwnerErr) {
+			slog.Info("account deletion rejected", "reason", "last organization owner", "userId", userId)
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "organization needs at least one owner"})
+		}
+
+		slog.Error("failed to schedule account deletion", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("account deletion scheduled", "userId", userId, "deleteAt", deleteAt)
+	return c.Status(fiber.StatusAccepted).JSON(accountDeletionResponse{
+		Scheduled: true,
+		DeleteAt:  &deleteAt,
+	})
+}
+
+// CancelAccountDeletion handler for DELETE /settings/account/deletion
+func (h *Handler) CancelAccountDeletion(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Accounts.CancelAccountDeletion(userId)
+	if err != nil {
+		var notScheduledErr *service.ErrAccountDeletionNotScheduled
+		if errors.As(err, &notScheduledErr) {
+			slog.Info("cancel account deletion rejected", "reason", "not scheduled", "userId", userId)
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "account deletion not scheduled"})
+		}
+
+		slog.Error("failed to cancel account deletion", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("account deletion canceled", "userId", userId)
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// RenameHandlename handler for POST /settings/account/handlename
+func (h *Handler) RenameHandlename(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Handlename string `json:"handlename" validate:"required,alphanum"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	account, err := h.Accounts.RenameHandlename(userId, req.Handlename)
+	if err != nil {
+		var registeredHandleNameErr *service.ErrRegisteredHandleName
+		if errors.As(err, &registeredHandleNameErr) {
+			slog.Info("rename handlename rejected", "reason", "registered handlename")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "registered handlename"})
+		}
+
+		slog.Error("failed to rename handlename", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("handlename renamed successfully", "userId", userId, "handlename", account.Handlename.Handlename)
+	type Response struct {
+		Handlename string `json:"handlename"`
+	}
+	return c.JSON(Response{Handlename: account.Handlename.Handlename})
+}
@@ -3,25 +3,44 @@ package handler
 import (
 	"errors"
 	"github.com/gofiber/fiber/v2"
-	"gityard-api/model"
+	"gityard-api/mail"
 	"gityard-api/security"
 	"gityard-api/service"
 	"log/slog"
+	"strings"
 	"time"
 )
 
-func setTokensAndRespond(c *fiber.Ctx, userId uint, refreshToken *model.RefreshToken) error {
+// sessionClient はセッションに記録する端末の情報をリクエストから取り出します。
+func sessionClient(c *fiber.Ctx) service.SessionClient {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 255 {
+		// 途中で切れた文字は捨てる。不正なUTF-8は utf8mb4 の列に保存できない
+		userAgent = strings.ToValidUTF8(userAgent[:255], "")
+	}
+	return service.SessionClient{
+		UserAgent: userAgent,
+		IPAddress: c.IP(),
+	}
+}
+
+func (h *Handler) setTokensAndRespond(c *fiber.Ctx, session *service.Session) error {
 	c.Cookie(&fiber.Cookie{
 		Name:     "refresh_token",
-		Value:    refreshToken.Body,
-		MaxAge:   int(refreshToken.ExpiresIn.Seconds()),
-		Secure:   false, // 本番環境ではtrueにすべき
+		Value:    session.RefreshToken.Body,
+		MaxAge:   int(session.RefreshToken.ExpiresIn.Seconds()),
+		Secure:   h.Cookie.Secure,
 		HTTPOnly: true,
 		SameSite: "strict",
 		Path:     "/api/v1/auth/refresh",
 	})
 
-	accessToken, err := security.GenerateAccessToken(userId)
+	return respondAccessToken(c, session.UserId, session.SessionId, session.TokenGeneration)
+}
+
+// respondAccessToken はセッションのアクセストークンを発行して返します。
+func respondAccessToken(c *fiber.Ctx, userId, sessionId, generation uint) error {
+	accessToken, err := security.GenerateAccessToken(userId, sessionId, generation)
 	if err != nil {
 		slog.Error("failed to generate access token", "detail", err)
 		return InternalError(c)
@@ -41,7 +60,7 @@ func setTokensAndRespond(c *fiber.Ctx, userId uint, refreshToken *model.RefreshT
 }
 
 // SignUp handler for /signup
-func SignUp(c *fiber.Ctx) error {
+func (h *Handler) SignUp(c *fiber.Ctx) error {
 	type Request struct {
 		Email      string `json:"email" validate:"required,email"`
 		Password   string `json:"password" validate:"required,min=8"`
@@ -60,7 +79,9 @@ func SignUp(c *fiber.Ctx) error {
 		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
 	}
 
-	user, refreshToken, err := service.SignUp(req.Email, req.Password, req.HandleName)
+	// メールはブラウザの言語で送る
+	locale := mail.MatchLocale(c.Get(fiber.HeaderAcceptLanguage))
+	session, err := h.Auth.SignUp(req.Email, req.Password, req.HandleName, locale, sessionClient(c))
 	if err != nil {
 		var registeredEmailErr *service.ErrRegisteredEmail
 		if errors.As(err, &registeredEmailErr) {
@@ -78,12 +99,12 @@ func SignUp(c *fiber.Ctx) error {
 		return InternalError(c)
 	}
 
-	slog.Info("user signed up successfully", "userId", user.ID, "handleName", req.HandleName)
-	return setTokensAndRespond(c, user.ID, refreshToken)
+	slog.Info("user signed up successfully", "userId", session.UserId, "handleName", req.HandleName)
+	return h.setTokensAndRespond(c, session)
 }
 
 // Login handler for /login
-func Login(c *fiber.Ctx) error {
+func (h *Handler) Login(c *fiber.Ctx) error {
 	type Request struct {
 		Email    string `json:"email" validate:"required,email"`
 		Password string `json:"password" validate:"required,min=8"`
@@ -100,7 +121,7 @@ func Login(c *fiber.Ctx) error {
 		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
 	}
 
-	user, refreshToken, err := service.Login(req.Email, req.Password)
+	session, challenge, err := h.Auth.Login(req.Email, req.Password, sessionClient(c))
 	if err != nil {
 		var userNotFoundErr *service.ErrUserNotFound
 		if errors.As(err, &userNotFoundErr) {
@@ -125,8 +146,64 @@ func Login(c *fiber.Ctx) error {
 		return InternalError(c)
 	}
 
-	slog.Info("user logged in successfully", "userId", user.ID)
-	return setTokensAndRespond(c, user.ID, refreshToken)
+	if challenge != nil {
+		slog.Info("user login requires two-factor authentication", "email", req.Email)
+		type Response struct {
+			TwoFactorRequired bool     `json:"two_factor_required"`
+			TwoFactorMethods  []string `json:"two_factor_methods"` // 例: ["totp", "webauthn", "recovery_code"]
+			ChallengeToken    string   `json:"challenge_token"`
+			ExpiresIn         int64    `json:"expires_in"`
+		}
+		return c.JSON(Response{
+			TwoFactorRequired: true,
+			TwoFactorMethods:  challenge.Methods,
+			ChallengeToken:    challenge.Token,
+			ExpiresIn:         int64(challenge.ExpiresIn.Seconds()),
+		})
+	}
+
+	slog.Info("user logged in successfully", "userId", session.UserId, "sessionId", session.SessionId)
+	return h.setTokensAndRespond(c, session)
+}
+
+// LoginWithTwoFactor handler for /login/2fa
+func (h *Handler) LoginWithTwoFactor(c *fiber.Ctx) error {
+	type Request struct {
+		ChallengeToken string `json:"challenge_token" validate:"required"`
+		Code           string `json:"code" validate:"required,max=32"` // TOTPかリカバリーコード
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	session, err := h.Auth.LoginWithTwoFactor(req.ChallengeToken, req.Code, sessionClient(c))
+	if err != nil {
+		var invalidChallengeErr *service.ErrInvalidTwoFactorChallengeProvided
+		if errors.As(err, &invalidChallengeErr) {
+			slog.Warn("user login rejected", "reason", "invalid two-factor challenge")
+			return UnauthorizedError(c)
+		}
+
+		var invalidCodeErr *service.ErrInvalidTwoFactorCode
+		if errors.As(err, &invalidCodeErr) {
+			slog.Warn("user login rejected", "reason", "invalid two-factor code", "userId", invalidCodeErr.UserId)
+			return UnauthorizedError(c)
+		}
+
+		slog.Error("user failed to login", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("user logged in successfully", "userId", session.UserId, "sessionId", session.SessionId)
+	return h.setTokensAndRespond(c, session)
 }
 
 // ref: https://github.com/gofiber/fiber/issues/1127
@@ -141,36 +218,59 @@ func clearCookies(c *fiber.Ctx, key ...string) {
 }
 
 // Logout handler for /logout
-func Logout(c *fiber.Ctx) error {
+func (h *Handler) Logout(c *fiber.Ctx) error {
 	userId, ok := c.Locals("user_id").(uint)
 	if !ok {
 		slog.Error("user_id not found in locals or is not uint")
 		return InternalError(c)
 	}
 
-	err := service.Logout(userId)
+	sessionId, ok := c.Locals("session_id").(uint)
+	if !ok {
+		slog.Error("session_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	tokenId, ok := c.Locals("token_id").(string)
+	if !ok {
+		slog.Error("token_id not found in locals or is not string")
+		return InternalError(c)
+	}
+
+	expiresAt, ok := c.Locals("token_expires_at").(time.Time)
+	if !ok {
+		slog.Error("token_expires_at not found in locals or is not time.Time")
+		return InternalError(c)
+	}
+
+	err := h.Auth.Logout(userId, sessionId, tokenId, expiresAt)
 	if err != nil {
 		slog.Error("failed to logout", "detail", err)
 		return InternalError(c)
 	}
 
-	slog.Info("user logged out successfully", "userId", userId)
+	slog.Info("user logged out successfully", "userId", userId, "sessionId", sessionId)
 	return c.Status(200).JSON(fiber.Map{})
 }
 
-func Refresh(c *fiber.Ctx) error {
+func (h *Handler) Refresh(c *fiber.Ctx) error {
 	refreshToken := c.Cookies("refresh_token", "")
 	if refreshToken == "" {
 		return UnauthorizedError(c)
 	}
 
-	userId, newRefreshToken, err := service.Refresh(refreshToken)
+	session, err := h.Auth.Refresh(refreshToken, sessionClient(c))
 	if err != nil {
 		var invalidErr *service.ErrInvalidRefreshTokenProvided
 		if errors.As(err, &invalidErr) {
 			slog.Warn("invalid refresh_token provided")
 			return UnauthorizedError(c)
 		}
+		var reusedErr *service.ErrRefreshTokenReused
+		if errors.As(err, &reusedErr) {
+			slog.Warn("reused refresh_token provided", "sessionId", reusedErr.SessionId)
+			return UnauthorizedError(c)
+		}
 		var expiredErr *service.ErrExpiredRefreshTokenProvided
 		if errors.As(err, &expiredErr) {
 			slog.Warn("expired refresh_token provided")
@@ -181,6 +281,6 @@ func Refresh(c *fiber.Ctx) error {
 		return InternalError(c)
 	}
 
-	slog.Info("token refreshed successfully", "userId", *userId)
-	return setTokensAndRespond(c, *userId, newRefreshToken)
+	slog.Info("token refreshed successfully", "userId", session.UserId, "sessionId", session.SessionId)
+	return h.setTokensAndRespond(c, session)
 }
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionClientTruncatesUserAgent(t *testing.T) {
+	var client string
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		client = sessionClient(c).UserAgent
+		return nil
+	})
+
+	// 3バイトの文字が255バイト目をまたぐ
+	userAgent := "a" + strings.Repeat("あ", 100)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderUserAgent, userAgent)
+	_, err := app.Test(req)
+	assert.Nil(t, err)
+
+	assert.True(t, utf8.ValidString(client))
+	assert.Equal(t, "a"+strings.Repeat("あ", 84), client)
+}
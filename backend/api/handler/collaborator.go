@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/model"
+	"log/slog"
+	"time"
+)
+
+type invitationResponse struct {
+	ID        uint      `json:"id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newInvitationResponse(invitation *model.RepositoryInvitation) invitationResponse {
+	return invitationResponse{
+		ID:        invitation.ID,
+		Role:      model.RepositoryRole(invitation.Role).String(),
+		CreatedAt: invitation.CreatedAt,
+	}
+}
+
+// GetRepositoryCollaborators handler for GET /repos/:owner/:name/collaborators
+func (h *Handler) GetRepositoryCollaborators(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	collaborators, err := h.Repos.GetRepositoryCollaborators(userId, c.Params("owner"), c.Params("name"), 0, 100)
+	if err != nil {
+		return repositoryError(c, "get collaborators", err)
+	}
+
+	type Item struct {
+		Handlename string `json:"handlename"`
+		Role       string `json:"role"`
+	}
+	type Response struct {
+		Collaborators []Item `json:"collaborators"`
+	}
+	res := Response{
+		Collaborators: []Item{},
+	}
+	for _, collaborator := range collaborators {
+		res.Collaborators = append(res.Collaborators, Item{
+			Handlename: collaborator.Handlename,
+			Role:       collaborator.Role.String(),
+		})
+	}
+	return c.JSON(res)
+}
+
+// InviteRepositoryCollaborator handler for POST /repos/:owner/:name/collaborators
+func (h *Handler) InviteRepositoryCollaborator(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Handlename string `json:"handlename" validate:"required,alphanum"`
+		Role       string `json:"role" validate:"required,oneof=read triage write maintain admin"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	role, _ := model.ParseRepositoryRole(req.Role)
+
+	invitation, err := h.Repos.InviteRepositoryCollaborator(userId, c.Params("owner"), c.Params("name"), req.Handlename, role)
+	if err != nil {
+		return repositoryError(c, "invite collaborator", err)
+	}
+
+	slog.Info("collaborator invited successfully", "userId", userId, "invitationId", invitation.ID)
+	return c.Status(fiber.StatusCreated).JSON(newInvitationResponse(invitation))
+}
+
+// UpdateRepositoryCollaborator handler for PATCH /repos/:owner/:name/collaborators/:handlename
+func (h *Handler) UpdateRepositoryCollaborator(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Role string `json:"role" validate:"required,oneof=read triage write maintain admin"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	role, _ := model.ParseRepositoryRole(req.Role)
+
+	err = h.Repos.UpdateRepositoryCollaborator(userId, c.Params("owner"), c.Params("name"), c.Params("handlename"), role)
+	if err != nil {
+		return repositoryError(c, "update collaborator", err)
+	}
+
+	slog.Info("collaborator updated successfully", "userId", userId, "collaborator", c.Params("handlename"), "role", req.Role)
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// RemoveRepositoryCollaborator handler for DELETE /repos/:owner/:name/collaborators/:handlename
+func (h *Handler) RemoveRepositoryCollaborator(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Repos.RemoveRepositoryCollaborator(userId, c.Params("owner"), c.Params("name"), c.Params("handlename"))
+	if err != nil {
+		return repositoryError(c, "remove collaborator", err)
+	}
+
+	slog.Info("collaborator removed successfully", "userId", userId, "collaborator", c.Params("handlename"))
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// GetRepositoryInvitations handler for GET /repos/:owner/:name/invitations
+func (h *Handler) GetRepositoryInvitations(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	invitations, err := h.Repos.GetRepositoryInvitations(userId, c.Params("owner"), c.Params("name"), 0, 100)
+	if err != nil {
+		return repositoryError(c, "get invitations", err)
+	}
+
+	type Response struct {
+		Invitations []invitationResponse `json:"invitations"`
+	}
+	res := Response{
+		Invitations: []invitationResponse{},
+	}
+	for i := range invitations {
+		res.Invitations = append(res.Invitations, newInvitationResponse(&invitations[i]))
+	}
+	return c.JSON(res)
+}
+
+// CancelRepositoryInvitation handler for DELETE /repos/:owner/:name/invitations/:id
+func (h *Handler) CancelRepositoryInvitation(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	invitationId, err := c.ParamsInt("id")
+	if err != nil || invitationId <= 0 {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	err = h.Repos.CancelRepositoryInvitation(userId, c.Params("owner"), c.Params("name"), uint(invitationId))
+	if err != nil {
+		return repositoryError(c, "cancel invitation", err)
+	}
+
+	slog.Info("invitation canceled successfully", "userId", userId, "invitationId", invitationId)
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// GetReceivedRepositoryInvitations handler for GET /settings/invitations
+func (h *Handler) GetReceivedRepositoryInvitations(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	invitations, err := h.Repos.GetReceivedRepositoryInvitations(userId, 0, 100)
+	if err != nil {
+		slog.Error("failed to get received invitations", "detail", err)
+		return InternalError(c)
+	}
+
+	type Item struct {
+		invitationResponse
+		Repository repositoryResponse `json:"repository"`
+	}
+	type Response struct {
+		Invitations []Item `json:"invitations"`
+	}
+	res := Response{
+		Invitations: []Item{},
+	}
+	for i := range invitations {
+		res.Invitations = append(res.Invitations, Item{
+			invitationResponse: newInvitationResponse(&invitations[i]),
+			Repository:         newRepositoryResponse(&invitations[i].Repository),
+		})
+	}
+	return c.JSON(res)
+}
+
+// AcceptRepositoryInvitation handler for POST /settings/invitations/:id/accept
+func (h *Handler) AcceptRepositoryInvitation(c *fiber.Ctx) error {
+	return h.answerRepositoryInvitation(c, true)
+}
+
+// DeclineRepositoryInvitation handler for POST /settings/invitations/:id/decline
+func (h *Handler) DeclineRepositoryInvitation(c *fiber.Ctx) error {
+	return h.answerRepositoryInvitation(c, false)
+}
+
+func (h *Handler) answerRepositoryInvitation(c *fiber.Ctx, accept bool) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	invitationId, err := c.ParamsInt("id")
+	if err != nil || invitationId <= 0 {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	if accept {
+		err = h.Repos.AcceptRepositoryInvitation(userId, uint(invitationId))
+	} else {
+		err = h.Repos.DeclineRepositoryInvitation(userId, uint(invitationId))
+	}
+	if err != nil {
+		return repositoryError(c, "answer invitation", err)
+	}
+
+	slog.Info("invitation answered successfully", "userId", userId, "invitationId", invitationId, "accepted", accept)
+	return c.Status(200).JSON(fiber.Map{})
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/service"
+	"log/slog"
+	"time"
+)
+
+// GetEmail handler for GET /settings/email
+func (h *Handler) GetEmail(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	user, err := h.Auth.GetEmail(userId)
+	if err != nil {
+		slog.Error("failed to get email", "detail", err)
+		return InternalError(c)
+	}
+
+	type Response struct {
+		Email      string     `json:"email"`
+		Verified   bool       `json:"verified"`
+		VerifiedAt *time.Time `json:"verified_at"`
+	}
+	return c.JSON(Response{
+		Email:      *user.Email,
+		Verified:   user.EmailVerifiedAt != nil,
+		VerifiedAt: user.EmailVerifiedAt,
+	})
+}
+
+// ResendEmailVerification handler for POST /settings/email/verification
+func (h *Handler) ResendEmailVerification(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Auth.ResendEmailVerification(userId)
+	if err != nil {
+		var alreadyVerifiedErr *service.ErrEmailAlreadyVerified
+		if errors.As(err, &alreadyVerifiedErr) {
+			slog.Info("resend email verification rejected", "reason", "email already verified")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "email already verified"})
+		}
+
+		slog.Error("failed to resend email verification", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("email verification resent", "userId", userId)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{})
+}
+
+// VerifyEmail handler for /email/verify
+func (h *Handler) VerifyEmail(c *fiber.Ctx) error {
+	type Request struct {
+		Token string `json:"token" validate:"required"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	err = h.Auth.VerifyEmail(req.Token)
+	if err != nil {
+		var invalidTokenErr *service.ErrInvalidVerificationTokenProvided
+		if errors.As(err, &invalidTokenErr) {
+			slog.Warn("verify email rejected", "reason", "invalid token")
+			return c.Status(422).JSON(fiber.Map{"message": "invalid token"})
+		}
+
+		slog.Error("failed to verify email", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("email verified successfully")
+	return c.Status(200).JSON(fiber.Map{})
+}
@@ -17,3 +17,11 @@ func BadRequestError(c *fiber.Ctx) error {
 func ConflictError(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "conflict"})
 }
+
+func ForbiddenError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+}
+
+func NotFoundError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "not found"})
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/gitcmd"
+	"gityard-api/model"
+	"gityard-api/security"
+	"gityard-api/service"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// gitCredentials は Authorization: Basic ヘッダーを取り出します。ヘッダーがなければ ok=false です。
+func gitCredentials(c *fiber.Ctx) (username, password string, ok bool) {
+	authHeader := c.Get("Authorization")
+	encoded, found := strings.CutPrefix(authHeader, "Basic ")
+	if !found {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// gitAuthRequired はgitクライアントに認証情報の入力を促します。
+func gitAuthRequired(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="gityard"`)
+	return c.Status(fiber.StatusUnauthorized).SendString("authentication required\n")
+}
+
+// gitRepository はBasic認証を検証してgitの操作対象のリポジトリを返します。
+// レスポンスを返した場合は repo が nil になります。
+func (h *Handler) gitRepository(c *fiber.Ctx, write bool) (*model.Repository, error) {
+	var userId *uint
+	if c.Get("Authorization") != "" {
+		username, password, ok := gitCredentials(c)
+		if !ok {
+			return nil, gitAuthRequired(c)
+		}
+		id, scopes, err := h.Repos.AuthenticateGitUser(username, password)
+		if err != nil {
+			var userNotFoundErr *service.ErrUserNotFound
+			var passwordMissMatchErr *service.ErrPasswordMissMatch
+			var invalidTokenErr *service.ErrInvalidAccessTokenProvided
+			var expiredTokenErr *service.ErrExpiredAccessTokenProvided
+			var revokedTokenErr *service.ErrRevokedAccessTokenProvided
+			if errors.As(err, &userNotFoundErr) || errors.As(err, &passwordMissMatchErr) ||
+				errors.As(err, &invalidTokenErr) || errors.As(err, &expiredTokenErr) || errors.As(err, &revokedTokenErr) {
+				slog.Warn("git http auth rejected", "reason", "invalid credentials", "username", username)
+				return nil, gitAuthRequired(c)
+			}
+			var passwordDisabledErr *service.ErrPasswordAuthenticationDisabled
+			if errors.As(err, &passwordDisabledErr) {
+				slog.Warn("git http auth rejected", "reason", "password authentication disabled", "userId", passwordDisabledErr.UserId)
+				return nil, c.Status(fiber.StatusUnauthorized).
+					SendString("password authentication is not available with two-factor authentication enabled; use a personal access token\n")
+			}
+			slog.Error("failed to authenticate git user", "detail", err)
+			return nil, InternalError(c)
+		}
+
+		requiredScope := security.ScopeRepoRead
+		if write {
+			requiredScope = security.ScopeRepoWrite
+		}
+		if scopes != nil && !security.HasScope(scopes, requiredScope) {
+			slog.Warn("git http rejected", "reason", "insufficient scope", "userId", id, "requiredScope", requiredScope)
+			return nil, c.Status(fiber.StatusForbidden).SendString("token does not have " + string(requiredScope) + " scope\n")
+		}
+		userId = &id
+	}
+
+	owner := c.Params("owner")
+	name := strings.TrimSuffix(c.Params("name"), ".git")
+	repo, err := h.Repos.GetRepositoryForGit(userId, owner, name, write)
+	if err != nil {
+		var repoNotFoundErr *service.ErrRepositoryNotFound
+		var permissionDeniedErr *service.ErrPermissionDenied
+		switch {
+		case errors.As(err, &repoNotFoundErr), errors.As(err, &permissionDeniedErr):
+			// 未ログインなら認証を求め、ログイン済みなら存在を明かさない
+			if userId == nil {
+				return nil, gitAuthRequired(c)
+			}
+			if permissionDeniedErr != nil {
+				slog.Warn("git http rejected", "reason", "permission denied", "userId", *userId, "owner", owner, "name", name)
+				return nil, c.Status(fiber.StatusForbidden).SendString("permission denied\n")
+			}
+			return nil, c.Status(fiber.StatusNotFound).SendString("repository not found\n")
+		}
+		var twoFactorRequiredErr *service.ErrTwoFactorRequired
+		if errors.As(err, &twoFactorRequiredErr) {
+			slog.Warn("git http rejected", "reason", "two-factor authentication required", "userId", twoFactorRequiredErr.UserId, "owner", owner, "name", name)
+			return nil, c.Status(fiber.StatusForbidden).SendString("two-factor authentication is required to push\n")
+		}
+		slog.Error("failed to get repository for git", "detail", err)
+		return nil, InternalError(c)
+	}
+
+	return repo, nil
+}
+
+// GitInfoRefs handler for GET /:owner/:name/info/refs
+func (h *Handler) GitInfoRefs(c *fiber.Ctx) error {
+	gitService := c.Query("service")
+	if !gitcmd.IsSupportedService(gitService) {
+		// dumb HTTP プロトコルには対応しない
+		return c.Status(fiber.StatusForbidden).SendString("smart http is required\n")
+	}
+
+	repo, err := h.gitRepository(c, gitService == gitcmd.ReceivePack)
+	if repo == nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	err = gitcmd.AdvertiseRefs(c.UserContext(), h.Repositories.Path(repo.ID), gitService, c.Get("Git-Protocol"), &out)
+	if err != nil {
+		slog.Error("failed to advertise refs", "repositoryId", repo.ID, "detail", err)
+		return InternalError(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-"+gitService+"-advertisement")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	return c.Send(out.Bytes())
+}
+
+// GitUploadPack handler for POST /:owner/:name/git-upload-pack
+func (h *Handler) GitUploadPack(c *fiber.Ctx) error {
+	return h.gitServiceRPC(c, gitcmd.UploadPack)
+}
+
+// GitReceivePack handler for POST /:owner/:name/git-receive-pack
+func (h *Handler) GitReceivePack(c *fiber.Ctx) error {
+	return h.gitServiceRPC(c, gitcmd.ReceivePack)
+}
+
+func (h *Handler) gitServiceRPC(c *fiber.Ctx, gitService string) error {
+	if c.Get(fiber.HeaderContentType) != "application/x-"+gitService+"-request" {
+		return c.Status(fiber.StatusUnsupportedMediaType).SendString("unsupported content type\n")
+	}
+
+	repo, err := h.gitRepository(c, gitService == gitcmd.ReceivePack)
+	if repo == nil {
+		return err
+	}
+
+	body, err := spoolGitRequestBody(c, int64(h.Git.MaxRequestBytes))
+	if errors.Is(err, errGitRequestTooLarge) {
+		slog.Warn("git request rejected", "reason", "body too large", "service", gitService, "repositoryId", repo.ID)
+		return c.Status(fiber.StatusRequestEntityTooLarge).SendString("request body too large\n")
+	}
+	if err != nil {
+		slog.Warn("failed to read git request body", "detail", err)
+		return BadRequestError(c)
+	}
+
+	path := h.Repositories.Path(repo.ID)
+	gitProtocol := c.Get("Git-Protocol")
+	c.Set(fiber.HeaderContentType, "application/x-"+gitService+"-result")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			_ = body.Close()
+			_ = os.Remove(body.Name())
+		}()
+		// ハンドラを抜けた後に動くので、リクエストのコンテキストは使わない
+		if err := gitcmd.ServiceRPC(context.Background(), path, gitService, gitProtocol, body, w); err != nil {
+			slog.Error("git service rpc failed", "service", gitService, "repositoryId", repo.ID, "detail", err)
+		}
+		_ = w.Flush()
+	})
+
+	slog.Info("git http service started", "service", gitService, "repositoryId", repo.ID)
+	return nil
+}
+
+var errGitRequestTooLarge = errors.New("git request body is too large")
+
+// spoolGitRequestBody はpackを含むリクエストボディを一時ファイルに書き出します。
+// ボディはレスポンスを書き込む間も読むので、メモリに載せずファイルにしておく。
+// 書き出す大きさが maxBytes を超えたら errGitRequestTooLarge を返します。gzipは展開後の大きさで数えるので、圧縮爆弾でディスクを埋められない。
+func spoolGitRequestBody(c *fiber.Ctx, maxBytes int64) (*os.File, error) {
+	var body io.Reader
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		body = stream
+	} else {
+		body = bytes.NewReader(c.Request().Body())
+	}
+	if c.Get(fiber.HeaderContentEncoding) == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	f, err := os.CreateTemp("", "gityard-git-request-*")
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(f, io.LimitReader(body, maxBytes+1))
+	if err == nil && n > maxBytes {
+		err = errGitRequestTooLarge
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoolGitRequestBody(t *testing.T) {
+	const maxBytes = 1024
+
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		f, err := spoolGitRequestBody(c, maxBytes)
+		if errors.Is(err, errGitRequestTooLarge) {
+			return c.SendStatus(fiber.StatusRequestEntityTooLarge)
+		}
+		if err != nil {
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		body, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		return c.Send(body)
+	})
+	send := func(body []byte, gzipped bool) int {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		if gzipped {
+			req.Header.Set(fiber.HeaderContentEncoding, "gzip")
+		}
+		res, err := app.Test(req)
+		assert.Nil(t, err)
+		return res.StatusCode
+	}
+	compress := func(body []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write(body)
+		_ = gz.Close()
+		return buf.Bytes()
+	}
+
+	assert.Equal(t, 200, send(bytes.Repeat([]byte("a"), maxBytes), false))
+	assert.Equal(t, 413, send(bytes.Repeat([]byte("a"), maxBytes+1), false))
+
+	// 圧縮すれば小さくても、展開後の大きさで数える
+	bomb := compress(bytes.Repeat([]byte("a"), 100*maxBytes))
+	assert.Less(t, len(bomb), maxBytes)
+	assert.Equal(t, 413, send(bomb, true))
+	assert.Equal(t, 200, send(compress(bytes.Repeat([]byte("a"), maxBytes)), true))
+}
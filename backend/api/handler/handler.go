@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"gityard-api/config"
+	"gityard-api/service"
+	"gityard-api/storage"
+)
+
+// Handler はAPIのハンドラーです。使うサービスは main で作成して渡します。
+// テストでは必要なサービスだけを設定して使えます。
+type Handler struct {
+	Auth          *service.AuthService
+	TwoFactor     *service.TwoFactorService
+	Tokens        *service.TokenService
+	Keys          *service.KeyService
+	Accounts      *service.AccountService
+	Organizations *service.OrganizationService
+	Repos         *service.RepoService
+
+	// Repositories はgit over HTTPでリポジトリを読み書きする場所です。
+	Repositories *storage.RepositoryStorage
+	// Cookie はリフレッシュトークンのクッキーの属性です。
+	Cookie config.CookieConfig
+	// Git はgit over HTTPのリクエストの上限です。
+	Git config.GitConfig
+}
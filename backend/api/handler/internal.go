@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/service"
+	"log/slog"
+	"strconv"
+)
+
+// InternalGetPubkeyOwner handler for GET /internal/keys?fingerprint=
+func (h *Handler) InternalGetPubkeyOwner(c *fiber.Ctx) error {
+	fingerprint := c.Query("fingerprint")
+	if fingerprint == "" {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	user, account, err := h.Keys.GetPubkeyOwner(fingerprint)
+	if err != nil {
+		var userNotFoundErr *service.ErrUserNotFound
+		var accountNotFoundErr *service.ErrAccountNotFound
+		if errors.As(err, &userNotFoundErr) || errors.As(err, &accountNotFoundErr) {
+			return NotFoundError(c)
+		}
+		slog.Error("failed to get pubkey owner", "detail", err)
+		return InternalError(c)
+	}
+
+	type Response struct {
+		UserId     uint   `json:"user_id"`
+		Handlename string `json:"handlename"`
+	}
+	return c.JSON(Response{
+		UserId:     user.ID,
+		Handlename: account.Handlename.Handlename,
+	})
+}
+
+// InternalGetRepositoryAccess handler for GET /internal/repos/:owner/:name/access?user_id=
+func (h *Handler) InternalGetRepositoryAccess(c *fiber.Ctx) error {
+	var userId *uint
+	if q := c.Query("user_id"); q != "" {
+		id, err := strconv.ParseUint(q, 10, 64)
+		if err != nil {
+			return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+		}
+		u := uint(id)
+		userId = &u
+	}
+
+	access, err := h.Repos.GetRepositoryAccess(userId, c.Params("owner"), c.Params("name"))
+	if err != nil {
+		var repoNotFoundErr *service.ErrRepositoryNotFound
+		if errors.As(err, &repoNotFoundErr) {
+			return NotFoundError(c)
+		}
+		slog.Error("failed to get repository access", "detail", err)
+		return InternalError(c)
+	}
+
+	type Response struct {
+		RepositoryId      uint   `json:"repository_id"`
+		Owner             string `json:"owner"` // 改名前のハンドルネームで問い合わせても今のハンドルネームを返す
+		Name              string `json:"name"`
+		Permission        string `json:"permission"`
+		CanRead           bool   `json:"can_read"`
+		CanWrite          bool   `json:"can_write"`
+		TwoFactorRequired bool   `json:"two_factor_required"` // 書き込み権限はあるが二要素認証が無効なためpushできない
+	}
+	return c.JSON(Response{
+		RepositoryId:      access.Repository.ID,
+		Owner:             access.Repository.OwnerAccount.Handlename.Handlename,
+		Name:              access.Repository.Name,
+		Permission:        access.Permission.String(),
+		CanRead:           access.Permission >= service.PermissionRead,
+		CanWrite:          access.CanWrite(),
+		TwoFactorRequired: access.TwoFactorRequired,
+	})
+}
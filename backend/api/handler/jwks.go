@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"gityard-api/security"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWKS handler for /.well-known/jwks.json
+// 他のサービスはここで公開鍵を取得して、アクセストークンをAPIサーバに問い合わせずに検証できます。
+// 知らない kid のトークンを受け取ったら取得し直してください。鍵を入れ替えた直後かもしれません。
+func (h *Handler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(security.JWKS())
+}
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/model"
+	"gityard-api/service"
+	"log/slog"
+)
+
+type organizationResponse struct {
+	Handlename  string `json:"handlename"`
+	Displayname string `json:"displayname"`
+	AvatarURL   string `json:"avatar_url"`
+	BaseRole    string `json:"base_role,omitempty"`
+}
+
+func newOrganizationResponse(organization *model.Account) organizationResponse {
+	res := organizationResponse{
+		Handlename:  organization.Handlename.Handlename,
+		Displayname: organization.AccountProfile.Displayname,
+		AvatarURL:   avatarURL(organization),
+	}
+	if organization.Organization != nil {
+		res.BaseRole = model.RepositoryRole(organization.Organization.BaseRole).String()
+	}
+	return res
+}
+
+// organizationError は組織の操作で起きたエラーをレスポンスに変換します。
+func organizationError(c *fiber.Ctx, action string, err error) error {
+	var registeredHandleNameErr *service.ErrRegisteredHandleName
+	if errors.As(err, &registeredHandleNameErr) {
+		slog.Info(action+" rejected", "reason", "registered handlename")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "registered handlename"})
+	}
+
+	var lastOwnerErr *service.ErrLastOrganizationOwner
+	if errors.As(err, &lastOwnerErr) {
+		slog.Info(action+" rejected", "reason", "last organization owner")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "organization needs at least one owner"})
+	}
+
+	var registeredTeamNameErr *service.ErrRegisteredTeamName
+	if errors.As(err, &registeredTeamNameErr) {
+		slog.Info(action+" rejected", "reason", "registered team name")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "registered team name"})
+	}
+
+	var invalidParentTeamErr *service.ErrInvalidParentTeam
+	if errors.As(err, &invalidParentTeamErr) {
+		slog.Info(action+" rejected", "reason", "invalid parent team")
+		return c.Status(422).JSON(fiber.Map{"message": "invalid parent team"})
+	}
+
+	var teamNotFoundErr *service.ErrTeamNotFound
+	if errors.As(err, &teamNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "team not found")
+		return NotFoundError(c)
+	}
+
+	var teamMemberNotFoundErr *service.ErrTeamMemberNotFound
+	if errors.As(err, &teamMemberNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "team member not found")
+		return NotFoundError(c)
+	}
+
+	var teamRepositoryNotFoundErr *service.ErrTeamRepositoryNotFound
+	if errors.As(err, &teamRepositoryNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "team repository not found")
+		return NotFoundError(c)
+	}
+
+	var memberNotFoundErr *service.ErrOrganizationMemberNotFound
+	if errors.As(err, &memberNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "member not found")
+		return NotFoundError(c)
+	}
+
+	return repositoryError(c, action, err)
+}
+
+// CreateOrganization handler for POST /orgs
+func (h *Handler) CreateOrganization(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		HandleName  string `json:"handlename" validate:"required,alphanum"`
+		Displayname string `json:"displayname" validate:"max=255"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	organization, err := h.Organizations.CreateOrganization(userId, req.HandleName, req.Displayname)
+	if err != nil {
+		return organizationError(c, "create organization", err)
+	}
+
+	slog.Info("organization created successfully", "userId", userId, "organizationAccountId", organization.ID)
+	return c.Status(fiber.StatusCreated).JSON(newOrganizationResponse(organization))
+}
+
+// GetOrganization handler for GET /orgs/:org
+func (h *Handler) GetOrganization(c *fiber.Ctx) error {
+	organization, err := h.Organizations.GetOrganization(c.Params("org"))
+	if err != nil {
+		return organizationError(c, "get organization", err)
+	}
+
+	return c.JSON(newOrganizationResponse(organization))
+}
+
+// UpdateOrganization handler for PATCH /orgs/:org
+func (h *Handler) UpdateOrganization(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		BaseRole string `json:"base_role" validate:"required,oneof=none read triage write maintain admin"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	baseRole, _ := model.ParseRepositoryRole(req.BaseRole) // none は 0
+
+	err = h.Organizations.UpdateOrganizationBaseRole(userId, c.Params("org"), baseRole)
+	if err != nil {
+		return organizationError(c, "update organization", err)
+	}
+
+	organization, err := h.Organizations.GetOrganization(c.Params("org"))
+	if err != nil {
+		return organizationError(c, "update organization", err)
+	}
+
+	slog.Info("organization updated successfully", "userId", userId, "org", c.Params("org"), "baseRole", req.BaseRole)
+	return c.JSON(newOrganizationResponse(organization))
+}
+
+// GetOrganizationMembers handler for GET /orgs/:org/members
+func (h *Handler) GetOrganizationMembers(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	members, err := h.Organizations.GetOrganizationMembers(userId, c.Params("org"), 0, 100)
+	if err != nil {
+		return organizationError(c, "get organization members", err)
+	}
+
+	type Item struct {
+		Handlename string `json:"handlename"`
+		Role       string `json:"role"`
+	}
+	type Response struct {
+		Members []Item `json:"members"`
+	}
+	res := Response{
+		Members: []Item{},
+	}
+	for _, member := range members {
+		res.Members = append(res.Members, Item{
+			Handlename: member.Handlename,
+			Role:       member.Role.String(),
+		})
+	}
+	return c.JSON(res)
+}
+
+// SetOrganizationMember handler for PUT /orgs/:org/members/:handlename
+func (h *Handler) SetOrganizationMember(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Role string `json:"role" validate:"required,oneof=member owner"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	role, _ := model.ParseOrganizationRole(req.Role)
+
+	err = h.Organizations.SetOrganizationMember(userId, c.Params("org"), c.Params("handlename"), role)
+	if err != nil {
+		return organizationError(c, "set organization member", err)
+	}
+
+	slog.Info("organization member set successfully", "userId", userId, "org", c.Params("org"), "member", c.Params("handlename"), "role", req.Role)
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// RemoveOrganizationMember handler for DELETE /orgs/:org/members/:handlename
+func (h *Handler) RemoveOrganizationMember(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Organizations.RemoveOrganizationMember(userId, c.Params("org"), c.Params("handlename"))
+	if err != nil {
+		return organizationError(c, "remove organization member", err)
+	}
+
+	slog.Info("organization member removed successfully", "userId", userId, "org", c.Params("org"), "member", c.Params("handlename"))
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// GetUserOrganizations handler for GET /settings/orgs
+func (h *Handler) GetUserOrganizations(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	organizations, err := h.Organizations.GetUserOrganizations(userId, 0, 100)
+	if err != nil {
+		slog.Error("failed to get user organizations", "detail", err)
+		return InternalError(c)
+	}
+
+	type Response struct {
+		Organizations []organizationResponse `json:"organizations"`
+	}
+	res := Response{
+		Organizations: []organizationResponse{},
+	}
+	for i := range organizations {
+		res.Organizations = append(res.Organizations, newOrganizationResponse(&organizations[i]))
+	}
+	return c.JSON(res)
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/service"
+	"log/slog"
+)
+
+// ChangePassword handler for POST /settings/password
+func (h *Handler) ChangePassword(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+	sessionId, ok := c.Locals("session_id").(uint)
+	if !ok {
+		slog.Error("session_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required,min=8"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	generation, err := h.Auth.ChangePassword(userId, sessionId, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		var passwordMissMatchErr *service.ErrPasswordMissMatch
+		if errors.As(err, &passwordMissMatchErr) {
+			slog.Warn("change password rejected", "reason", "password miss match", "userId", userId)
+			return c.Status(422).JSON(fiber.Map{"message": "invalid current password"})
+		}
+
+		slog.Error("failed to change password", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("user changed password successfully", "userId", userId, "sessionId", sessionId)
+	// 変更前のアクセストークンはすべて失効したので、このセッションのものを発行し直す
+	return respondAccessToken(c, userId, sessionId, generation)
+}
+
+// ForgotPassword handler for /password/forgot
+func (h *Handler) ForgotPassword(c *fiber.Ctx) error {
+	type Request struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	if err := h.Auth.RequestPasswordReset(req.Email); err != nil {
+		slog.Error("failed to request password reset", "detail", err)
+		return InternalError(c)
+	}
+
+	// 登録されているかどうかに関わらず同じレスポンスを返す
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{})
+}
+
+// ResetPassword handler for /password/reset
+func (h *Handler) ResetPassword(c *fiber.Ctx) error {
+	type Request struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=8"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	err = h.Auth.ResetPassword(req.Token, req.Password)
+	if err != nil {
+		var invalidTokenErr *service.ErrInvalidVerificationTokenProvided
+		if errors.As(err, &invalidTokenErr) {
+			slog.Warn("reset password rejected", "reason", "invalid token")
+			return c.Status(422).JSON(fiber.Map{"message": "invalid token"})
+		}
+
+		slog.Error("failed to reset password", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("user reset password successfully")
+	return c.Status(200).JSON(fiber.Map{})
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/model"
+	"gityard-api/service"
+	"gityard-api/storage"
+	"log/slog"
+)
+
+type profileResponse struct {
+	Handlename  string `json:"handlename"`
+	Displayname string `json:"displayname"`
+	AvatarURL   string `json:"avatar_url"` // アップロードした画像がなければアイデンティコン
+	IsPrivate   bool   `json:"is_private"`
+}
+
+// avatarURL はアカウントのアバター画像のURLを返します。
+func avatarURL(account *model.Account) string {
+	return "/api/v1/" + service.AvatarKey(account)
+}
+
+func newProfileResponse(account *model.Account) profileResponse {
+	return profileResponse{
+		Handlename:  account.Handlename.Handlename,
+		Displayname: account.AccountProfile.Displayname,
+		AvatarURL:   avatarURL(account),
+		IsPrivate:   account.AccountProfile.IsPrivate,
+	}
+}
+
+// profileError はプロフィールの操作で起きたエラーをレスポンスに変換します。
+func profileError(c *fiber.Ctx, action string, err error) error {
+	var accountNotFoundErr *service.ErrAccountNotFound
+	if errors.As(err, &accountNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "account not found")
+		return NotFoundError(c)
+	}
+
+	var invalidAvatarErr *service.ErrInvalidAvatar
+	if errors.As(err, &invalidAvatarErr) {
+		slog.Info(action+" rejected", "reason", "invalid avatar", "detail", invalidAvatarErr.Detail)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid image"})
+	}
+
+	slog.Error("failed to "+action, "detail", err)
+	return InternalError(c)
+}
+
+// GetProfile handler for GET /users/:handlename
+func (h *Handler) GetProfile(c *fiber.Ctx) error {
+	account, err := h.Accounts.GetProfile(optionalUserId(c), c.Params("handlename"))
+	if err != nil {
+		return profileError(c, "get profile", err)
+	}
+
+	return c.JSON(newProfileResponse(account))
+}
+
+// UpdateProfile handler for PATCH /settings/profile
+func (h *Handler) UpdateProfile(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Displayname *string `json:"displayname" validate:"omitempty,max=255"` // 空文字列にするとハンドルネームに戻す
+		IsPrivate   *bool   `json:"is_private"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	account, err := h.Accounts.UpdateProfile(userId, req.Displayname, req.IsPrivate)
+	if err != nil {
+		return profileError(c, "update profile", err)
+	}
+
+	slog.Info("profile updated successfully", "userId", userId)
+	return c.JSON(newProfileResponse(account))
+}
+
+// UploadAvatar handler for PUT /settings/profile/avatar
+// 画像は multipart/form-data の avatar フィールドで受け取ります。
+func (h *Handler) UploadAvatar(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		slog.Error("failed to open uploaded avatar", "detail", err)
+		return InternalError(c)
+	}
+	defer file.Close()
+
+	account, err := h.Accounts.UploadAvatar(userId, file)
+	if err != nil {
+		return profileError(c, "upload avatar", err)
+	}
+
+	slog.Info("avatar uploaded successfully", "userId", userId, "iconpath", account.AccountProfile.Iconpath)
+	return c.JSON(newProfileResponse(account))
+}
+
+// DeleteAvatar handler for DELETE /settings/profile/avatar
+func (h *Handler) DeleteAvatar(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	account, err := h.Accounts.DeleteAvatar(userId)
+	if err != nil {
+		return profileError(c, "delete avatar", err)
+	}
+
+	slog.Info("avatar deleted successfully", "userId", userId)
+	return c.JSON(newProfileResponse(account))
+}
+
+// GetAvatar handler for GET /avatars/*
+// アップロードした画像のキーは変更のたびに変わり、アイデンティコンはアカウントごとに変わらないので、ブラウザには長くキャッシュさせます。
+func (h *Handler) GetAvatar(c *fiber.Ctx) error {
+	r, err := h.Accounts.OpenAvatar("avatars/" + c.Params("*"))
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return NotFoundError(c)
+		}
+		slog.Error("failed to open avatar", "detail", err)
+		return InternalError(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "image/png")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.SendStream(r)
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/model"
+	"gityard-api/service"
+	"log/slog"
+	"time"
+)
+
+type repositoryResponse struct {
+	ID        uint      `json:"id"`
+	Owner     string    `json:"owner"`
+	Name      string    `json:"name"`
+	IsPrivate bool      `json:"is_private"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newRepositoryResponse(repo *model.Repository) repositoryResponse {
+	return repositoryResponse{
+		ID:        repo.ID,
+		Owner:     repo.OwnerAccount.Handlename.Handlename,
+		Name:      repo.Name,
+		IsPrivate: repo.IsPrivate,
+		CreatedAt: repo.CreatedAt,
+		UpdatedAt: repo.UpdatedAt,
+	}
+}
+
+// optionalUserId はログインしていれば user_id を、していなければ nil を返します。
+func optionalUserId(c *fiber.Ctx) *uint {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return nil
+	}
+	return &userId
+}
+
+// repositoryError はリポジトリ操作で起きたエラーをレスポンスに変換します。
+func repositoryError(c *fiber.Ctx, action string, err error) error {
+	var repoNotFoundErr *service.ErrRepositoryNotFound
+	if errors.As(err, &repoNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "repository not found")
+		return NotFoundError(c)
+	}
+
+	var accountNotFoundErr *service.ErrAccountNotFound
+	if errors.As(err, &accountNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "account not found")
+		return NotFoundError(c)
+	}
+
+	var registeredNameErr *service.ErrRegisteredRepositoryName
+	if errors.As(err, &registeredNameErr) {
+		slog.Info(action+" rejected", "reason", "registered repository name")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "registered repository name"})
+	}
+
+	var userNotFoundErr *service.ErrUserNotFound
+	if errors.As(err, &userNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "user not found")
+		return NotFoundError(c)
+	}
+
+	var collaboratorNotFoundErr *service.ErrCollaboratorNotFound
+	if errors.As(err, &collaboratorNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "collaborator not found")
+		return NotFoundError(c)
+	}
+
+	var invitationNotFoundErr *service.ErrInvitationNotFound
+	if errors.As(err, &invitationNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "invitation not found")
+		return NotFoundError(c)
+	}
+
+	var registeredCollaboratorErr *service.ErrRegisteredCollaborator
+	if errors.As(err, &registeredCollaboratorErr) {
+		slog.Info(action+" rejected", "reason", "registered collaborator")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "registered collaborator"})
+	}
+
+	var registeredInvitationErr *service.ErrRegisteredInvitation
+	if errors.As(err, &registeredInvitationErr) {
+		slog.Info(action+" rejected", "reason", "registered invitation")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "registered invitation"})
+	}
+
+	var invalidCollaboratorErr *service.ErrInvalidCollaborator
+	if errors.As(err, &invalidCollaboratorErr) {
+		slog.Info(action+" rejected", "reason", "invalid collaborator")
+		return c.Status(422).JSON(fiber.Map{"message": "invalid collaborator"})
+	}
+
+	var permissionDeniedErr *service.ErrPermissionDenied
+	if errors.As(err, &permissionDeniedErr) {
+		slog.Warn(action+" rejected", "reason", "permission denied", "userId", permissionDeniedErr.UserId)
+		return ForbiddenError(c)
+	}
+
+	slog.Error("failed to "+action, "detail", err)
+	return InternalError(c)
+}
+
+// CreateRepository handler for POST /repos
+func (h *Handler) CreateRepository(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Owner     string `json:"owner" validate:"omitempty,alphanum"` // 組織に作る場合に指定する
+		Name      string `json:"name" validate:"required,reponame"`
+		IsPrivate bool   `json:"is_private"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	repo, err := h.Repos.CreateRepository(userId, req.Owner, req.Name, req.IsPrivate)
+	if err != nil {
+		return repositoryError(c, "create repository", err)
+	}
+
+	slog.Info("repository created successfully", "userId", userId, "repositoryId", repo.ID)
+	return c.Status(fiber.StatusCreated).JSON(newRepositoryResponse(repo))
+}
+
+// GetRepository handler for GET /repos/:owner/:name
+func (h *Handler) GetRepository(c *fiber.Ctx) error {
+	repo, err := h.Repos.GetRepository(optionalUserId(c), c.Params("owner"), c.Params("name"))
+	if err != nil {
+		return repositoryError(c, "get repository", err)
+	}
+
+	return c.JSON(newRepositoryResponse(repo))
+}
+
+// GetRepositories handler for GET /repos/:owner
+func (h *Handler) GetRepositories(c *fiber.Ctx) error {
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", 30)
+	if offset < 0 || limit < 1 || limit > 100 {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	repos, err := h.Repos.GetRepositories(optionalUserId(c), c.Params("owner"), offset, limit)
+	if err != nil {
+		return repositoryError(c, "get repositories", err)
+	}
+
+	type Response struct {
+		Repositories []repositoryResponse `json:"repositories"`
+	}
+	res := Response{
+		Repositories: []repositoryResponse{},
+	}
+	for i := range repos {
+		res.Repositories = append(res.Repositories, newRepositoryResponse(&repos[i]))
+	}
+	return c.JSON(res)
+}
+
+// UpdateRepository handler for PATCH /repos/:owner/:name
+func (h *Handler) UpdateRepository(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Name      *string `json:"name" validate:"omitnil,reponame"`
+		IsPrivate *bool   `json:"is_private"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	repo, err := h.Repos.UpdateRepository(userId, c.Params("owner"), c.Params("name"), req.Name, req.IsPrivate)
+	if err != nil {
+		return repositoryError(c, "update repository", err)
+	}
+
+	slog.Info("repository updated successfully", "userId", userId, "repositoryId", repo.ID)
+	return c.JSON(newRepositoryResponse(repo))
+}
+
+// DeleteRepository handler for DELETE /repos/:owner/:name
+func (h *Handler) DeleteRepository(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Repos.DeleteRepository(userId, c.Params("owner"), c.Params("name"))
+	if err != nil {
+		return repositoryError(c, "delete repository", err)
+	}
+
+	slog.Info("repository deleted successfully", "userId", userId, "owner", c.Params("owner"), "name", c.Params("name"))
+	return c.Status(200).JSON(fiber.Map{})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/service"
+	"log/slog"
+	"time"
+)
+
+// GetUserSessions handler for GET /settings/sessions
+func (h *Handler) GetUserSessions(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+	sessionId, _ := c.Locals("session_id").(uint)
+
+	sessions, err := h.Auth.GetUserSessions(userId, 0, 100)
+	if err != nil {
+		slog.Error("failed to get user sessions", "detail", err)
+		return InternalError(c)
+	}
+
+	type Item struct {
+		ID         uint      `json:"id"`
+		UserAgent  string    `json:"user_agent"`
+		IPAddress  string    `json:"ip_address"`
+		Current    bool      `json:"current"` // このリクエストのアクセストークンを発行したセッション
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at"`
+		ExpiresAt  time.Time `json:"expires_at"`
+	}
+	type Response struct {
+		Sessions []Item `json:"sessions"`
+	}
+	res := Response{
+		Sessions: []Item{},
+	}
+	for _, session := range sessions {
+		res.Sessions = append(res.Sessions, Item{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			Current:    session.ID == sessionId,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+	return c.JSON(res)
+}
+
+// RevokeUserSession handler for DELETE /settings/sessions/:id
+func (h *Handler) RevokeUserSession(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	sessionId, err := c.ParamsInt("id")
+	if err != nil || sessionId <= 0 {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	err = h.Auth.RevokeUserSession(userId, uint(sessionId))
+	if err != nil {
+		var sessionNotFoundErr *service.ErrSessionNotFound
+		if errors.As(err, &sessionNotFoundErr) {
+			slog.Info("revoke session rejected", "reason", "session not found")
+			return NotFoundError(c)
+		}
+		slog.Error("failed to revoke session", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("session revoked successfully", "userId", userId, "sessionId", sessionId)
+	return c.Status(200).JSON(fiber.Map{})
+}
@@ -7,7 +7,7 @@ import (
 	"log/slog"
 )
 
-func RegisterSSHPublicKey(c *fiber.Ctx) error {
+func (h *Handler) RegisterSSHPublicKey(c *fiber.Ctx) error {
 	// cookieからuseridを取り出す
 	userId, ok := c.Locals("user_id").(uint)
 	if !ok {
@@ -31,7 +31,7 @@ func RegisterSSHPublicKey(c *fiber.Ctx) error {
 		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
 	}
 
-	pk, err := service.RegisterSSHPublicKey(userId, req.KeyName, req.PublicKeyFullText)
+	pk, err := h.Keys.RegisterSSHPublicKey(userId, req.KeyName, req.PublicKeyFullText)
 	if err != nil {
 		var invalidPkErr *service.ErrInvalidPubkeyProvided
 		if errors.As(err, &invalidPkErr) {
@@ -61,7 +61,7 @@ func RegisterSSHPublicKey(c *fiber.Ctx) error {
 	return c.JSON(res)
 }
 
-func GetSSHPublicKeys(c *fiber.Ctx) error {
+func (h *Handler) GetSSHPublicKeys(c *fiber.Ctx) error {
 	// cookieからを取り出す
 	userId, ok := c.Locals("user_id").(uint)
 	if !ok {
@@ -69,7 +69,7 @@ func GetSSHPublicKeys(c *fiber.Ctx) error {
 		return InternalError(c)
 	}
 
-	pubkeys, err := service.GetSSHPublicKeys(userId, 0, 30)
+	pubkeys, err := h.Keys.GetSSHPublicKeys(userId, 0, 30)
 	if err != nil {
 		slog.Error("failed to get ssh pubkeys", "detail", err)
 		return InternalError(c)
@@ -94,7 +94,7 @@ func GetSSHPublicKeys(c *fiber.Ctx) error {
 	return c.JSON(res)
 }
 
-func DeleteSSHPubkeyByFingerprint(c *fiber.Ctx) error {
+func (h *Handler) DeleteSSHPubkeyByFingerprint(c *fiber.Ctx) error {
 	// cookieからを取り出す
 	userId, ok := c.Locals("user_id").(uint)
 	if !ok {
@@ -117,7 +117,7 @@ func DeleteSSHPubkeyByFingerprint(c *fiber.Ctx) error {
 		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
 	}
 
-	err = service.DeleteSSHPublicKeyByFingerprint(userId, req.Fingerprint)
+	err = h.Keys.DeleteSSHPublicKeyByFingerprint(userId, req.Fingerprint)
 	if err != nil {
 		var userNotFoundErr *service.ErrUserNotFound
 		if errors.As(err, &userNotFoundErr) {
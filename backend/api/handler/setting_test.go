@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"gityard-api/model"
+	"gityard-api/service"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// memoryKeyStore は KeyService が使う分だけをメモリ上に持つストアです。
+type memoryKeyStore struct {
+	users    map[uint]*model.User
+	accounts map[uint]*model.Account
+	pubkeys  []model.UserPublicKey
+}
+
+var _ service.KeyServiceStore = (*memoryKeyStore)(nil)
+
+func (s *memoryKeyStore) Transaction(fn func(tx service.KeyServiceStore) error) error {
+	return fn(s)
+}
+
+func (s *memoryKeyStore) GetUserById(userId uint) (*model.User, error) {
+	return s.users[userId], nil
+}
+
+func (s *memoryKeyStore) GetPersonalAccountByUserId(userId uint) (*model.Account, error) {
+	return s.accounts[userId], nil
+}
+
+func (s *memoryKeyStore) CreatePublicKey(userId uint, name, fullKeyText, algorithm, keyBody, comment, fingerprint string) (*model.UserPublicKey, error) {
+	pubkey := model.UserPublicKey{
+		ID:          uint(len(s.pubkeys) + 1),
+		UserID:      userId,
+		Name:        name,
+		FullKeyText: fullKeyText,
+		Algorithm:   algorithm,
+		Keybody:     keyBody,
+		Comment:     comment,
+		Fingerprint: fingerprint,
+	}
+	s.pubkeys = append(s.pubkeys, pubkey)
+	return &pubkey, nil
+}
+
+func (s *memoryKeyStore) GetPubKeyByFingerprint(fingerprint string) (*model.UserPublicKey, error) {
+	for _, pubkey := range s.pubkeys {
+		if pubkey.Fingerprint == fingerprint {
+			return &pubkey, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryKeyStore) GetPubkeysByUserId(userId uint, offset, limit int) ([]model.UserPublicKey, error) {
+	var pubkeys []model.UserPublicKey
+	for _, pubkey := range s.pubkeys {
+		if pubkey.UserID == userId {
+			pubkeys = append(pubkeys, pubkey)
+		}
+	}
+	return pubkeys, nil
+}
+
+func (s *memoryKeyStore) DeletePublicKeyByFingerprint(userId uint, fingerprint string) error {
+	pubkeys := s.pubkeys[:0]
+	for _, pubkey := range s.pubkeys {
+		if pubkey.UserID != userId || pubkey.Fingerprint != fingerprint {
+			pubkeys = append(pubkeys, pubkey)
+		}
+	}
+	s.pubkeys = pubkeys
+	return nil
+}
+
+func (s *memoryKeyStore) DeletePublicKeysByUserId(userId uint) error {
+	pubkeys := s.pubkeys[:0]
+	for _, pubkey := range s.pubkeys {
+		if pubkey.UserID != userId {
+			pubkeys = append(pubkeys, pubkey)
+		}
+	}
+	s.pubkeys = pubkeys
+	return nil
+}
+
+func newKeyTestApp(store *memoryKeyStore, userId uint) *fiber.App {
+	h := &Handler{Keys: service.NewKeyService(store)}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", userId)
+		return c.Next()
+	})
+	app.Post("/keys", h.RegisterSSHPublicKey)
+	app.Get("/keys", h.GetSSHPublicKeys)
+	app.Delete("/keys", h.DeleteSSHPubkeyByFingerprint)
+	return app
+}
+
+func sendJSON(t *testing.T, app *fiber.App, method, path, body string) (int, map[string]any) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+
+	var data map[string]any
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&data))
+	return res.StatusCode, data
+}
+
+func TestSSHPublicKeys(t *testing.T) {
+	store := &memoryKeyStore{users: map[uint]*model.User{1: {ID: 1}}}
+	app := newKeyTestApp(store, 1)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	sshPub, err := ssh.NewPublicKey(pub)
+	assert.Nil(t, err)
+	fullText := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	fingerprint := ssh.FingerprintSHA256(sshPub)
+
+	status, data := sendJSON(t, app, "POST", "/keys", `{"name":"laptop","full_text":"`+fullText+`"}`)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, fingerprint, data["fingerprint"])
+
+	t.Run("duplicated key is conflict", func(t *testing.T) {
+		status, _ := sendJSON(t, app, "POST", "/keys", `{"name":"desktop","full_text":"`+fullText+`"}`)
+		assert.Equal(t, 409, status)
+	})
+
+	t.Run("invalid key is bad request", func(t *testing.T) {
+		status, _ := sendJSON(t, app, "POST", "/keys", `{"name":"desktop","full_text":"ssh-ed25519 not-a-key"}`)
+		assert.Equal(t, 400, status)
+	})
+
+	t.Run("missing name is unprocessable", func(t *testing.T) {
+		status, _ := sendJSON(t, app, "POST", "/keys", `{"full_text":"`+fullText+`"}`)
+		assert.Equal(t, 422, status)
+	})
+
+	status, data = sendJSON(t, app, "GET", "/keys", "")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []any{map[string]any{"name": "laptop", "fingerprint": fingerprint}}, data["keys"])
+
+	status, _ = sendJSON(t, app, "DELETE", "/keys", `{"fingerprint":"`+fingerprint+`"}`)
+	assert.Equal(t, 200, status)
+	assert.Empty(t, store.pubkeys)
+}
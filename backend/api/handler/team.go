@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/model"
+	"log/slog"
+)
+
+type teamResponse struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Parent      *string `json:"parent"`
+}
+
+func newTeamResponse(team *model.Team) teamResponse {
+	res := teamResponse{
+		Name:        team.Name,
+		Description: team.Description,
+	}
+	if team.ParentTeam != nil {
+		res.Parent = &team.ParentTeam.Name
+	}
+	return res
+}
+
+// CreateTeam handler for POST /orgs/:org/teams
+func (h *Handler) CreateTeam(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Name        string `json:"name" validate:"required,teamname"`
+		Description string `json:"description" validate:"max=255"`
+		Parent      string `json:"parent" validate:"omitempty,teamname"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	team, err := h.Organizations.CreateTeam(userId, c.Params("org"), req.Name, req.Description, req.Parent)
+	if err != nil {
+		return organizationError(c, "create team", err)
+	}
+
+	slog.Info("team created successfully", "userId", userId, "teamId", team.ID)
+	return c.Status(fiber.StatusCreated).JSON(newTeamResponse(team))
+}
+
+// GetTeams handler for GET /orgs/:org/teams
+func (h *Handler) GetTeams(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	teams, err := h.Organizations.GetTeams(userId, c.Params("org"), 0, 100)
+	if err != nil {
+		return organizationError(c, "get teams", err)
+	}
+
+	type Response struct {
+		Teams []teamResponse `json:"teams"`
+	}
+	res := Response{
+		Teams: []teamResponse{},
+	}
+	for i := range teams {
+		res.Teams = append(res.Teams, newTeamResponse(&teams[i]))
+	}
+	return c.JSON(res)
+}
+
+// GetTeam handler for GET /orgs/:org/teams/:team
+func (h *Handler) GetTeam(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	team, err := h.Organizations.GetTeam(userId, c.Params("org"), c.Params("team"))
+	if err != nil {
+		return organizationError(c, "get team", err)
+	}
+
+	return c.JSON(newTeamResponse(team))
+}
+
+// UpdateTeam handler for PATCH /orgs/:org/teams/:team
+func (h *Handler) UpdateTeam(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Name        *string `json:"name" validate:"omitnil,teamname"`
+		Description *string `json:"description" validate:"omitnil,max=255"`
+		Parent      *string `json:"parent" validate:"omitnil,eq=|teamname"` // 空文字で親チームを外す
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	team, err := h.Organizations.UpdateTeam(userId, c.Params("org"), c.Params("team"), req.Name, req.Description, req.Parent)
+	if err != nil {
+		return organizationError(c, "update team", err)
+	}
+
+	slog.Info("team updated successfully", "userId", userId, "teamId", team.ID)
+	return c.JSON(newTeamResponse(team))
+}
+
+// DeleteTeam handler for DELETE /orgs/:org/teams/:team
+func (h *Handler) DeleteTeam(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Organizations.DeleteTeam(userId, c.Params("org"), c.Params("team"))
+	if err != nil {
+		return organizationError(c, "delete team", err)
+	}
+
+	slog.Info("team deleted successfully", "userId", userId, "org", c.Params("org"), "team", c.Params("team"))
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// GetTeamMembers handler for GET /orgs/:org/teams/:team/members
+func (h *Handler) GetTeamMembers(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	members, err := h.Organizations.GetTeamMembers(userId, c.Params("org"), c.Params("team"), 0, 100)
+	if err != nil {
+		return organizationError(c, "get team members", err)
+	}
+
+	type Item struct {
+		Handlename string `json:"handlename"`
+	}
+	type Response struct {
+		Members []Item `json:"members"`
+	}
+	res := Response{
+		Members: []Item{},
+	}
+	for _, member := range members {
+		res.Members = append(res.Members, Item{
+			Handlename: member.Handlename,
+		})
+	}
+	return c.JSON(res)
+}
+
+// AddTeamMember handler for PUT /orgs/:org/teams/:team/members/:handlename
+func (h *Handler) AddTeamMember(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Organizations.AddTeamMember(userId, c.Params("org"), c.Params("team"), c.Params("handlename"))
+	if err != nil {
+		return organizationError(c, "add team member", err)
+	}
+
+	slog.Info("team member added successfully", "userId", userId, "team", c.Params("team"), "member", c.Params("handlename"))
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// RemoveTeamMember handler for DELETE /orgs/:org/teams/:team/members/:handlename
+func (h *Handler) RemoveTeamMember(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Organizations.RemoveTeamMember(userId, c.Params("org"), c.Params("team"), c.Params("handlename"))
+	if err != nil {
+		return organizationError(c, "remove team member", err)
+	}
+
+	slog.Info("team member removed successfully", "userId", userId, "team", c.Params("team"), "member", c.Params("handlename"))
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// GetTeamRepositories handler for GET /orgs/:org/teams/:team/repos
+func (h *Handler) GetTeamRepositories(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	teamRepos, err := h.Organizations.GetTeamRepositories(userId, c.Params("org"), c.Params("team"), 0, 100)
+	if err != nil {
+		return organizationError(c, "get team repositories", err)
+	}
+
+	type Item struct {
+		repositoryResponse
+		Role string `json:"role"`
+	}
+	type Response struct {
+		Repositories []Item `json:"repositories"`
+	}
+	res := Response{
+		Repositories: []Item{},
+	}
+	for i := range teamRepos {
+		res.Repositories = append(res.Repositories, Item{
+			repositoryResponse: newRepositoryResponse(&teamRepos[i].Repository),
+			Role:               model.RepositoryRole(teamRepos[i].Role).String(),
+		})
+	}
+	return c.JSON(res)
+}
+
+// SetTeamRepository handler for PUT /orgs/:org/teams/:team/repos/:name
+func (h *Handler) SetTeamRepository(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Role string `json:"role" validate:"required,oneof=read triage write maintain admin"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	role, _ := model.ParseRepositoryRole(req.Role)
+
+	err = h.Organizations.SetTeamRepository(userId, c.Params("org"), c.Params("team"), c.Params("name"), role)
+	if err != nil {
+		return organizationError(c, "set team repository", err)
+	}
+
+	slog.Info("team repository set successfully", "userId", userId, "team", c.Params("team"), "repository", c.Params("name"), "role", req.Role)
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// RemoveTeamRepository handler for DELETE /orgs/:org/teams/:team/repos/:name
+func (h *Handler) RemoveTeamRepository(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	err := h.Organizations.RemoveTeamRepository(userId, c.Params("org"), c.Params("team"), c.Params("name"))
+	if err != nil {
+		return organizationError(c, "remove team repository", err)
+	}
+
+	slog.Info("team repository removed successfully", "userId", userId, "team", c.Params("team"), "repository", c.Params("name"))
+	return c.Status(200).JSON(fiber.Map{})
+}
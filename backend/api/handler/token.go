@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/model"
+	"gityard-api/security"
+	"gityard-api/service"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type personalAccessTokenResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newPersonalAccessTokenResponse(accessToken *model.UserAccessToken) personalAccessTokenResponse {
+	return personalAccessTokenResponse{
+		ID:         accessToken.ID,
+		Name:       accessToken.Name,
+		Scopes:     strings.Fields(accessToken.Scopes),
+		ExpiresAt:  accessToken.ExpiresAt,
+		LastUsedAt: accessToken.LastUsedAt,
+		CreatedAt:  accessToken.CreatedAt,
+	}
+}
+
+// CreatePersonalAccessToken handler for POST /settings/tokens
+func (h *Handler) CreatePersonalAccessToken(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		Name          string   `json:"name" validate:"required,max=255"`
+		Scopes        []string `json:"scopes" validate:"required,min=1,dive,required"`
+		ExpiresInDays *int     `json:"expires_in_days" validate:"omitnil,min=1,max=365"` // 省略すると無期限
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	scopes, ok := security.ParseScopes(req.Scopes)
+	if !ok {
+		slog.Debug("failed to validate", "detail", "unknown scope")
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	accessToken, token, err := h.Tokens.CreatePersonalAccessToken(userId, req.Name, scopes, expiresAt)
+	if err != nil {
+		slog.Error("failed to create personal access token", "detail", err)
+		return InternalError(c)
+	}
+
+	type Response struct {
+		personalAccessTokenResponse
+		Token string `json:"token"` // 平文のトークンはこのレスポンスでしか返さない
+	}
+	slog.Info("personal access token created successfully", "userId", userId, "tokenId", accessToken.ID)
+	return c.Status(fiber.StatusCreated).JSON(Response{
+		personalAccessTokenResponse: newPersonalAccessTokenResponse(accessToken),
+		Token:                       token,
+	})
+}
+
+// GetPersonalAccessTokens handler for GET /settings/tokens
+func (h *Handler) GetPersonalAccessTokens(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	accessTokens, err := h.Tokens.GetPersonalAccessTokens(userId, 0, 100)
+	if err != nil {
+		slog.Error("failed to get personal access tokens", "detail", err)
+		return InternalError(c)
+	}
+
+	type Response struct {
+		Tokens []personalAccessTokenResponse `json:"tokens"`
+	}
+	res := Response{
+		Tokens: []personalAccessTokenResponse{},
+	}
+	for i := range accessTokens {
+		res.Tokens = append(res.Tokens, newPersonalAccessTokenResponse(&accessTokens[i]))
+	}
+	return c.JSON(res)
+}
+
+// DeletePersonalAccessToken handler for DELETE /settings/tokens/:id
+func (h *Handler) DeletePersonalAccessToken(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	tokenId, err := c.ParamsInt("id")
+	if err != nil || tokenId <= 0 {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	err = h.Tokens.DeletePersonalAccessToken(userId, uint(tokenId))
+	if err != nil {
+		var tokenNotFoundErr *service.ErrAccessTokenNotFound
+		if errors.As(err, &tokenNotFoundErr) {
+			slog.Info("delete personal access token rejected", "reason", "token not found")
+			return NotFoundError(c)
+		}
+		slog.Error("failed to delete personal access token", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("personal access token deleted successfully", "userId", userId, "tokenId", tokenId)
+	return c.Status(200).JSON(fiber.Map{})
+}
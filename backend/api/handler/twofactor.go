@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/service"
+	"log/slog"
+	"time"
+)
+
+// twoFactorError は二要素認証の設定で起きたエラーをレスポンスに変換します。
+func twoFactorError(c *fiber.Ctx, action string, err error) error {
+	var alreadyEnabledErr *service.ErrTwoFactorAlreadyEnabled
+	if errors.As(err, &alreadyEnabledErr) {
+		slog.Info(action+" rejected", "reason", "two-factor authentication already enabled")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "two-factor authentication already enabled"})
+	}
+
+	var notEnabledErr *service.ErrTwoFactorNotEnabled
+	if errors.As(err, &notEnabledErr) {
+		slog.Info(action+" rejected", "reason", "two-factor authentication not enabled")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "two-factor authentication not enabled"})
+	}
+
+	var enrollmentNotFoundErr *service.ErrTwoFactorEnrollmentNotFound
+	if errors.As(err, &enrollmentNotFoundErr) {
+		slog.Info(action+" rejected", "reason", "two-factor enrollment not found")
+		return NotFoundError(c)
+	}
+
+	var invalidCodeErr *service.ErrInvalidTwoFactorCode
+	if errors.As(err, &invalidCodeErr) {
+		slog.Warn(action+" rejected", "reason", "invalid two-factor code", "userId", invalidCodeErr.UserId)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid code"})
+	}
+
+	slog.Error("failed to "+action, "detail", err)
+	return InternalError(c)
+}
+
+// twoFactorCodeRequest は二要素認証のコードだけを受け取るリクエストを読み込みます。
+func twoFactorCodeRequest(c *fiber.Ctx) (string, bool) {
+	type Request struct {
+		Code string `json:"code" validate:"required,max=32"` // TOTPかリカバリーコード
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return "", false
+	}
+	// validation
+	if err := validate.Struct(req); err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return "", false
+	}
+	return req.Code, true
+}
+
+// GetTwoFactorStatus handler for GET /settings/2fa
+func (h *Handler) GetTwoFactorStatus(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	status, err := h.TwoFactor.GetTwoFactorStatus(userId)
+	if err != nil {
+		return twoFactorError(c, "get two-factor status", err)
+	}
+
+	type Response struct {
+		Enabled                bool       `json:"enabled"` // TOTPかWebAuthnのどちらかが有効
+		TOTPEnabled            bool       `json:"totp_enabled"`
+		TOTPEnabledAt          *time.Time `json:"totp_enabled_at"`
+		WebAuthnCredentials    int64      `json:"webauthn_credentials"`
+		RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+	}
+	return c.JSON(Response{
+		Enabled:                status.Enabled,
+		TOTPEnabled:            status.TOTPEnabled,
+		TOTPEnabledAt:          status.TOTPEnabledAt,
+		WebAuthnCredentials:    status.WebAuthnCredentials,
+		RecoveryCodesRemaining: status.RecoveryCodesRemaining,
+	})
+}
+
+// EnrollTwoFactor handler for POST /settings/2fa
+func (h *Handler) EnrollTwoFactor(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	enrollment, err := h.TwoFactor.EnrollTwoFactor(userId)
+	if err != nil {
+		return twoFactorError(c, "enroll two-factor", err)
+	}
+
+	slog.Info("two-factor enrollment started", "userId", userId)
+	type Response struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}
+	return c.Status(fiber.StatusCreated).JSON(Response{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	})
+}
+
+// ConfirmTwoFactor handler for POST /settings/2fa/confirm
+func (h *Handler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	code, ok := twoFactorCodeRequest(c)
+	if !ok {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	recoveryCodes, err := h.TwoFactor.ConfirmTwoFactor(userId, code)
+	if err != nil {
+		return twoFactorError(c, "confirm two-factor", err)
+	}
+
+	slog.Info("two-factor authentication enabled", "userId", userId)
+	type Response struct {
+		RecoveryCodes []string `json:"recovery_codes"` // 表示するのはこのときだけ
+	}
+	return c.JSON(Response{RecoveryCodes: recoveryCodes})
+}
+
+// DisableTwoFactor handler for DELETE /settings/2fa
+func (h *Handler) DisableTwoFactor(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	code, ok := twoFactorCodeRequest(c)
+	if !ok {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	err := h.TwoFactor.DisableTwoFactor(userId, code)
+	if err != nil {
+		return twoFactorError(c, "disable two-factor", err)
+	}
+
+	slog.Info("two-factor authentication disabled", "userId", userId)
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// RegenerateRecoveryCodes handler for POST /settings/2fa/recovery-codes
+func (h *Handler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	code, ok := twoFactorCodeRequest(c)
+	if !ok {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	recoveryCodes, err := h.TwoFactor.RegenerateRecoveryCodes(userId, code)
+	if err != nil {
+		return twoFactorError(c, "regenerate recovery codes", err)
+	}
+
+	slog.Info("recovery codes regenerated", "userId", userId)
+	type Response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	return c.JSON(Response{RecoveryCodes: recoveryCodes})
+}
@@ -1,5 +1,39 @@
 package handler
 
-import "github.com/go-playground/validator/v10"
+import (
+	"regexp"
+	"strings"
 
-var validate = validator.New(validator.WithRequiredStructEnabled())
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+// リポジトリ名に使える文字。URL やディスク上のパスでそのまま使えるものに限る
+var repositoryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+
+// チーム名に使える文字。URL のパスにそのまま使う
+var teamNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,99}$`)
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	_ = v.RegisterValidation("reponame", validateRepositoryName)
+	_ = v.RegisterValidation("teamname", validateTeamName)
+	return v
+}
+
+func validateRepositoryName(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+	if !repositoryNamePattern.MatchString(name) {
+		return false
+	}
+	// "." や ".." 、クローンURLと紛らわしい ".git" 終わりは許可しない
+	if name == "." || name == ".." || strings.HasSuffix(name, ".git") {
+		return false
+	}
+	return true
+}
+
+func validateTeamName(fl validator.FieldLevel) bool {
+	return teamNamePattern.MatchString(fl.Field().String())
+}
@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gityard-api/model"
+	"gityard-api/service"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type webAuthnCredentialResponse struct {
+	ID           uint       `json:"id"`
+	Name         string     `json:"name"`
+	Transports   []string   `json:"transports"`
+	CloneWarning bool       `json:"clone_warning"` // trueなら削除して登録し直すまで使えない
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func newWebAuthnCredentialResponse(credential *model.UserWebAuthnCredential) webAuthnCredentialResponse {
+	transports := strings.Fields(credential.Transports)
+	if transports == nil {
+		transports = []string{}
+	}
+	return webAuthnCredentialResponse{
+		ID:           credential.ID,
+		Name:         credential.Name,
+		Transports:   transports,
+		CloneWarning: credential.CloneWarning,
+		LastUsedAt:   credential.LastUsedAt,
+		CreatedAt:    credential.CreatedAt,
+	}
+}
+
+// webAuthnCeremonyResponse はセレモニーを始めたときのレスポンスです。options はそのまま navigator.credentials に渡します。
+type webAuthnCeremonyResponse struct {
+	CeremonyToken string          `json:"ceremony_token"`
+	Options       json.RawMessage `json:"options"`
+	ExpiresIn     int64           `json:"expires_in"`
+}
+
+func newWebAuthnCeremonyResponse(start *service.WebAuthnCeremonyStart) webAuthnCeremonyResponse {
+	return webAuthnCeremonyResponse{
+		CeremonyToken: start.Token,
+		Options:       start.Options,
+		ExpiresIn:     int64(start.ExpiresIn.Seconds()),
+	}
+}
+
+// webAuthnLoginError はWebAuthnでのログインで起きたエラーをレスポンスに変換します。
+func webAuthnLoginError(c *fiber.Ctx, err error) error {
+	var invalidChallengeErr *service.ErrInvalidTwoFactorChallengeProvided
+	if errors.As(err, &invalidChallengeErr) {
+		slog.Warn("user login rejected", "reason", "invalid two-factor challenge")
+		return UnauthorizedError(c)
+	}
+
+	var invalidCeremonyErr *service.ErrInvalidWebAuthnCeremonyProvided
+	if errors.As(err, &invalidCeremonyErr) {
+		slog.Warn("user login rejected", "reason", "invalid webauthn ceremony")
+		return UnauthorizedError(c)
+	}
+
+	var invalidResponseErr *service.ErrInvalidWebAuthnResponse
+	if errors.As(err, &invalidResponseErr) {
+		slog.Warn("user login rejected", "reason", "invalid webauthn response", "detail", invalidResponseErr.Detail)
+		return UnauthorizedError(c)
+	}
+
+	var clonedErr *service.ErrWebAuthnCredentialCloned
+	if errors.As(err, &clonedErr) {
+		slog.Warn("user login rejected", "reason", "webauthn credential may be cloned", "userId", clonedErr.UserId, "credentialId", clonedErr.CredentialId)
+		return UnauthorizedError(c)
+	}
+
+	var credentialNotFoundErr *service.ErrWebAuthnCredentialNotFound
+	if errors.As(err, &credentialNotFoundErr) {
+		slog.Info("user login rejected", "reason", "webauthn credential not found")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "no webauthn credentials registered"})
+	}
+
+	slog.Error("user failed to login", "detail", err)
+	return InternalError(c)
+}
+
+// GetWebAuthnCredentials handler for GET /settings/webauthn
+func (h *Handler) GetWebAuthnCredentials(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	credentials, err := h.TwoFactor.GetWebAuthnCredentials(userId)
+	if err != nil {
+		slog.Error("failed to get webauthn credentials", "detail", err)
+		return InternalError(c)
+	}
+
+	type Response struct {
+		Credentials []webAuthnCredentialResponse `json:"credentials"`
+	}
+	res := Response{
+		Credentials: []webAuthnCredentialResponse{},
+	}
+	for i := range credentials {
+		res.Credentials = append(res.Credentials, newWebAuthnCredentialResponse(&credentials[i]))
+	}
+	return c.JSON(res)
+}
+
+// BeginWebAuthnRegistration handler for POST /settings/webauthn/register
+func (h *Handler) BeginWebAuthnRegistration(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	start, err := h.TwoFactor.BeginWebAuthnRegistration(userId)
+	if err != nil {
+		slog.Error("failed to begin webauthn registration", "detail", err)
+		return InternalError(c)
+	}
+
+	return c.JSON(newWebAuthnCeremonyResponse(start))
+}
+
+// FinishWebAuthnRegistration handler for POST /settings/webauthn/register/finish
+func (h *Handler) FinishWebAuthnRegistration(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	type Request struct {
+		CeremonyToken string          `json:"ceremony_token" validate:"required"`
+		Name          string          `json:"name" validate:"required,max=255"`
+		Credential    json.RawMessage `json:"credential" validate:"required"` // navigator.credentials.create() の結果
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	credential, recoveryCodes, err := h.TwoFactor.FinishWebAuthnRegistration(userId, req.CeremonyToken, req.Name, req.Credential)
+	if err != nil {
+		var invalidCeremonyErr *service.ErrInvalidWebAuthnCeremonyProvided
+		if errors.As(err, &invalidCeremonyErr) {
+			slog.Info("webauthn registration rejected", "reason", "invalid ceremony")
+			return c.Status(422).JSON(fiber.Map{"message": "invalid ceremony"})
+		}
+
+		var invalidResponseErr *service.ErrInvalidWebAuthnResponse
+		if errors.As(err, &invalidResponseErr) {
+			slog.Info("webauthn registration rejected", "reason", "invalid response", "detail", invalidResponseErr.Detail)
+			return c.Status(422).JSON(fiber.Map{"message": "invalid credential"})
+		}
+
+		var alreadyRegisteredErr *service.ErrWebAuthnCredentialAlreadyRegistered
+		if errors.As(err, &alreadyRegisteredErr) {
+			slog.Info("webauthn registration rejected", "reason", "credential already registered")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "credential already registered"})
+		}
+
+		slog.Error("failed to finish webauthn registration", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("webauthn credential registered successfully", "userId", userId, "credentialId", credential.ID)
+	type Response struct {
+		webAuthnCredentialResponse
+		RecoveryCodes []string `json:"recovery_codes,omitempty"` // 初めての二要素目の手段を登録したときだけ返す
+	}
+	return c.Status(fiber.StatusCreated).JSON(Response{
+		webAuthnCredentialResponse: newWebAuthnCredentialResponse(credential),
+		RecoveryCodes:              recoveryCodes,
+	})
+}
+
+// DeleteWebAuthnCredential handler for DELETE /settings/webauthn/:id
+func (h *Handler) DeleteWebAuthnCredential(c *fiber.Ctx) error {
+	userId, ok := c.Locals("user_id").(uint)
+	if !ok {
+		slog.Error("user_id not found in locals or is not uint")
+		return InternalError(c)
+	}
+
+	credentialId, err := c.ParamsInt("id")
+	if err != nil || credentialId <= 0 {
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	err = h.TwoFactor.DeleteWebAuthnCredential(userId, uint(credentialId))
+	if err != nil {
+		var credentialNotFoundErr *service.ErrWebAuthnCredentialNotFound
+		if errors.As(err, &credentialNotFoundErr) {
+			slog.Info("delete webauthn credential rejected", "reason", "credential not found")
+			return NotFoundError(c)
+		}
+		slog.Error("failed to delete webauthn credential", "detail", err)
+		return InternalError(c)
+	}
+
+	slog.Info("webauthn credential deleted successfully", "userId", userId, "credentialId", credentialId)
+	return c.Status(200).JSON(fiber.Map{})
+}
+
+// BeginTwoFactorWebAuthnLogin handler for /login/2fa/webauthn
+func (h *Handler) BeginTwoFactorWebAuthnLogin(c *fiber.Ctx) error {
+	type Request struct {
+		ChallengeToken string `json:"challenge_token" validate:"required"`
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	options, err := h.Auth.BeginTwoFactorWebAuthnLogin(req.ChallengeToken)
+	if err != nil {
+		return webAuthnLoginError(c, err)
+	}
+
+	type Response struct {
+		Options json.RawMessage `json:"options"` // そのまま navigator.credentials.get() に渡す
+	}
+	return c.JSON(Response{Options: options})
+}
+
+// FinishTwoFactorWebAuthnLogin handler for /login/2fa/webauthn/finish
+func (h *Handler) FinishTwoFactorWebAuthnLogin(c *fiber.Ctx) error {
+	type Request struct {
+		ChallengeToken string          `json:"challenge_token" validate:"required"`
+		Credential     json.RawMessage `json:"credential" validate:"required"` // navigator.credentials.get() の結果
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	session, err := h.Auth.FinishTwoFactorWebAuthnLogin(req.ChallengeToken, req.Credential, sessionClient(c))
+	if err != nil {
+		return webAuthnLoginError(c, err)
+	}
+
+	slog.Info("user logged in successfully", "userId", session.UserId, "sessionId", session.SessionId, "method", "webauthn")
+	return h.setTokensAndRespond(c, session)
+}
+
+// BeginPasswordlessLogin handler for /webauthn
+func (h *Handler) BeginPasswordlessLogin(c *fiber.Ctx) error {
+	start, err := h.Auth.BeginPasswordlessLogin()
+	if err != nil {
+		slog.Error("failed to begin passwordless login", "detail", err)
+		return InternalError(c)
+	}
+
+	return c.JSON(newWebAuthnCeremonyResponse(start))
+}
+
+// FinishPasswordlessLogin handler for /webauthn/finish
+func (h *Handler) FinishPasswordlessLogin(c *fiber.Ctx) error {
+	type Request struct {
+		CeremonyToken string          `json:"ceremony_token" validate:"required"`
+		Credential    json.RawMessage `json:"credential" validate:"required"` // navigator.credentials.get() の結果
+	}
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		slog.Debug("failed to parse", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+	// validation
+	err := validate.Struct(req)
+	if err != nil {
+		slog.Debug("failed to validate", "detail", err)
+		return c.Status(422).JSON(fiber.Map{"message": "invalid request"})
+	}
+
+	session, err := h.Auth.FinishPasswordlessLogin(req.CeremonyToken, req.Credential, sessionClient(c))
+	if err != nil {
+		return webAuthnLoginError(c, err)
+	}
+
+	slog.Info("user logged in successfully", "userId", session.UserId, "sessionId", session.SessionId, "method", "passkey")
+	return h.setTokensAndRespond(c, session)
+}
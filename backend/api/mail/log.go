@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer はメールを送らずにログに出すメーラーです。ローカル開発とテスト用です。
+// dir を指定すると、送るはずだったメールをそのディレクトリに .eml として保存します。
+type LogMailer struct {
+	dir  string
+	from string
+}
+
+func NewLogMailer(dir, from string) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+	}
+	return &LogMailer{dir: dir, from: from}, nil
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	now := time.Now()
+	if m.dir == "" {
+		slog.Info("mail not sent (log mailer)", "to", msg.To, "subject", msg.Subject, "body", msg.Text)
+		return nil
+	}
+
+	path := filepath.Join(m.dir, fmt.Sprintf("%d.eml", now.UnixNano()))
+	if err := os.WriteFile(path, format(m.from, msg, now), 0o640); err != nil {
+		return err
+	}
+	slog.Info("mail not sent (log mailer)", "to", msg.To, "subject", msg.Subject, "path", path)
+	return nil
+}
@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"gityard-api/config"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"time"
+)
+
+// Message は送信するメールです。HTML があればテキストと HTML の multipart/alternative で送ります。
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer はメールの送信方法です。本番ではSMTP、ローカル開発とテストではログやファイルに書き出します。
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// NewMailer は cfg.Mailer に応じたメーラーを作成します。
+//
+//	smtp: SMTPHost, SMTPPort, SMTPUsername, SMTPPassword を使って送信する
+//	log:  送信せずにログに出す。LogDir があればそこに .eml として保存する
+//
+// 差出人は cfg.From です。
+func NewMailer(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	case "log":
+		return NewLogMailer(cfg.LogDir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mailer: %s", cfg.Mailer)
+	}
+}
+
+// format はメッセージをRFC 5322の形式にします。件名は日本語でも送れるようにエンコードします。
+func format(from string, msg *Message, now time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&buf, msg.Text)
+		return buf.Bytes()
+	}
+
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+	// 後ろのパートほど優先されるので、HTMLを後にする
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType+"; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, _ := w.CreatePart(header) // bytes.Buffer への書き込みは失敗しない
+		writeQuotedPrintable(pw, part.body)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// writeQuotedPrintable は本文をquoted-printableで書き出します。SMTPの1行の長さの上限を超えないようにし、改行もCRLFにします。
+func writeQuotedPrintable(w io.Writer, body string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(body))
+	qp.Close()
+}
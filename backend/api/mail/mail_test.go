@@ -0,0 +1,81 @@
+package mail_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gityard-api/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogMailer(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mail.NewLogMailer(dir, "gityard <noreply@example.com>")
+	require.NoError(t, err)
+
+	err = m.Send(&mail.Message{
+		To:      "alice@example.com",
+		Subject: "メールアドレスの確認",
+		Text:    "line1\nline2\n",
+	})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	b, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	eml := string(b)
+
+	assert.Contains(t, eml, "From: gityard <noreply@example.com>\r\n")
+	assert.Contains(t, eml, "To: alice@example.com\r\n")
+	// 日本語の件名はエンコードする
+	assert.Contains(t, eml, "Subject: =?utf-8?q?")
+	assert.True(t, strings.HasSuffix(eml, "\r\n\r\nline1\r\nline2\r\n"))
+}
+
+func TestRender(t *testing.T) {
+	data := map[string]any{
+		"Link":             "https://example.com/verify?token=a&b",
+		"ExpiresInHours":   24,
+		"ExpiresInMinutes": 60,
+		"Inviter":          "alice",
+		"Repository":       "alice/<repo>",
+		"Role":             "write",
+		"DeleteAt":         "2026-01-02 03:04 UTC",
+	}
+	for _, name := range mail.Templates {
+		for _, locale := range mail.Locales {
+			msg, err := mail.Render(name, locale, data)
+			require.NoError(t, err, "%s.%s", name, locale)
+			assert.NotEmpty(t, msg.Subject, "%s.%s", name, locale)
+			assert.NotContains(t, msg.Subject, "\n")
+			assert.NotEmpty(t, msg.Text)
+			assert.NotContains(t, msg.Text, "<no value>")
+			assert.NotContains(t, msg.HTML, "<no value>")
+			// HTMLではエスケープする
+			assert.NotContains(t, msg.HTML, "<repo>")
+		}
+	}
+
+	msg, err := mail.Render(mail.EmailVerificationTemplate, "ja", data)
+	require.NoError(t, err)
+	assert.Equal(t, "メールアドレスの確認", msg.Subject)
+	assert.Contains(t, msg.Text, "https://example.com/verify?token=a&b\n")
+	assert.Contains(t, msg.HTML, `href="https://example.com/verify?token=a&amp;b"`)
+
+	// 対応していない言語は既定の言語にする
+	msg, err = mail.Render(mail.EmailVerificationTemplate, "fr", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Verify your email address", msg.Subject)
+}
+
+func TestMatchLocale(t *testing.T) {
+	assert.Equal(t, "ja", mail.MatchLocale("ja-JP,ja;q=0.9,en-US;q=0.8"))
+	assert.Equal(t, "en", mail.MatchLocale("en-US,en;q=0.9"))
+	assert.Equal(t, "ja", mail.MatchLocale("fr-FR,ja;q=0.5"))
+	assert.Equal(t, "en", mail.MatchLocale("fr-FR"))
+	assert.Equal(t, "en", mail.MatchLocale(""))
+}
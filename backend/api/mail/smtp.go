@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer はSMTPサーバを経由してメールを送ります。サーバが対応していればSTARTTLSを使います。
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer は username が空ならSMTP認証をせずに送信するメーラーを作ります。
+func NewSMTPMailer(host, port, username, password, from string) (*SMTPMailer, error) {
+	if host == "" {
+		return nil, errors.New("smtp host is empty")
+	}
+	if port == "" {
+		port = "587"
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, err
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+		from: from,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, from.Address, []string{to.Address}, format(m.from, msg, time.Now()))
+}
@@ -0,0 +1,149 @@
+package mail_test
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gityard-api/mail"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"strings"
+	"testing"
+)
+
+// smtpStandIn はテスト用のSMTPサーバです。受け取ったメールを記録し、rejectRcpt なら宛先を一時エラーで拒否します。
+type smtpStandIn struct {
+	listener   net.Listener
+	received   chan string
+	rejectRcpt bool
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpStandIn{listener: l, received: make(chan string, 10)}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) hostPort() (string, string) {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host, port
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			if s.rejectRcpt {
+				reply("451 try again later")
+				continue
+			}
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.received <- data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newSMTPStandIn(t)
+	host, port := server.hostPort()
+	m, err := mail.NewSMTPMailer(host, port, "", "", "gityard <noreply@example.com>")
+	require.NoError(t, err)
+
+	msg, err := mail.Render(mail.PasswordResetTemplate, "ja", map[string]any{
+		"Link":             "https://example.com/reset-password?token=abc",
+		"ExpiresInMinutes": 60,
+	})
+	require.NoError(t, err)
+	msg.To = "alice@example.com"
+	require.NoError(t, m.Send(msg))
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(<-server.received))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "パスワードの再設定", subject)
+	assert.Equal(t, "alice@example.com", parsed.Header.Get("To"))
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var contentTypes []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		contentTypes = append(contentTypes, part.Header.Get("Content-Type"))
+		// multipart.Reader は quoted-printable を自動でデコードする
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "https://example.com/reset-password?token=abc")
+	}
+	assert.Equal(t, []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, contentTypes)
+
+	// 一時エラーはそのまま返すので、呼び出し側で再送できる
+	server.rejectRcpt = true
+	assert.Error(t, m.Send(msg))
+}
+
+func TestNewSMTPMailer(t *testing.T) {
+	_, err := mail.NewSMTPMailer("", "587", "", "", "noreply@example.com")
+	assert.Error(t, err)
+
+	_, err = mail.NewSMTPMailer("smtp.example.com", "", "", "", "not an address")
+	assert.Error(t, err)
+
+	_, err = mail.NewSMTPMailer("smtp.example.com", "", "user", "pass", "gityard <noreply@example.com>")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+// テンプレートは言語ごとに templates/<言語>/<名前>.txt と .html に置く。
+// 件名は .txt の中で {{define "subject"}} として定義する。
+//
+//go:embed templates
+var templates embed.FS
+
+type Template string
+
+const (
+	EmailVerificationTemplate    Template = "email_verification"
+	PasswordResetTemplate        Template = "password_reset"
+	PasswordChangedTemplate      Template = "password_changed"
+	RepositoryInvitationTemplate Template = "repository_invitation"
+	AccountDeletionTemplate      Template = "account_deletion_scheduled"
+)
+
+// Templates は用意しているすべてのテンプレートです。
+var Templates = []Template{
+	EmailVerificationTemplate,
+	PasswordResetTemplate,
+	PasswordChangedTemplate,
+	RepositoryInvitationTemplate,
+	AccountDeletionTemplate,
+}
+
+// 対応している言語。先頭が既定
+const (
+	LocaleEnglish  = "en"
+	LocaleJapanese = "ja"
+)
+
+var Locales = []string{LocaleEnglish, LocaleJapanese}
+
+var localeMatcher = language.NewMatcher([]language.Tag{language.English, language.Japanese})
+
+// MatchLocale は Accept-Language ヘッダーから対応している言語を選びます。選べなければ既定の言語を返します。
+func MatchLocale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Locales[0]
+	}
+	_, index, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return Locales[0]
+	}
+	return Locales[index]
+}
+
+// normalizeLocale は対応していない言語を既定の言語にします。
+func normalizeLocale(locale string) string {
+	for _, l := range Locales {
+		if l == locale {
+			return l
+		}
+	}
+	return Locales[0]
+}
+
+// Render はテンプレートからメールを作ります。宛先は呼び出し側で設定してください。
+func Render(name Template, locale string, data any) (*Message, error) {
+	locale = normalizeLocale(locale)
+	base := path.Join("templates", locale, string(name))
+
+	text, err := texttemplate.ParseFS(templates, base+".txt")
+	if err != nil {
+		return nil, err
+	}
+	var subject, textBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.ParseFS(templates, base+".html")
+	if err != nil {
+		return nil, err
+	}
+	var htmlBody bytes.Buffer
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimLeft(textBody.String(), "\n"),
+		HTML:    htmlBody.String(),
+	}, nil
+}
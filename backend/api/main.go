@@ -1,22 +1,107 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"gityard-api/config"
 	"gityard-api/database"
+	"gityard-api/handler"
+	"gityard-api/mail"
+	"gityard-api/middleware"
 	"gityard-api/router"
+	"gityard-api/security"
+	"gityard-api/service"
+	"gityard-api/service/repository"
+	"gityard-api/storage"
 	"log"
+	"os"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	flag.Parse()
+
+	// 設定は起動時に一度だけ読み込み、足りない値や不正な値があれば起動しない
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal("failed to load config: ", err)
+	}
+
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	//logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		// git push のpackは大きくなるので、上限を超えたボディはストリームで受け取る
+		StreamRequestBody: true,
+	})
 	//app.Use(slogfiber.New(logger))
 	app.Use(cors.New())
 
-	database.ConnectDB()
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// スキーマの変更はコードと一緒にリリースするので、古いスキーマのままでは起動しない
+	if err := database.EnsureMigrated(db); err != nil {
+		log.Fatal("failed to check database schema: ", err)
+	}
+
+	repositories, err := storage.NewRepositoryStorage(cfg.Storage.GitRoot)
+	if err != nil {
+		log.Fatal("failed to setup repository storage: ", err)
+	}
+
+	blobs, err := storage.NewBlobStore(cfg.Storage.Blob, cfg.Storage.BlobRoot)
+	if err != nil {
+		log.Fatal("failed to setup blob storage: ", err)
+	}
+
+	mailer, err := mail.NewMailer(cfg.Mail)
+	if err != nil {
+		log.Fatal("failed to setup mailer: ", err)
+	}
+
+	if err := security.Setup(cfg); err != nil {
+		log.Fatal("failed to setup signing keys: ", err)
+	}
+
+	// サービスは依存するストアやストレージを受け取って作る
+	store := repository.NewStore(db)
+	tokens := service.NewTokenService(store.TokenServiceStore())
+	accounts := service.NewAccountService(store.AccountServiceStore(), repositories, blobs, cfg)
+	mails := service.NewMailService(store, mailer, cfg)
+
+	h := &handler.Handler{
+		Auth:          service.NewAuthService(store.AuthServiceStore(), blobs, cfg),
+		TwoFactor:     service.NewTwoFactorService(store.TwoFactorServiceStore(), cfg),
+		Tokens:        tokens,
+		Keys:          service.NewKeyService(store.KeyServiceStore()),
+		Accounts:      accounts,
+		Organizations: service.NewOrganizationService(store.OrganizationServiceStore(), blobs),
+		Repos:         service.NewRepoService(store.RepoServiceStore(), repositories, cfg),
+		Repositories:  repositories,
+		Cookie:        cfg.Cookie,
+		Git:           cfg.Git,
+	}
+	m := &middleware.Middleware{
+		Tokens:           tokens,
+		InternalAPIToken: cfg.InternalAPIToken,
+	}
+
+	// メールはoutboxに積まれるので、送信は別のgoroutineで行う
+	go mails.RunMailOutboxWorker(context.Background())
+	go accounts.RunAccountDeletionWorker(context.Background())
 
-	router.SetupRoutes(app)
-	log.Fatal(app.Listen(":8000"))
+	router.SetupRoutes(app, h, m)
+	log.Fatal(app.Listen(cfg.ListenAddr))
 }
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"gityard-api/security"
+	"gityard-api/service"
+	"log/slog"
 	"strings"
 )
 
@@ -22,7 +25,7 @@ func WithoutAuthInfoProtection(c *fiber.Ctx) error {
 	return c.Next()
 }
 
-func AuthHeaderProtection(c *fiber.Ctx) error {
+func (m *Middleware) AuthHeaderProtection(c *fiber.Ctx) error {
 	// 1. "Authorization"ヘッダーを取得
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
@@ -40,14 +43,84 @@ func AuthHeaderProtection(c *fiber.Ctx) error {
 	}
 	accessToken := parts[1] // [0] == "Bearer"
 
-	// 3. トークンを検証
-	userId, ok := security.VerifyAccessToken(accessToken)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "invalid access_token"})
+	// パーソナルアクセストークンはスコープの範囲でだけ使える
+	if security.IsPersonalAccessToken(accessToken) {
+		return m.personalAccessTokenProtection(c, accessToken)
+	}
+
+	// 3. トークンを検証。ログアウトやパスワードの変更で失効したものも拒否する
+	claims, err := m.Tokens.AuthenticateAccessToken(accessToken)
+	if err != nil {
+		var invalidErr *service.ErrInvalidAccessTokenProvided
+		if errors.As(err, &invalidErr) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "invalid access_token"})
+		}
+		var revokedErr *service.ErrRevokedAccessTokenProvided
+		if errors.As(err, &revokedErr) {
+			slog.Info("request rejected", "reason", "revoked access token", "userId", revokedErr.UserId)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "invalid access_token"})
+		}
+		slog.Error("failed to authenticate access token", "detail", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "internal error"})
 	}
 
 	// 4. 情報取り出す
+	c.Locals("user_id", claims.UserId)
+	c.Locals("session_id", claims.SessionId)
+	c.Locals("token_id", claims.TokenId)
+	c.Locals("token_expires_at", claims.ExpiresAt)
+
+	return c.Next()
+}
+
+// OptionalAuthHeaderProtection は Authorization ヘッダーがあれば検証して user_id を設定します。
+// ヘッダーがない場合は未ログインのまま次へ進みます。
+func (m *Middleware) OptionalAuthHeaderProtection(c *fiber.Ctx) error {
+	if c.Get("Authorization") == "" {
+		return c.Next()
+	}
+	return m.AuthHeaderProtection(c)
+}
+
+func (m *Middleware) personalAccessTokenProtection(c *fiber.Ctx, token string) error {
+	userId, scopes, err := m.Tokens.AuthenticatePersonalAccessToken(token)
+	if err != nil {
+		var invalidErr *service.ErrInvalidAccessTokenProvided
+		var expiredErr *service.ErrExpiredAccessTokenProvided
+		if errors.As(err, &invalidErr) || errors.As(err, &expiredErr) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "invalid access_token"})
+		}
+		slog.Error("failed to authenticate personal access token", "detail", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "internal error"})
+	}
+
 	c.Locals("user_id", userId)
+	c.Locals("token_scopes", scopes)
+
+	return c.Next()
+}
 
+// RequireScope はパーソナルアクセストークンで呼ばれた場合に、トークンが scope を持っているかを確認します。
+// ログインで発行したアクセストークンは全ての操作ができるので確認しません。
+func RequireScope(scope security.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals("token_scopes").([]security.Scope)
+		if ok && !security.HasScope(scopes, scope) {
+			slog.Warn("request rejected", "reason", "insufficient scope", "requiredScope", scope, "path", c.Path())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "insufficient scope",
+				"scope":   scope,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// SessionTokenRequired はログインで発行したアクセストークンだけを通します。
+// トークンやセッションの管理をパーソナルアクセストークンからできないようにするためです。
+func SessionTokenRequired(c *fiber.Ctx) error {
+	if _, ok := c.Locals("token_scopes").([]security.Scope); ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "personal access token is not allowed"})
+	}
 	return c.Next()
 }
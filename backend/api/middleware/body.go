@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestBodyLimit はリクエストボディの大きさを制限します。
+// git の push を受け付けるためにボディをストリームで受け取る設定にしているので、
+// それ以外のAPIではここでボディを読み切って上限を確認する。
+func RequestBodyLimit(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stream := c.Context().RequestBodyStream()
+		if stream == nil { // 上限以下のボディは既に読み込まれている
+			return c.Next()
+		}
+
+		body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "bad request"})
+		}
+		if len(body) > limit {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": "request entity too large"})
+		}
+		c.Request().SetBody(body)
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InternalServiceProtection はgityard-sshなど内部サービスからの呼び出しだけを通します。
+// 共有トークン INTERNAL_API_TOKEN を Authorization: Bearer で受け取ります。
+func (m *Middleware) InternalServiceProtection(c *fiber.Ctx) error {
+	expected := m.InternalAPIToken
+	if expected == "" {
+		// 未設定のまま公開しないよう、設定されるまで全て拒否する
+		slog.Error("internal api called, but INTERNAL_API_TOKEN is not set")
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"message": "internal api disabled"})
+	}
+
+	token, found := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		slog.Warn("internal api rejected", "reason", "invalid service token", "ip", c.IP())
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,10 @@
+package middleware
+
+import "gityard-api/service"
+
+// Middleware は認証が必要なミドルウェアです。使うサービスは main で作成して渡します。
+type Middleware struct {
+	Tokens *service.TokenService
+	// InternalAPIToken は内部APIの共有トークンです。
+	InternalAPIToken string
+}
@@ -3,10 +3,13 @@ package model
 import "time"
 
 // Handlename はシステム内でユニークなハンドルネームを管理します。
+// 改名するとアカウントには新しい行を割り当て、改名前の行はしばらく新しい名前への転送に使います。
 type Handlename struct {
-	ID         uint      `gorm:"column:id;primaryKey"                                                                   json:"id"`
-	Handlename string    `gorm:"column:handlename;type:varchar(255);not null;uniqueIndex:uq_idx_handlenames_handlename" json:"handlename"`
-	CreatedAt  time.Time `gorm:"column:created_at;default:current_timestamp(3)"                                         json:"created_at"`
+	ID                uint       `gorm:"column:id;primaryKey"                                                                   json:"id"`
+	Handlename        string     `gorm:"column:handlename;type:varchar(255);not null;uniqueIndex:uq_idx_handlenames_handlename" json:"handlename"`
+	RedirectAccountID *uint      `gorm:"column:redirect_account_id;index:idx_handlenames_redirect_account_id"                   json:"redirect_account_id"` // 改名前のハンドルネームなら改名したアカウント
+	RedirectExpiresAt *time.Time `gorm:"column:redirect_expires_at"                                                             json:"redirect_expires_at"` // 過ぎたら転送をやめて他のアカウントが使えるようにする
+	CreatedAt         time.Time  `gorm:"column:created_at;default:current_timestamp"                                            json:"created_at"`
 }
 
 func (Handlename) TableName() string {
@@ -15,19 +18,21 @@ func (Handlename) TableName() string {
 
 // Account はユーザーに紐づくアカウント（個人・組織）を表します。
 type Account struct {
-	ID           uint      `gorm:"column:id;primaryKey"                                                         json:"id"`
-	UserID       uint      `gorm:"column:user_id;not null"                                                      json:"user_id"`
-	HandlenameID *uint     `gorm:"column:handlename_id;uniqueIndex:uq_idx_accounts_handlename_id"               json:"handlename_id"` // 退会時にNULLになるためポインタ型
-	Kind         int       `gorm:"column:kind;type:smallint;not null;default:1"                                 json:"kind"`
-	IsDeleted    bool      `gorm:"column:is_deleted;type:tinyint(1);not null;default:0"                         json:"is_deleted"`
-	CreatedAt    time.Time `gorm:"column:created_at;default:current_timestamp(3)"                               json:"created_at"`
-	UpdatedAt    time.Time `gorm:"column:updated_at;default:current_timestamp(3);onUpdate:current_timestamp(3)" json:"updated_at"`
+	ID           uint      `gorm:"column:id;primaryKey"                                                   json:"id"`
+	UserID       *uint     `gorm:"column:user_id"                                                         json:"user_id"`       // 組織アカウントは特定のユーザに属さないためポインタ型
+	HandlenameID *uint     `gorm:"column:handlename_id;uniqueIndex:uq_idx_accounts_handlename_id"         json:"handlename_id"` // 退会時にNULLになるためポインタ型
+	Kind         int       `gorm:"column:kind;type:smallint;not null;default:1"                           json:"kind"`
+	IsDeleted    bool      `gorm:"column:is_deleted;type:tinyint(1);not null;default:0"                   json:"is_deleted"`
+	CreatedAt    time.Time `gorm:"column:created_at;default:current_timestamp"                            json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp" json:"updated_at"`
 
 	// リレーションシップ
-	User           User           `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
-	Handlename     Handlename     `gorm:"foreignKey:HandlenameID;constraint:OnDelete:RESTRICT"`
-	AccountProfile AccountProfile `gorm:"foreignKey:AccountID"`
-	Repositories   []Repository   `gorm:"foreignKey:OwnerAccountID"`
+	User           User                 `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
+	Handlename     Handlename           `gorm:"foreignKey:HandlenameID;constraint:OnDelete:RESTRICT"`
+	AccountProfile AccountProfile       `gorm:"foreignKey:AccountID"`
+	Repositories   []Repository         `gorm:"foreignKey:OwnerAccountID"`
+	Members        []OrganizationMember `gorm:"foreignKey:OrganizationAccountID"`
+	Organization   *Organization        `gorm:"foreignKey:AccountID"` // 組織アカウントの場合だけ
 }
 
 func (Account) TableName() string {
@@ -40,14 +45,17 @@ type AccountProfile struct {
 	Displayname string    `gorm:"column:displayname;type:varchar(255);not null;default:'unknown';index:idx_account_profiles_displayname" json:"displayname"`
 	Iconpath    string    `gorm:"column:iconpath;type:varchar(255);not null;default:'noimage001'"                                        json:"icon_path"`
 	IsPrivate   bool      `gorm:"column:is_private;type:tinyint(1);not null;default:0"                                                   json:"is_private"`
-	CreatedAt   time.Time `gorm:"column:created_at;default:current_timestamp(3)"                                                         json:"created_at"`
-	UpdatedAt   time.Time `gorm:"column:updated_at;default:current_timestamp(3);onUpdate:current_timestamp(3)"                           json:"updated_at"`
+	CreatedAt   time.Time `gorm:"column:created_at;default:current_timestamp"                                                            json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp"                                 json:"updated_at"`
 }
 
 func (AccountProfile) TableName() string {
 	return "account_profiles"
 }
 
+// DefaultIconpath はアバター画像を設定していないアカウントの Iconpath です。
+const DefaultIconpath = "noimage001"
+
 type AccountKind int
 
 const (
@@ -0,0 +1,85 @@
+package model
+
+import "time"
+
+// RepositoryCollaborator はリポジトリの所有者以外に与えたアクセス権を表します。
+type RepositoryCollaborator struct {
+	RepositoryID uint      `gorm:"column:repository_id;primaryKey;autoIncrement:false"                                      json:"repository_id"`
+	UserID       uint      `gorm:"column:user_id;primaryKey;autoIncrement:false;index:idx_repository_collaborators_user_id" json:"user_id"`
+	Role         int       `gorm:"column:role;type:smallint;not null"                                                       json:"role"`
+	CreatedAt    time.Time `gorm:"column:created_at;default:current_timestamp"                                              json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp"                   json:"updated_at"`
+
+	// リレーションシップ
+	Repository Repository `gorm:"foreignKey:RepositoryID;constraint:OnDelete:CASCADE"`
+	User       User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (RepositoryCollaborator) TableName() string {
+	return "repository_collaborators"
+}
+
+// RepositoryInvitation はコラボレーターへの招待を表します。承認されるまでアクセス権は与えません。
+type RepositoryInvitation struct {
+	ID            uint      `gorm:"column:id;primaryKey"                                                             json:"id"`
+	RepositoryID  uint      `gorm:"column:repository_id;not null;index:idx_repository_invitations_repository_id"     json:"repository_id"`
+	InviteeUserID uint      `gorm:"column:invitee_user_id;not null;index:idx_repository_invitations_invitee_user_id" json:"invitee_user_id"`
+	InviterUserID uint      `gorm:"column:inviter_user_id;not null"                                                  json:"inviter_user_id"`
+	Role          int       `gorm:"column:role;type:smallint;not null"                                               json:"role"`
+	Status        int       `gorm:"column:status;type:smallint;not null;default:1"                                   json:"status"`
+	CreatedAt     time.Time `gorm:"column:created_at;default:current_timestamp"                                      json:"created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp"           json:"updated_at"`
+
+	// リレーションシップ
+	Repository  Repository `gorm:"foreignKey:RepositoryID;constraint:OnDelete:CASCADE"`
+	InviteeUser User       `gorm:"foreignKey:InviteeUserID;constraint:OnDelete:CASCADE"`
+	InviterUser User       `gorm:"foreignKey:InviterUserID;constraint:OnDelete:CASCADE"`
+}
+
+func (RepositoryInvitation) TableName() string {
+	return "repository_invitations"
+}
+
+// RepositoryRole はリポジトリに対する役割です。大きいほど強い権限を持ちます。
+type RepositoryRole int
+
+const (
+	RepositoryRoleRead RepositoryRole = iota + 1
+	RepositoryRoleTriage
+	RepositoryRoleWrite
+	RepositoryRoleMaintain
+	RepositoryRoleAdmin
+)
+
+var repositoryRoleNames = map[RepositoryRole]string{
+	RepositoryRoleRead:     "read",
+	RepositoryRoleTriage:   "triage",
+	RepositoryRoleWrite:    "write",
+	RepositoryRoleMaintain: "maintain",
+	RepositoryRoleAdmin:    "admin",
+}
+
+func (r RepositoryRole) String() string {
+	if name, ok := repositoryRoleNames[r]; ok {
+		return name
+	}
+	return "none"
+}
+
+// ParseRepositoryRole は "read" などの役割名を RepositoryRole に変換します。
+func ParseRepositoryRole(name string) (RepositoryRole, bool) {
+	for role, roleName := range repositoryRoleNames {
+		if roleName == name {
+			return role, true
+		}
+	}
+	return 0, false
+}
+
+type InvitationStatus int
+
+const (
+	InvitationPending InvitationStatus = iota + 1
+	InvitationAccepted
+	InvitationDeclined
+)
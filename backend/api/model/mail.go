@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// MailOutbox は送信待ちのメールです。業務の変更と同じトランザクションで書き込み、ワーカーが取り出して送信します。
+// 送信できた行は削除し、再送の上限に達した行は FailedAt を付けて残します。
+type MailOutbox struct {
+	ID            uint       `gorm:"column:id;primaryKey"                                                  json:"id"`
+	ToAddress     string     `gorm:"column:to_address;type:varchar(255);not null"                          json:"to_address"`
+	Subject       string     `gorm:"column:subject;type:varchar(1000);not null"                            json:"subject"`
+	TextBody      string     `gorm:"column:text_body;type:text;not null"                                   json:"text_body"`
+	HTMLBody      string     `gorm:"column:html_body;type:text;not null"                                   json:"html_body"`
+	Attempts      int        `gorm:"column:attempts;not null;default:0"                                    json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;not null;index:idx_mail_outbox_next_attempt_at" json:"next_attempt_at"`
+	LastError     string     `gorm:"column:last_error;type:varchar(1000);not null;default:''"              json:"last_error"`
+	FailedAt      *time.Time `gorm:"column:failed_at"                                                      json:"failed_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at;default:current_timestamp"                           json:"created_at"`
+}
+
+func (MailOutbox) TableName() string {
+	return "mail_outbox"
+}
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// OrganizationMember は組織アカウントに所属するユーザを表します。
+type OrganizationMember struct {
+	OrganizationAccountID uint      `gorm:"column:organization_account_id;primaryKey;autoIncrement:false"                        json:"organization_account_id"`
+	UserID                uint      `gorm:"column:user_id;primaryKey;autoIncrement:false;index:idx_organization_members_user_id" json:"user_id"`
+	Role                  int       `gorm:"column:role;type:smallint;not null;default:1"                                         json:"role"`
+	CreatedAt             time.Time `gorm:"column:created_at;default:current_timestamp"                                          json:"created_at"`
+	UpdatedAt             time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp"               json:"updated_at"`
+
+	// リレーションシップ
+	OrganizationAccount Account `gorm:"foreignKey:OrganizationAccountID;constraint:OnDelete:CASCADE"`
+	User                User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+type OrganizationRole int
+
+const (
+	OrganizationRoleMember OrganizationRole = iota + 1
+	OrganizationRoleOwner
+)
+
+func (r OrganizationRole) String() string {
+	switch r {
+	case OrganizationRoleMember:
+		return "member"
+	case OrganizationRoleOwner:
+		return "owner"
+	default:
+		return "none"
+	}
+}
+
+// ParseOrganizationRole は "member" などの役割名を OrganizationRole に変換します。
+func ParseOrganizationRole(name string) (OrganizationRole, bool) {
+	switch name {
+	case "member":
+		return OrganizationRoleMember, true
+	case "owner":
+		return OrganizationRoleOwner, true
+	default:
+		return 0, false
+	}
+}
+
+// Organization は組織アカウントの設定を表します。
+type Organization struct {
+	AccountID uint      `gorm:"column:account_id;primaryKey;autoIncrement:false"                       json:"account_id"`
+	BaseRole  int       `gorm:"column:base_role;type:smallint;not null;default:1"                      json:"base_role"` // メンバー全員が組織のリポジトリに持つ役割。0 は権限なし
+	CreatedAt time.Time `gorm:"column:created_at;default:current_timestamp"                            json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp" json:"updated_at"`
+
+	// リレーションシップ
+	Account Account `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
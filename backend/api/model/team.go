@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Team は組織のメンバーをまとめたグループです。親チームを持つ子チームは親チームのアクセス権を引き継ぎます。
+type Team struct {
+	ID                    uint      `gorm:"column:id;primaryKey"                                                                                         json:"id"`
+	OrganizationAccountID uint      `gorm:"column:organization_account_id;not null;uniqueIndex:uq_idx_teams_organization_account_id_and_name,priority:1" json:"organization_account_id"`
+	ParentTeamID          *uint     `gorm:"column:parent_team_id;index:idx_teams_parent_team_id"                                                         json:"parent_team_id"` // 親チームがなければNULL
+	Name                  string    `gorm:"column:name;type:varchar(100);not null;uniqueIndex:uq_idx_teams_organization_account_id_and_name,priority:2"  json:"name"`
+	Description           string    `gorm:"column:description;type:varchar(255);not null;default:''"                                                     json:"description"`
+	CreatedAt             time.Time `gorm:"column:created_at;default:current_timestamp"                                                                  json:"created_at"`
+	UpdatedAt             time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp"                                       json:"updated_at"`
+
+	// リレーションシップ
+	OrganizationAccount Account `gorm:"foreignKey:OrganizationAccountID;constraint:OnDelete:CASCADE"`
+	ParentTeam          *Team   `gorm:"foreignKey:ParentTeamID;constraint:OnDelete:SET NULL"`
+}
+
+func (Team) TableName() string {
+	return "teams"
+}
+
+// TeamMember はチームに所属するユーザを表します。
+type TeamMember struct {
+	TeamID    uint      `gorm:"column:team_id;primaryKey;autoIncrement:false"                                json:"team_id"`
+	UserID    uint      `gorm:"column:user_id;primaryKey;autoIncrement:false;index:idx_team_members_user_id" json:"user_id"`
+	CreatedAt time.Time `gorm:"column:created_at;default:current_timestamp"                                  json:"created_at"`
+
+	// リレーションシップ
+	Team Team `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE"`
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (TeamMember) TableName() string {
+	return "team_members"
+}
+
+// TeamRepository はチームにリポジトリへの役割を与えたことを表します。
+type TeamRepository struct {
+	TeamID       uint      `gorm:"column:team_id;primaryKey;autoIncrement:false"                                                 json:"team_id"`
+	RepositoryID uint      `gorm:"column:repository_id;primaryKey;autoIncrement:false;index:idx_team_repositories_repository_id" json:"repository_id"`
+	Role         int       `gorm:"column:role;type:smallint;not null"                                                            json:"role"`
+	CreatedAt    time.Time `gorm:"column:created_at;default:current_timestamp"                                                   json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp"                        json:"updated_at"`
+
+	// リレーションシップ
+	Team       Team       `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE"`
+	Repository Repository `gorm:"foreignKey:RepositoryID;constraint:OnDelete:CASCADE"`
+}
+
+func (TeamRepository) TableName() string {
+	return "team_repositories"
+}
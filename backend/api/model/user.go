@@ -6,17 +6,21 @@ import (
 
 // User はユーザーの基本情報を表します。認証の主体となります。
 type User struct {
-	ID        uint      `gorm:"column:id;primaryKey"                                                 json:"id"`
-	Email     *string   `gorm:"column:email;type:varchar(255);uniqueIndex:uq_idx_users_email"           json:"email"` // 退会時にNULLになるためポインタ型
-	IsDeleted bool      `gorm:"column:is_deleted;type:tinyint(1);not null;default:0"                         json:"is_deleted"`
-	CreatedAt time.Time `gorm:"column:created_at;default:current_timestamp(3)"                               json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at;default:current_timestamp(3);onUpdate:current_timestamp(3)" json:"updated_at"`
+	ID                  uint       `gorm:"column:id;primaryKey"                                                   json:"id"`
+	Email               *string    `gorm:"column:email;type:varchar(255);uniqueIndex:uq_idx_users_email"          json:"email"` // 退会時にNULLになるためポインタ型
+	IsDeleted           bool       `gorm:"column:is_deleted;type:tinyint(1);not null;default:0"                   json:"is_deleted"`
+	EmailVerifiedAt     *time.Time `gorm:"column:email_verified_at"                                               json:"email_verified_at"`     // 確認メールのリンクを開くまではNULL
+	Locale              string     `gorm:"column:locale;type:varchar(8);not null;default:'en'"                    json:"locale"`                // メールの言語
+	DeletionScheduledAt *time.Time `gorm:"column:deletion_scheduled_at;index:idx_users_deletion_scheduled_at"     json:"deletion_scheduled_at"` // 退会の予定日時。猶予期間中に取り消すとNULLに戻る
+	TokenGeneration     uint       `gorm:"column:token_generation;not null;default:0"                             json:"-"`                     // 増やすとそれまでに発行したアクセストークンがすべて無効になる
+	CreatedAt           time.Time  `gorm:"column:created_at;default:current_timestamp"                            json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp" json:"updated_at"`
 
 	// リレーションシップ
-	UserCredential   UserCredential   `gorm:"foreignKey:UserID"`
-	UserRefreshToken UserRefreshToken `gorm:"foreignKey:UserID"`
-	UserPublicKeys   []UserPublicKey  `gorm:"foreignKey:UserID"`
-	Accounts         []Account        `gorm:"foreignKey:UserID"`
+	UserCredential    UserCredential     `gorm:"foreignKey:UserID"`
+	UserRefreshTokens []UserRefreshToken `gorm:"foreignKey:UserID"`
+	UserPublicKeys    []UserPublicKey    `gorm:"foreignKey:UserID"`
+	Accounts          []Account          `gorm:"foreignKey:UserID"`
 }
 
 func (User) TableName() string {
@@ -25,23 +29,53 @@ func (User) TableName() string {
 
 // UserCredential はユーザーのパスワード情報を分離して管理します。
 type UserCredential struct {
-	UserID         uint      `gorm:"column:user_id;primaryKey;autoIncrement:false"                             json:"user_id"`
-	HashedPassword string    `gorm:"column:hashed_password;type:varchar(255);not null"                                 json:"hashed_password"`
-	CreatedAt      time.Time `gorm:"column:created_at;default:current_timestamp(3)"                               json:"created_at"`
-	UpdatedAt      time.Time `gorm:"column:updated_at;default:current_timestamp(3);onUpdate:current_timestamp(3)" json:"updated_at"`
+	UserID         uint      `gorm:"column:user_id;primaryKey;autoIncrement:false"                          json:"user_id"`
+	HashedPassword string    `gorm:"column:hashed_password;type:varchar(255);not null"                      json:"hashed_password"`
+	CreatedAt      time.Time `gorm:"column:created_at;default:current_timestamp"                            json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp" json:"updated_at"`
 }
 
 func (UserCredential) TableName() string {
 	return "user_credentials"
 }
 
+type VerificationTokenKind int
+
+const (
+	EmailVerificationToken VerificationTokenKind = iota + 1 // メールアドレスの確認
+	PasswordResetToken                                      // パスワードの再設定
+)
+
+// UserVerificationToken はメールで送ったリンクに載せる一度きりのトークンです。トークンはハッシュだけを保存します。
+type UserVerificationToken struct {
+	ID          uint      `gorm:"column:id;primaryKey"                                                                                    json:"id"`
+	UserID      uint      `gorm:"column:user_id;not null;index:idx_user_verification_tokens_user_id"                                      json:"user_id"`
+	Kind        int       `gorm:"column:kind;not null"                                                                                    json:"kind"`
+	HashedToken string    `gorm:"column:hashed_token;type:varchar(255);not null;uniqueIndex:uq_idx_user_verification_tokens_hashed_token" json:"hashed_token"`
+	Email       string    `gorm:"column:email;type:varchar(255);not null"                                                                 json:"email"` // 送り先。メールアドレスが変わったら使えない
+	ExpiresAt   time.Time `gorm:"column:expires_at;not null"                                                                              json:"expires_at"`
+	CreatedAt   time.Time `gorm:"column:created_at;default:current_timestamp"                                                             json:"created_at"`
+
+	// リレーションシップ
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserVerificationToken) TableName() string {
+	return "user_verification_tokens"
+}
+
 // UserRefreshToken はユーザーのリフレッシュトークンを管理します。
+// 1行が1つのログインセッションで、端末ごとに別の行になります。
 type UserRefreshToken struct {
-	UserID             uint      `gorm:"column:user_id;primaryKey;autoIncrement:false"                                           json:"user_id"`
+	ID                 uint      `gorm:"column:id;primaryKey"                                                                                 json:"id"`
+	UserID             uint      `gorm:"column:user_id;not null;index:idx_user_refresh_tokens_user_id"                                        json:"user_id"`
 	HashedRefreshToken string    `gorm:"column:hashed_refresh_token;type:varchar(255);not null;uniqueIndex:uq_idx_users_hashed_refresh_token" json:"hashed_refresh_token"`
-	ExpiresAt          time.Time `gorm:"column:expires_at;not null"                                                                 json:"expires_at"`
-	CreatedAt          time.Time `gorm:"column:created_at;default:current_timestamp(3)"                                             json:"created_at"`
-	UpdatedAt          time.Time `gorm:"column:updated_at;default:current_timestamp(3);onUpdate:current_timestamp(3)"               json:"updated_at"`
+	UserAgent          string    `gorm:"column:user_agent;type:varchar(255);not null;default:''"                                              json:"user_agent"`
+	IPAddress          string    `gorm:"column:ip_address;type:varchar(45);not null;default:''"                                               json:"ip_address"`
+	ExpiresAt          time.Time `gorm:"column:expires_at;not null"                                                                           json:"expires_at"`
+	LastUsedAt         time.Time `gorm:"column:last_used_at;default:current_timestamp"                                                        json:"last_used_at"`
+	CreatedAt          time.Time `gorm:"column:created_at;default:current_timestamp"                                                          json:"created_at"`
+	UpdatedAt          time.Time `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp"                               json:"updated_at"`
 }
 
 func (UserRefreshToken) TableName() string {
@@ -50,17 +84,169 @@ func (UserRefreshToken) TableName() string {
 
 // UserPublicKey はアカウントに紐づくSSH公開鍵を表します。
 type UserPublicKey struct {
-	ID          uint      `gorm:"column:id;primaryKey"                                                                                                            json:"id"`
-	UserID      uint      `gorm:"column:user_id;not null;uniqueIndex:idx_user_id_fingerprint,priority:1"                                                               json:"user_id"`
-	Name        string    `gorm:"column:name;type:varchar(255);not null"                                                                                            json:"name"`
-	FullKeyText string    `gorm:"column:fullkeytext;type:text;not null"                                                                                                    json:"fullkeytext"`
-	Algorithm   string    `gorm:"column:algorithm;type:varchar(50);not null"                                                                                             json:"algorithm"`
-	Keybody     string    `gorm:"column:keybody;type:text;not null"                                                                                                    json:"keybody"`
-	Comment     string    `gorm:"column:comment;type:varchar(255);not null"                                                                                            json:"comment"`
-	Fingerprint string    `gorm:"column:fingerprint;type:varchar(255);not null;uniqueIndex:idx_user_id_fingerprint,priority:2;index:idx_user_publickeys_fingerprint" json:"fingerprint"`
-	CreatedAt   time.Time `gorm:"column:created_at;default:current_timestamp(3)"                                                                                          json:"created_at"`
+	ID          uint      `gorm:"column:id;primaryKey"                                                                                                                                  json:"id"`
+	UserID      uint      `gorm:"column:user_id;not null;uniqueIndex:uq_idx_user_publickeys_user_id_fingerprint,priority:1"                                                             json:"user_id"`
+	Name        string    `gorm:"column:name;type:varchar(255);not null"                                                                                                                json:"name"`
+	FullKeyText string    `gorm:"column:fullkeytext;type:text;not null"                                                                                                                 json:"fullkeytext"`
+	Algorithm   string    `gorm:"column:algorithm;type:varchar(50);not null"                                                                                                            json:"algorithm"`
+	Keybody     string    `gorm:"column:keybody;type:text;not null"                                                                                                                     json:"keybody"`
+	Comment     string    `gorm:"column:comment;type:varchar(255);not null"                                                                                                             json:"comment"`
+	Fingerprint string    `gorm:"column:fingerprint;type:varchar(255);not null;uniqueIndex:uq_idx_user_publickeys_user_id_fingerprint,priority:2;index:idx_user_publickeys_fingerprint" json:"fingerprint"`
+	CreatedAt   time.Time `gorm:"column:created_at;default:current_timestamp"                                                                                                           json:"created_at"`
 }
 
 func (UserPublicKey) TableName() string {
 	return "user_publickeys"
 }
+
+// UserRotatedRefreshToken はローテーションで無効にしたリフレッシュトークンを記録します。
+// 無効にしたトークンが再び使われたら盗まれたとみなし、同じセッションのトークンをすべて失効させます。
+type UserRotatedRefreshToken struct {
+	HashedRefreshToken string    `gorm:"column:hashed_refresh_token;type:varchar(255);primaryKey"                                json:"hashed_refresh_token"`
+	RefreshTokenID     uint      `gorm:"column:refresh_token_id;not null;index:idx_user_rotated_refresh_tokens_refresh_token_id" json:"refresh_token_id"`
+	ExpiresAt          time.Time `gorm:"column:expires_at;not null"                                                              json:"expires_at"`
+	CreatedAt          time.Time `gorm:"column:created_at;default:current_timestamp"                                             json:"created_at"`
+
+	// リレーションシップ
+	RefreshToken UserRefreshToken `gorm:"foreignKey:RefreshTokenID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserRotatedRefreshToken) TableName() string {
+	return "user_rotated_refresh_tokens"
+}
+
+// RevokedAccessToken はログアウトで失効させたアクセストークンです。
+// アクセストークンはDBに保存しないので、期限が切れるまでの間だけ jti を記録して拒否します。
+type RevokedAccessToken struct {
+	JTI       string    `gorm:"column:jti;type:varchar(64);primaryKey"                           json:"jti"`
+	UserID    uint      `gorm:"column:user_id;not null"                                          json:"user_id"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null;index:idx_revoked_access_tokens_expires_at" json:"expires_at"`
+	CreatedAt time.Time `gorm:"column:created_at;default:current_timestamp"                      json:"created_at"`
+}
+
+func (RevokedAccessToken) TableName() string {
+	return "revoked_access_tokens"
+}
+
+// UserAccessToken はスクリプトやCIから使うパーソナルアクセストークンを表します。トークンはハッシュだけを保存します。
+type UserAccessToken struct {
+	ID          uint       `gorm:"column:id;primaryKey"                                                                              json:"id"`
+	UserID      uint       `gorm:"column:user_id;not null;index:idx_user_access_tokens_user_id"                                      json:"user_id"`
+	Name        string     `gorm:"column:name;type:varchar(255);not null"                                                            json:"name"`
+	HashedToken string     `gorm:"column:hashed_token;type:varchar(255);not null;uniqueIndex:uq_idx_user_access_tokens_hashed_token" json:"hashed_token"`
+	Scopes      string     `gorm:"column:scopes;type:varchar(255);not null"                                                          json:"scopes"`     // 空白区切り
+	ExpiresAt   *time.Time `gorm:"column:expires_at"                                                                                 json:"expires_at"` // 無期限ならNULL
+	LastUsedAt  *time.Time `gorm:"column:last_used_at"                                                                               json:"last_used_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at;default:current_timestamp"                                                       json:"created_at"`
+
+	// リレーションシップ
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserAccessToken) TableName() string {
+	return "user_access_tokens"
+}
+
+// UserTwoFactor はユーザのTOTPによる二要素認証の設定です。
+// 登録を始めた時点で行を作り、確認コードを検証できたら有効にします。
+type UserTwoFactor struct {
+	UserID       uint       `gorm:"column:user_id;primaryKey;autoIncrement:false"                          json:"user_id"`
+	Secret       string     `gorm:"column:secret;type:varchar(64);not null"                                json:"-"` // コードの検証に使うため平文で保存する
+	IsEnabled    bool       `gorm:"column:is_enabled;type:tinyint(1);not null;default:0"                   json:"is_enabled"`
+	LastUsedStep int64      `gorm:"column:last_used_step;not null;default:0"                               json:"last_used_step"` // 同じコードの再利用を防ぐ
+	EnabledAt    *time.Time `gorm:"column:enabled_at"                                                      json:"enabled_at"`
+	CreatedAt    time.Time  `gorm:"column:created_at;default:current_timestamp"                            json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;default:current_timestamp;onUpdate:current_timestamp" json:"updated_at"`
+
+	// リレーションシップ
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserTwoFactor) TableName() string {
+	return "user_two_factors"
+}
+
+// UserRecoveryCode はTOTPを使えないときに1回だけ使えるリカバリーコードです。コードはハッシュだけを保存します。
+type UserRecoveryCode struct {
+	ID         uint       `gorm:"column:id;primaryKey"                                          json:"id"`
+	UserID     uint       `gorm:"column:user_id;not null;index:idx_user_recovery_codes_user_id" json:"user_id"`
+	HashedCode string     `gorm:"column:hashed_code;type:varchar(255);not null"                 json:"hashed_code"`
+	UsedAt     *time.Time `gorm:"column:used_at"                                                json:"used_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at;default:current_timestamp"                   json:"created_at"`
+
+	// リレーションシップ
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+// UserTwoFactorChallenge はパスワードを確認済みで、二要素認証のコードを待っているログインです。
+// チャレンジトークンはハッシュだけを保存します。
+type UserTwoFactorChallenge struct {
+	ID             uint   `gorm:"column:id;primaryKey"                                                                                      json:"id"`
+	UserID         uint   `gorm:"column:user_id;not null;index:idx_user_two_factor_challenges_user_id"                                      json:"user_id"`
+	HashedToken    string `gorm:"column:hashed_token;type:varchar(255);not null;uniqueIndex:uq_idx_user_two_factor_challenges_hashed_token" json:"hashed_token"`
+	FailedAttempts int    `gorm:"column:failed_attempts;not null;default:0"                                                                 json:"failed_attempts"`
+	// WebAuthnで応答する場合に、検証まで保存しておくセレモニーのセッション
+	WebAuthnSessionData *string   `gorm:"column:webauthn_session_data;type:text"      json:"-"`
+	ExpiresAt           time.Time `gorm:"column:expires_at;not null"                  json:"expires_at"`
+	CreatedAt           time.Time `gorm:"column:created_at;default:current_timestamp" json:"created_at"`
+
+	// リレーションシップ
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserTwoFactorChallenge) TableName() string {
+	return "user_two_factor_challenges"
+}
+
+// UserWebAuthnCredential はユーザが登録したWebAuthnのクレデンシャル(セキュリティキーやパスキー)です。
+type UserWebAuthnCredential struct {
+	ID              uint       `gorm:"column:id;primaryKey"                                                                                       json:"id"`
+	UserID          uint       `gorm:"column:user_id;not null;index:idx_user_webauthn_credentials_user_id"                                        json:"user_id"`
+	Name            string     `gorm:"column:name;type:varchar(255);not null"                                                                     json:"name"`
+	CredentialID    string     `gorm:"column:credential_id;type:varchar(255);not null;uniqueIndex:uq_idx_user_webauthn_credentials_credential_id" json:"credential_id"` // base64url
+	PublicKey       []byte     `gorm:"column:public_key;type:blob;not null"                                                                       json:"-"`             // COSE形式
+	AttestationType string     `gorm:"column:attestation_type;type:varchar(32);not null;default:''"                                               json:"attestation_type"`
+	Transports      string     `gorm:"column:transports;type:varchar(255);not null;default:''"                                                    json:"transports"` // 空白区切り
+	AAGUID          []byte     `gorm:"column:aaguid;type:varbinary(16)"                                                                           json:"aaguid"`
+	Flags           uint8      `gorm:"column:flags;not null;default:0"                                                                            json:"flags"`      // 登録時の認証器データのフラグ
+	SignCount       uint32     `gorm:"column:sign_count;not null;default:0"                                                                       json:"sign_count"` // 署名カウンタ。巻き戻ったらクローンを疑う
+	CloneWarning    bool       `gorm:"column:clone_warning;type:tinyint(1);not null;default:0"                                                    json:"clone_warning"`
+	LastUsedAt      *time.Time `gorm:"column:last_used_at"                                                                                        json:"last_used_at"`
+	CreatedAt       time.Time  `gorm:"column:created_at;default:current_timestamp"                                                                json:"created_at"`
+
+	// リレーションシップ
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserWebAuthnCredential) TableName() string {
+	return "user_webauthn_credentials"
+}
+
+type WebAuthnCeremonyKind int
+
+const (
+	WebAuthnRegistration      WebAuthnCeremonyKind = iota + 1 // クレデンシャルの登録
+	WebAuthnPasswordlessLogin                                 // パスワードレスのログイン
+)
+
+// WebAuthnCeremony は開始してから検証されるまでのWebAuthnのセレモニーです。トークンはハッシュだけを保存します。
+type WebAuthnCeremony struct {
+	ID          uint      `gorm:"column:id;primaryKey"                                                                               json:"id"`
+	UserID      *uint     `gorm:"column:user_id"                                                                                     json:"user_id"` // パスワードレスのログインではまだ分からないのでNULL
+	HashedToken string    `gorm:"column:hashed_token;type:varchar(255);not null;uniqueIndex:uq_idx_webauthn_ceremonies_hashed_token" json:"hashed_token"`
+	Kind        int       `gorm:"column:kind;not null"                                                                               json:"kind"`
+	SessionData string    `gorm:"column:session_data;type:text;not null"                                                             json:"-"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;not null"                                                                         json:"expires_at"`
+	CreatedAt   time.Time `gorm:"column:created_at;default:current_timestamp"                                                        json:"created_at"`
+
+	// リレーションシップ
+	User *User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (WebAuthnCeremony) TableName() string {
+	return "webauthn_ceremonies"
+}
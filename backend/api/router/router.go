@@ -3,27 +3,136 @@ package router
 import (
 	"gityard-api/handler"
 	"gityard-api/middleware"
+	"gityard-api/security"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
-func SetupRoutes(app *fiber.App) {
-	api := app.Group("/api", logger.New())
+func SetupRoutes(app *fiber.App, h *handler.Handler, m *middleware.Middleware) {
+	app.Get("/.well-known/jwks.json", h.JWKS)
+
+	api := app.Group("/api", logger.New(), middleware.RequestBodyLimit(fiber.DefaultBodyLimit))
 	v1 := api.Group("/v1")
 
-	v1.Get("/healthcheck", handler.HealthCheck)
+	v1.Get("/healthcheck", h.HealthCheck)
 
 	auth := v1.Group("/auth")
-	auth.Post("/signup", middleware.WithoutAuthInfoProtection, handler.SignUp)
-	auth.Post("/login", middleware.WithoutAuthInfoProtection, handler.Login)
-	auth.Post("/logout", middleware.AuthHeaderProtection, handler.Logout)
-	auth.Post("/refresh", handler.Refresh) // クッキーの処理はmiddlewareじゃなくて関数内にある
+	auth.Post("/signup", middleware.WithoutAuthInfoProtection, h.SignUp)
+	auth.Post("/login", middleware.WithoutAuthInfoProtection, h.Login)
+	auth.Post("/login/2fa", middleware.WithoutAuthInfoProtection, h.LoginWithTwoFactor)
+	auth.Post("/login/2fa/webauthn", middleware.WithoutAuthInfoProtection, h.BeginTwoFactorWebAuthnLogin)
+	auth.Post("/login/2fa/webauthn/finish", middleware.WithoutAuthInfoProtection, h.FinishTwoFactorWebAuthnLogin)
+	auth.Post("/webauthn", middleware.WithoutAuthInfoProtection, h.BeginPasswordlessLogin)
+	auth.Post("/webauthn/finish", middleware.WithoutAuthInfoProtection, h.FinishPasswordlessLogin)
+	auth.Post("/logout", m.AuthHeaderProtection, middleware.SessionTokenRequired, h.Logout)
+	auth.Post("/refresh", h.Refresh) // クッキーの処理はmiddlewareじゃなくて関数内にある
+	auth.Post("/password/forgot", middleware.WithoutAuthInfoProtection, h.ForgotPassword)
+	auth.Post("/password/reset", middleware.WithoutAuthInfoProtection, h.ResetPassword)
+	auth.Post("/email/verify", h.VerifyEmail) // ログインしていなくてもメールのリンクから確認できる
+
+	// パーソナルアクセストークンで呼べるルートには必要なスコープを付ける
+	repoRead := middleware.RequireScope(security.ScopeRepoRead)
+	repoWrite := middleware.RequireScope(security.ScopeRepoWrite)
+	repoAdmin := middleware.RequireScope(security.ScopeRepoAdmin)
+	keysRead := middleware.RequireScope(security.ScopeKeysRead)
+	keysAdmin := middleware.RequireScope(security.ScopeKeysAdmin)
+	orgRead := middleware.RequireScope(security.ScopeOrgRead)
+	orgAdmin := middleware.RequireScope(security.ScopeOrgAdmin)
 
-	settings := v1.Group("/settings", middleware.AuthHeaderProtection)
+	settings := v1.Group("/settings", m.AuthHeaderProtection)
 	keys := settings.Group("/keys")
 	sshKeys := keys.Group("/ssh")
-	sshKeys.Post("/new", handler.RegisterSSHPublicKey)
-	sshKeys.Get("/list", handler.GetSSHPublicKeys)
-	sshKeys.Post("/delete", handler.DeleteSSHPubkeyByFingerprint)
+	sshKeys.Post("/new", keysAdmin, h.RegisterSSHPublicKey)
+	sshKeys.Get("/list", keysRead, h.GetSSHPublicKeys)
+	sshKeys.Post("/delete", keysAdmin, h.DeleteSSHPubkeyByFingerprint)
+	settings.Get("/orgs", orgRead, h.GetUserOrganizations)
+	settings.Post("/password", middleware.SessionTokenRequired, h.ChangePassword)
+	profile := settings.Group("/profile", middleware.SessionTokenRequired)
+	profile.Patch("/", h.UpdateProfile)
+	profile.Put("/avatar", h.UploadAvatar)
+	profile.Delete("/avatar", h.DeleteAvatar)
+	account := settings.Group("/account", middleware.SessionTokenRequired)
+	account.Get("/deletion", h.GetAccountDeletion)
+	account.Post("/deletion", h.ScheduleAccountDeletion)
+	account.Delete("/deletion", h.CancelAccountDeletion)
+	account.Post("/handlename", h.RenameHandlename)
+	email := settings.Group("/email", middleware.SessionTokenRequired)
+	email.Get("/", h.GetEmail)
+	email.Post("/verification", h.ResendEmailVerification)
+	sessions := settings.Group("/sessions", middleware.SessionTokenRequired)
+	sessions.Get("/", h.GetUserSessions)
+	sessions.Delete("/:id", h.RevokeUserSession)
+	tokens := settings.Group("/tokens", middleware.SessionTokenRequired)
+	tokens.Get("/", h.GetPersonalAccessTokens)
+	tokens.Post("/", h.CreatePersonalAccessToken)
+	tokens.Delete("/:id", h.DeletePersonalAccessToken)
+	twoFactor := settings.Group("/2fa", middleware.SessionTokenRequired)
+	twoFactor.Get("/", h.GetTwoFactorStatus)
+	twoFactor.Post("/", h.EnrollTwoFactor)
+	twoFactor.Delete("/", h.DisableTwoFactor)
+	twoFactor.Post("/confirm", h.ConfirmTwoFactor)
+	twoFactor.Post("/recovery-codes", h.RegenerateRecoveryCodes)
+	webAuthn := settings.Group("/webauthn", middleware.SessionTokenRequired)
+	webAuthn.Get("/", h.GetWebAuthnCredentials)
+	webAuthn.Post("/register", h.BeginWebAuthnRegistration)
+	webAuthn.Post("/register/finish", h.FinishWebAuthnRegistration)
+	webAuthn.Delete("/:id", h.DeleteWebAuthnCredential)
+	invitations := settings.Group("/invitations")
+	invitations.Get("/", repoRead, h.GetReceivedRepositoryInvitations)
+	// 招待を受けるとリポジトリの権限が増えるので、パーソナルアクセストークンでは受けられない
+	invitations.Post("/:id/accept", middleware.SessionTokenRequired, h.AcceptRepositoryInvitation)
+	invitations.Post("/:id/decline", middleware.SessionTokenRequired, h.DeclineRepositoryInvitation)
+
+	users := v1.Group("/users")
+	users.Get("/:handlename", m.OptionalAuthHeaderProtection, h.GetProfile)
+	v1.Get("/avatars/*", h.GetAvatar)
+
+	repos := v1.Group("/repos")
+	repos.Post("/", m.AuthHeaderProtection, repoWrite, h.CreateRepository)
+	repos.Get("/:owner", m.OptionalAuthHeaderProtection, repoRead, h.GetRepositories)
+	repos.Get("/:owner/:name", m.OptionalAuthHeaderProtection, repoRead, h.GetRepository)
+	repos.Patch("/:owner/:name", m.AuthHeaderProtection, repoAdmin, h.UpdateRepository)
+	repos.Delete("/:owner/:name", m.AuthHeaderProtection, repoAdmin, h.DeleteRepository)
+	collaborators := repos.Group("/:owner/:name/collaborators", m.AuthHeaderProtection)
+	collaborators.Get("/", repoRead, h.GetRepositoryCollaborators)
+	collaborators.Post("/", repoAdmin, h.InviteRepositoryCollaborator)
+	collaborators.Patch("/:handlename", repoAdmin, h.UpdateRepositoryCollaborator)
+	collaborators.Delete("/:handlename", repoAdmin, h.RemoveRepositoryCollaborator)
+	repoInvitations := repos.Group("/:owner/:name/invitations", m.AuthHeaderProtection, repoAdmin)
+	repoInvitations.Get("/", h.GetRepositoryInvitations)
+	repoInvitations.Delete("/:id", h.CancelRepositoryInvitation)
+
+	orgs := v1.Group("/orgs")
+	orgs.Post("/", m.AuthHeaderProtection, orgAdmin, h.CreateOrganization)
+	orgs.Get("/:org", h.GetOrganization)
+	orgs.Patch("/:org", m.AuthHeaderProtection, orgAdmin, h.UpdateOrganization)
+	members := orgs.Group("/:org/members", m.AuthHeaderProtection)
+	members.Get("/", orgRead, h.GetOrganizationMembers)
+	members.Put("/:handlename", orgAdmin, h.SetOrganizationMember)
+	members.Delete("/:handlename", orgAdmin, h.RemoveOrganizationMember)
+	teams := orgs.Group("/:org/teams", m.AuthHeaderProtection)
+	teams.Get("/", orgRead, h.GetTeams)
+	teams.Post("/", orgAdmin, h.CreateTeam)
+	teams.Get("/:team", orgRead, h.GetTeam)
+	teams.Patch("/:team", orgAdmin, h.UpdateTeam)
+	teams.Delete("/:team", orgAdmin, h.DeleteTeam)
+	teams.Get("/:team/members", orgRead, h.GetTeamMembers)
+	teams.Put("/:team/members/:handlename", orgAdmin, h.AddTeamMember)
+	teams.Delete("/:team/members/:handlename", orgAdmin, h.RemoveTeamMember)
+	teams.Get("/:team/repos", orgRead, h.GetTeamRepositories)
+	teams.Put("/:team/repos/:name", orgAdmin, h.SetTeamRepository)
+	teams.Delete("/:team/repos/:name", orgAdmin, h.RemoveTeamRepository)
+
+	// gityard-ssh などの内部サービス向け
+	internal := v1.Group("/internal", m.InternalServiceProtection)
+	internal.Get("/keys", h.InternalGetPubkeyOwner)
+	internal.Get("/repos/:owner/:name/access", h.InternalGetRepositoryAccess)
+
+	// git Smart HTTP (https://host/owner/name.git)
+	// グループにするとミドルウェアが /api 以下にもかかってしまうので個別に登録する
+	gitLogger := logger.New()
+	app.Get("/:owner/:name/info/refs", gitLogger, h.GitInfoRefs)
+	app.Post("/:owner/:name/git-upload-pack", gitLogger, h.GitUploadPack)
+	app.Post("/:owner/:name/git-receive-pack", gitLogger, h.GitReceivePack)
 }
@@ -0,0 +1,27 @@
+package security
+
+import "gityard-api/config"
+
+// トークンの署名鍵とWebAuthnの設定。起動時に Setup で設定します
+var (
+	keyring        *Keyring
+	tokenConfig    = config.Default().Token
+	webAuthnConfig = config.Default().WebAuthn
+)
+
+// Setup は読み込んだ設定の署名鍵、トークンの有効期間とWebAuthnのRelying Partyを使うように設定します。
+func Setup(cfg *config.Config) error {
+	k, err := LoadKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	keyring = k
+	tokenConfig = cfg.Token
+	webAuthnConfig = cfg.WebAuthn
+	return nil
+}
+
+// JWKS はアクセストークンの検証に使える公開鍵の一覧を返します。
+func JWKS() JWKSet {
+	return keyring.JWKS()
+}
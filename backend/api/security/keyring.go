@@ -0,0 +1,244 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gityard-api/config"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey はアクセストークンの署名鍵です。kid は公開鍵のJWKサムプリント (RFC 7638) なので、鍵のファイルから毎回同じ値になります。
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer // 引退した鍵では nil
+	public  crypto.PublicKey
+	jwk     JWK
+}
+
+func newSigningKey(public crypto.PublicKey, private crypto.Signer) (*signingKey, error) {
+	key := &signingKey{private: private, public: public}
+	switch pub := public.(type) {
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.jwk = JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub)}
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("ecdsa key must be on the P-256 curve")
+		}
+		point, err := pub.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		xy := point.Bytes()[1:] // 0x04 || X || Y
+		key.method = jwt.SigningMethodES256
+		key.jwk = JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(xy[:32]),
+			Y:   base64.RawURLEncoding.EncodeToString(xy[32:]),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T: use ed25519 or ecdsa P-256", public)
+	}
+
+	// サムプリントは必須のメンバーだけを辞書順に並べたJSONのハッシュ。値はbase64urlなのでエスケープはいらない
+	var members string
+	if key.jwk.Kty == "OKP" {
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, key.jwk.Crv, key.jwk.Kty, key.jwk.X)
+	} else {
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, key.jwk.Crv, key.jwk.Kty, key.jwk.X, key.jwk.Y)
+	}
+	thumbprint := sha256.Sum256([]byte(members))
+	key.id = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	key.jwk.Kid = key.id
+	key.jwk.Alg = key.method.Alg()
+	key.jwk.Use = "sig"
+	return key, nil
+}
+
+// JWK はJWKSで公開する検証用の公開鍵です。
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKSet は /.well-known/jwks.json で返す公開鍵の一覧です。
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Keyring はアクセストークンの署名鍵の一覧です。新しいトークンは現在の鍵で署名し、検証は kid が一致する鍵で行います。
+//
+// 鍵を入れ替えるときは、それまでの鍵を引退した鍵として残しておけば、発行済みのトークンは期限まで使えます。
+// リフレッシュトークンは署名しないので、鍵を入れ替えてもログアウトされることはありません。
+type Keyring struct {
+	active *signingKey
+	keys   map[string]*signingKey
+	jwks   JWKSet
+}
+
+// NewKeyring は active で署名し、active と retired で検証する Keyring を作成します。
+func NewKeyring(active crypto.Signer, retired ...crypto.PublicKey) (*Keyring, error) {
+	activeKey, err := newSigningKey(active.Public(), active)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{active: activeKey, keys: map[string]*signingKey{}}
+	k.add(activeKey)
+	for _, public := range retired {
+		key, err := newSigningKey(public, nil)
+		if err != nil {
+			return nil, err
+		}
+		k.add(key)
+	}
+	return k, nil
+}
+
+func (k *Keyring) add(key *signingKey) {
+	if _, ok := k.keys[key.id]; ok {
+		return // 現在の鍵を引退した鍵にも書いた場合など
+	}
+	k.keys[key.id] = key
+	k.jwks.Keys = append(k.jwks.Keys, key.jwk)
+}
+
+// LoadKeyring は設定の署名鍵のファイルを読み込みます。署名鍵のファイルがなければ SECRET から Ed25519 の鍵を導出します。
+// 導出した鍵は SECRET が同じなら毎回同じなので、再起動しても複数台で動かしても同じトークンを検証できます。
+func LoadKeyring(cfg *config.Config) (*Keyring, error) {
+	var active crypto.Signer
+	if cfg.JWT.SigningKeyFile != "" {
+		private, err := readPrivateKeyFile(cfg.JWT.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		active = private
+	} else {
+		seed, err := hkdf.Key(sha256.New, []byte(cfg.Secret), nil, "gityard access token signing key", ed25519.SeedSize)
+		if err != nil {
+			return nil, err
+		}
+		active = ed25519.NewKeyFromSeed(seed)
+	}
+
+	var retired []crypto.PublicKey
+	for _, path := range cfg.JWT.RetiredKeyFiles {
+		public, err := readPublicKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		retired = append(retired, public)
+	}
+
+	return NewKeyring(active, retired...)
+}
+
+// JWKS は検証に使える公開鍵の一覧を返します。
+func (k *Keyring) JWKS() JWKSet {
+	return k.jwks
+}
+
+// sign は claims に現在の鍵で署名し、kid ヘッダーを付けます。
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.private)
+}
+
+// parse はトークンの kid の鍵で署名を検証します。知らない kid や、鍵と違うアルゴリズムのトークンは拒否します。
+func (k *Keyring) parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodES256.Alg()}))
+}
+
+func readPEMFile(path string) (*pem.Block, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in key file %s", path)
+	}
+	return block, nil
+}
+
+// readPrivateKeyFile はPKCS#8 か SEC 1 (EC PRIVATE KEY) の秘密鍵を読み込みます。
+func readPrivateKeyFile(path string) (crypto.Signer, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKey(path, block)
+}
+
+func parsePrivateKey(path string, block *pem.Block) (crypto.Signer, error) {
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key file %s must be a private key: %s", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type in %s: %T", path, key)
+	}
+	return signer, nil
+}
+
+// readPublicKeyFile は公開鍵 (PUBLIC KEY) を読み込みます。秘密鍵のファイルならその公開鍵を使います。
+func readPublicKeyFile(path string) (crypto.PublicKey, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PUBLIC KEY" {
+		private, err := parsePrivateKey(path, block)
+		if err != nil {
+			return nil, err
+		}
+		return private.Public(), nil
+	}
+
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", path, err)
+	}
+	return public, nil
+}
@@ -0,0 +1,137 @@
+package security_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"gityard-api/config"
+	"gityard-api/security"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// writeKeyFile は秘密鍵をPKCS#8のPEMで書き出します。
+func writeKeyFile(t *testing.T, key any) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return path
+}
+
+func setupKeys(t *testing.T, signingKeyFile string, retiredKeyFiles ...string) {
+	cfg := config.Default()
+	cfg.Secret = testSecret
+	cfg.JWT = config.JWTConfig{SigningKeyFile: signingKeyFile, RetiredKeyFiles: retiredKeyFiles}
+	require.NoError(t, security.Setup(&cfg))
+}
+
+func kid(t *testing.T, token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	require.NoError(t, err)
+	return parsed.Header["kid"].(string)
+}
+
+func TestKeyRotation(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	oldKeyFile := writeKeyFile(t, oldKey)
+	newKeyFile := writeKeyFile(t, newKey)
+
+	setupKeys(t, oldKeyFile)
+	oldToken, err := security.GenerateAccessToken(1, 2, 0)
+	require.NoError(t, err)
+	jwks := security.JWKS()
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "EdDSA", jwks.Keys[0].Alg)
+	assert.Equal(t, jwks.Keys[0].Kid, kid(t, oldToken.Body))
+
+	// 新しい鍵に入れ替えても、引退した鍵で署名したトークンは使える
+	setupKeys(t, newKeyFile, oldKeyFile)
+	newToken, err := security.GenerateAccessToken(1, 3, 0)
+	require.NoError(t, err)
+	jwks = security.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "ES256", jwks.Keys[0].Alg)
+	assert.Equal(t, jwks.Keys[0].Kid, kid(t, newToken.Body))
+	assert.NotEqual(t, kid(t, oldToken.Body), kid(t, newToken.Body))
+
+	claims, ok := security.VerifyAccessToken(oldToken.Body)
+	require.True(t, ok)
+	assert.Equal(t, uint(2), claims.SessionId)
+	claims, ok = security.VerifyAccessToken(newToken.Body)
+	require.True(t, ok)
+	assert.Equal(t, uint(3), claims.SessionId)
+
+	// 引退した鍵を外すと検証できなくなる
+	setupKeys(t, newKeyFile)
+	_, ok = security.VerifyAccessToken(oldToken.Body)
+	assert.False(t, ok)
+}
+
+func TestKeyDerivedFromSecret(t *testing.T) {
+	setupKeys(t, "")
+	token, err := security.GenerateAccessToken(1, 2, 0)
+	require.NoError(t, err)
+
+	// 同じ SECRET なら再起動しても同じ鍵になる
+	setupKeys(t, "")
+	_, ok := security.VerifyAccessToken(token.Body)
+	assert.True(t, ok)
+
+	// SECRET を知っていても、HS256 で署名したトークンは受け付けない
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  "1",
+		"sid":  "2",
+		"jti":  "forged",
+		"gen":  "0",
+		"exp":  time.Now().Add(time.Minute).Unix(),
+		"kind": "access_token",
+	})
+	forged.Header["kid"] = kid(t, token.Body)
+	body, err := forged.SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	_, ok = security.VerifyAccessToken(body)
+	assert.False(t, ok)
+}
+
+func TestLoadKeyringRejectsUnsupportedKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	cfg := config.Default()
+	cfg.JWT.SigningKeyFile = writeKeyFile(t, key)
+	_, err = security.LoadKeyring(&cfg)
+	assert.ErrorContains(t, err, "P-256")
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 8037 A.1 の鍵と A.3 のサムプリント
+	seed, err := base64.RawURLEncoding.DecodeString("nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A")
+	require.NoError(t, err)
+	keyring, err := security.NewKeyring(ed25519.NewKeyFromSeed(seed))
+	require.NoError(t, err)
+
+	assert.Equal(t, []security.JWK{{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+		Kid: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		Alg: "EdDSA",
+		Use: "sig",
+	}}, keyring.JWKS().Keys)
+}
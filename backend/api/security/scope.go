@@ -0,0 +1,60 @@
+package security
+
+// Scope はパーソナルアクセストークンで許可する操作の範囲です。
+type Scope string
+
+const (
+	ScopeRepoRead  Scope = "repo:read"  // リポジトリの閲覧とclone/fetch
+	ScopeRepoWrite Scope = "repo:write" // リポジトリの作成とpush
+	ScopeRepoAdmin Scope = "repo:admin" // リポジトリの設定変更・削除とコラボレーターの管理
+	ScopeKeysRead  Scope = "keys:read"  // SSH公開鍵の閲覧
+	ScopeKeysAdmin Scope = "keys:admin" // SSH公開鍵の登録・削除
+	ScopeOrgRead   Scope = "org:read"   // 組織とチームの閲覧
+	ScopeOrgAdmin  Scope = "org:admin"  // 組織とチームの管理
+)
+
+// impliedScopes は強いスコープが含む弱いスコープです。admin は write と read を、write は read を含みます。
+var impliedScopes = map[Scope][]Scope{
+	ScopeRepoWrite: {ScopeRepoRead},
+	ScopeRepoAdmin: {ScopeRepoWrite, ScopeRepoRead},
+	ScopeKeysAdmin: {ScopeKeysRead},
+	ScopeOrgAdmin:  {ScopeOrgRead},
+}
+
+var knownScopes = map[Scope]bool{
+	ScopeRepoRead:  true,
+	ScopeRepoWrite: true,
+	ScopeRepoAdmin: true,
+	ScopeKeysRead:  true,
+	ScopeKeysAdmin: true,
+	ScopeOrgRead:   true,
+	ScopeOrgAdmin:  true,
+}
+
+// ParseScopes は "repo:read" などのスコープ名を Scope に変換します。知らないスコープが含まれていれば ok=false です。
+func ParseScopes(names []string) ([]Scope, bool) {
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		scope := Scope(name)
+		if !knownScopes[scope] {
+			return nil, false
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, true
+}
+
+// HasScope は granted が required を直接または含意によって許可しているかを返します。
+func HasScope(granted []Scope, required Scope) bool {
+	for _, scope := range granted {
+		if scope == required {
+			return true
+		}
+		for _, implied := range impliedScopes[scope] {
+			if implied == required {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package security_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gityard-api/security"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, ok := security.ParseScopes([]string{"repo:read", "keys:admin"})
+	assert.True(t, ok)
+	assert.Equal(t, []security.Scope{security.ScopeRepoRead, security.ScopeKeysAdmin}, scopes)
+
+	_, ok = security.ParseScopes([]string{"repo:read", "repo:everything"})
+	assert.False(t, ok)
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []security.Scope
+		required security.Scope
+		expected bool
+	}{
+		{"same scope", []security.Scope{security.ScopeRepoWrite}, security.ScopeRepoWrite, true},
+		{"write implies read", []security.Scope{security.ScopeRepoWrite}, security.ScopeRepoRead, true},
+		{"admin implies write", []security.Scope{security.ScopeRepoAdmin}, security.ScopeRepoWrite, true},
+		{"read does not imply write", []security.Scope{security.ScopeRepoRead}, security.ScopeRepoWrite, false},
+		{"other resource", []security.Scope{security.ScopeKeysAdmin}, security.ScopeRepoRead, false},
+		{"no scopes", nil, security.ScopeRepoRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, security.HasScope(tt.granted, tt.required))
+		})
+	}
+}
@@ -4,22 +4,35 @@ import (
 	"crypto/rand"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"gityard-api/config"
 	"gityard-api/model"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func GenerateAccessToken(userId uint) (*model.AccessToken, error) {
-	expiresIn := time.Minute * config.AccessTokenActiveDurationMinutes
+// AccessTokenClaims はアクセストークンから取り出したユーザとセッションです。
+type AccessTokenClaims struct {
+	UserId    uint
+	SessionId uint
+	TokenId   string // jti。ログアウトで失効させるときに使う
+	ExpiresAt time.Time
+	// Generation は発行したときのユーザのトークンの世代です。ユーザの世代が進んでいたら失効しています。
+	Generation uint
+}
 
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["sub"] = strconv.Itoa(int(userId))
-	claims["exp"] = time.Now().Add(expiresIn).Unix()
-	claims["kind"] = "access_token"
+// GenerateAccessToken はセッションに紐づくアクセストークンを発行します。現在の署名鍵で署名し、kid ヘッダーを付けます。
+// generation にはユーザの今のトークンの世代を渡してください。
+func GenerateAccessToken(userId, sessionId, generation uint) (*model.AccessToken, error) {
+	expiresIn := tokenConfig.AccessTokenTTL
 
-	body, err := token.SignedString([]byte(config.Config("SECRET")))
+	body, err := keyring.sign(jwt.MapClaims{
+		"sub":  strconv.Itoa(int(userId)),
+		"sid":  strconv.Itoa(int(sessionId)),
+		"jti":  rand.Text(),
+		"gen":  strconv.Itoa(int(generation)),
+		"exp":  time.Now().Add(expiresIn).Unix(),
+		"kind": "access_token",
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +47,7 @@ func GenerateAccessToken(userId uint) (*model.AccessToken, error) {
 }
 
 func GenerateRefreshToken() (*model.RefreshToken, error) {
-	expiresIn := time.Minute * config.RefreshTokenActiveDurationMinutes
+	expiresIn := tokenConfig.RefreshTokenTTL
 
 	return &model.RefreshToken{
 		Body: rand.Text(),
@@ -45,38 +58,96 @@ func GenerateRefreshToken() (*model.RefreshToken, error) {
 	}, nil
 }
 
-func VerifyAccessToken(accessToken string) (uint, bool) {
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return []byte(config.Config("SECRET")), nil
-	})
+// パーソナルアクセストークンの接頭辞。JWTと見分けるのと、漏洩したときに検出しやすくするため
+const personalAccessTokenPrefix = "gyp_"
+
+// GeneratePersonalAccessToken はパーソナルアクセストークンを生成します。DBにはハッシュだけを保存してください。
+func GeneratePersonalAccessToken() string {
+	return personalAccessTokenPrefix + rand.Text()
+}
+
+// IsPersonalAccessToken はトークンがパーソナルアクセストークンの形式かを返します。
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
 
+// GenerateTwoFactorChallengeToken は二要素認証のコードを待つログインのチャレンジトークンを生成します。
+// DBにはハッシュだけを保存してください。
+func GenerateTwoFactorChallengeToken() string {
+	return rand.Text()
+}
+
+// GenerateWebAuthnCeremonyToken はWebAuthnのセレモニーを完了させるときに提示するトークンを生成します。
+// DBにはハッシュだけを保存してください。
+func GenerateWebAuthnCeremonyToken() string {
+	return rand.Text()
+}
+
+// GenerateVerificationToken はメールで送るリンクに載せるトークンを生成します。
+// DBにはハッシュだけを保存してください。
+func GenerateVerificationToken() string {
+	return rand.Text()
+}
+
+// VerifyAccessToken は現在の鍵か引退した鍵で署名された、期限内のアクセストークンを検証します。
+// 署名だけを確認するので、失効しているかどうかは service.TokenService.AuthenticateAccessToken で確認してください。
+func VerifyAccessToken(accessToken string) (*AccessTokenClaims, bool) {
+	token, err := keyring.parse(accessToken)
 	if err != nil || !token.Valid {
-		return 0, false
+		return nil, false
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, false
+		return nil, false
 	}
 
 	// 用途チェック
 	if kind, ok := claims["kind"]; !ok || kind != "access_token" {
-		return 0, false
+		return nil, false
+	}
+
+	userId, ok := uintClaim(claims, "sub")
+	if !ok {
+		return nil, false
+	}
+	sessionId, ok := uintClaim(claims, "sid")
+	if !ok {
+		return nil, false
+	}
+	tokenId, ok := claims["jti"].(string)
+	if !ok || tokenId == "" {
+		return nil, false
+	}
+	generation, ok := uintClaim(claims, "gen")
+	if !ok {
+		return nil, false
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, false
 	}
 
-	userIdStr, ok := claims["sub"]
+	return &AccessTokenClaims{
+		UserId:     userId,
+		SessionId:  sessionId,
+		TokenId:    tokenId,
+		ExpiresAt:  expiresAt.Time,
+		Generation: generation,
+	}, true
+}
+
+// uintClaim は文字列で入れたIDのクレームを取り出します。
+func uintClaim(claims jwt.MapClaims, key string) (uint, bool) {
+	value, ok := claims[key]
 	if !ok {
 		return 0, false
 	}
-	userId64, err := strconv.ParseInt(fmt.Sprintf("%s", userIdStr), 10, 64)
+	id64, err := strconv.ParseInt(fmt.Sprintf("%s", value), 10, 64)
 	if err != nil {
 		return 0, false
 	}
-
-	return uint(userId64), true
+	return uint(id64), true
 }
 
 //
@@ -85,7 +156,7 @@ func VerifyAccessToken(accessToken string) (uint, bool) {
 //		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 //			return nil, fmt.Errorf("unexpected signing method")
 //		}
-//		return []byte(config.Config("SECRET")), nil
+//		return secret, nil
 //	})
 //
 //	if err != nil || !token.Valid {
@@ -0,0 +1,31 @@
+package security_test
+
+import (
+	"gityard-api/security"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTokenClaims(t *testing.T) {
+	setupKeys(t, "")
+	token, err := security.GenerateAccessToken(1, 2, 3)
+	require.NoError(t, err)
+	other, err := security.GenerateAccessToken(1, 2, 3)
+	require.NoError(t, err)
+
+	claims, ok := security.VerifyAccessToken(token.Body)
+	require.True(t, ok)
+	assert.Equal(t, uint(1), claims.UserId)
+	assert.Equal(t, uint(2), claims.SessionId)
+	assert.Equal(t, uint(3), claims.Generation)
+	assert.WithinDuration(t, time.Now().Add(token.ExpiresIn), claims.ExpiresAt, time.Second)
+
+	// 同じセッションでもトークンごとに jti は違う
+	otherClaims, ok := security.VerifyAccessToken(other.Body)
+	require.True(t, ok)
+	assert.NotEmpty(t, claims.TokenId)
+	assert.NotEqual(t, claims.TokenId, otherClaims.TokenId)
+}
@@ -0,0 +1,53 @@
+package security_test
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"gityard-api/security"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B のSHA1のテストベクタ(下6桁)
+func TestVerifyTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		step, ok := security.VerifyTOTP(secret, tt.code, time.Unix(tt.unix, 0))
+		assert.True(t, ok, tt.code)
+		assert.Equal(t, tt.unix/30, step)
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	// 1ステップ前後のずれは許容する
+	_, ok := security.VerifyTOTP(secret, "287082", time.Unix(59+30, 0))
+	assert.True(t, ok)
+
+	_, ok = security.VerifyTOTP(secret, "287082", time.Unix(59+90, 0))
+	assert.False(t, ok)
+
+	_, ok = security.VerifyTOTP(secret, "28708", time.Unix(59, 0))
+	assert.False(t, ok)
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	code := security.GenerateRecoveryCode()
+	assert.Len(t, code, 11)
+	assert.Equal(t, code, security.NormalizeRecoveryCode(" "+code+" "))
+
+	assert.Equal(t, "abcde-fghij", security.NormalizeRecoveryCode("ABCDEFGHIJ"))
+}
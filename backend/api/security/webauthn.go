@@ -0,0 +1,214 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"strconv"
+)
+
+// WebAuthnUser はWebAuthnのセレモニーで扱うユーザと登録済みのクレデンシャルです。
+type WebAuthnUser struct {
+	ID          uint
+	Name        string
+	Credentials []webauthn.Credential
+}
+
+func (user *WebAuthnUser) WebAuthnID() []byte {
+	return WebAuthnUserHandle(user.ID)
+}
+
+func (user *WebAuthnUser) WebAuthnName() string {
+	return user.Name
+}
+
+func (user *WebAuthnUser) WebAuthnDisplayName() string {
+	return user.Name
+}
+
+func (user *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return user.Credentials
+}
+
+// WebAuthnUserHandle はクレデンシャルに保存するユーザハンドルを返します。パスワードレスのログインでユーザを特定するのに使います。
+func WebAuthnUserHandle(userId uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userId), 10))
+}
+
+// ParseWebAuthnUserHandle はユーザハンドルからユーザIDを取り出します。
+func ParseWebAuthnUserHandle(userHandle []byte) (uint, bool) {
+	id, err := strconv.ParseUint(string(userHandle), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// newWebAuthn は設定のRelying PartyでWebAuthnを初期化します。
+func newWebAuthn() (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          webAuthnConfig.RPID,
+		RPDisplayName: "gityard",
+		RPOrigins:     webAuthnConfig.RPOrigins,
+	})
+}
+
+// WebAuthnCeremony はブラウザに渡すオプションと、完了の検証まで保存しておくセッションです。
+type WebAuthnCeremony struct {
+	Options     json.RawMessage
+	SessionData string
+}
+
+func newWebAuthnCeremony(options any, session *webauthn.SessionData) (*WebAuthnCeremony, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnCeremony{
+		Options:     optionsJSON,
+		SessionData: string(sessionJSON),
+	}, nil
+}
+
+func parseWebAuthnSessionData(sessionData string) (*webauthn.SessionData, error) {
+	session := new(webauthn.SessionData)
+	if err := json.Unmarshal([]byte(sessionData), session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// BeginWebAuthnRegistration はクレデンシャルの登録を始めます。登録済みのクレデンシャルは二重に登録させません。
+func BeginWebAuthnRegistration(user *WebAuthnUser) (*WebAuthnCeremony, error) {
+	w, err := newWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := w.BeginRegistration(
+		user,
+		webauthn.WithExclusions(webauthn.Credentials(user.Credentials).CredentialDescriptors()),
+		// パスワードレスでも使えるよう、できればパスキーとして保存してもらう
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWebAuthnCeremony(creation, session)
+}
+
+// FinishWebAuthnRegistration はブラウザから返ってきた登録のレスポンスを検証してクレデンシャルを返します。
+func FinishWebAuthnRegistration(user *WebAuthnUser, sessionData string, response []byte) (*webauthn.Credential, error) {
+	w, err := newWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+	session, err := parseWebAuthnSessionData(sessionData)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, err
+	}
+
+	return w.CreateCredential(user, *session, parsed)
+}
+
+// BeginWebAuthnLogin はパスワードを確認したユーザの二要素目としてのログインを始めます。
+func BeginWebAuthnLogin(user *WebAuthnUser) (*WebAuthnCeremony, error) {
+	w, err := newWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, session, err := w.BeginLogin(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWebAuthnCeremony(assertion, session)
+}
+
+// FinishWebAuthnLogin は二要素目のログインのレスポンスを検証し、署名カウンタを更新したクレデンシャルを返します。
+func FinishWebAuthnLogin(user *WebAuthnUser, sessionData string, response []byte) (*webauthn.Credential, error) {
+	w, err := newWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+	session, err := parseWebAuthnSessionData(sessionData)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, err
+	}
+
+	return w.ValidateLogin(user, *session, parsed)
+}
+
+// BeginWebAuthnPasswordlessLogin はユーザを指定しないパスワードレスのログインを始めます。
+// パスワードの代わりになるので、認証器でのユーザ検証(生体認証やPIN)を必須にします。
+func BeginWebAuthnPasswordlessLogin() (*WebAuthnCeremony, error) {
+	w, err := newWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, session, err := w.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, err
+	}
+
+	return newWebAuthnCeremony(assertion, session)
+}
+
+// FinishWebAuthnPasswordlessLogin はパスワードレスのログインのレスポンスを検証します。
+// lookup にはユーザハンドルから取り出したユーザIDでユーザと登録済みのクレデンシャルを引く関数を渡します。
+func FinishWebAuthnPasswordlessLogin(lookup func(userId uint) (*WebAuthnUser, error), sessionData string, response []byte) (*WebAuthnUser, *webauthn.Credential, error) {
+	w, err := newWebAuthn()
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := parseWebAuthnSessionData(sessionData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user *WebAuthnUser
+	_, credential, err := w.ValidatePasskeyLogin(func(_, userHandle []byte) (webauthn.User, error) {
+		userId, ok := ParseWebAuthnUserHandle(userHandle)
+		if !ok {
+			return nil, fmt.Errorf("invalid user handle")
+		}
+		found, err := lookup(userId)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			return nil, fmt.Errorf("user not found: user_id=%v", userId)
+		}
+		user = found
+		return found, nil
+	}, *session, parsed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, credential, nil
+}
@@ -0,0 +1,121 @@
+package security_test
+
+import (
+	"github.com/descope/virtualwebauthn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gityard-api/config"
+	"gityard-api/security"
+	"testing"
+)
+
+var webAuthnRelyingParty = virtualwebauthn.RelyingParty{Name: "gityard", ID: "example.com", Origin: "https://example.com"}
+
+func setupWebAuthn(t *testing.T) {
+	cfg := config.Default()
+	cfg.WebAuthn = config.WebAuthnConfig{RPID: webAuthnRelyingParty.ID, RPOrigins: []string{webAuthnRelyingParty.Origin}}
+	require.NoError(t, security.Setup(&cfg))
+}
+
+// registerWebAuthnCredential はソフトウェアの認証器でクレデンシャルを登録します。
+func registerWebAuthnCredential(t *testing.T, user *security.WebAuthnUser, authenticator *virtualwebauthn.Authenticator) virtualwebauthn.Credential {
+	ceremony, err := security.BeginWebAuthnRegistration(user)
+	require.NoError(t, err)
+
+	options, err := virtualwebauthn.ParseAttestationOptions(string(ceremony.Options))
+	require.NoError(t, err)
+	assert.Equal(t, string(security.WebAuthnUserHandle(user.ID)), options.UserID)
+
+	credential := virtualwebauthn.NewCredential(virtualwebauthn.KeyTypeEC2)
+	response := virtualwebauthn.CreateAttestationResponse(webAuthnRelyingParty, *authenticator, credential, *options)
+
+	registered, err := security.FinishWebAuthnRegistration(user, ceremony.SessionData, []byte(response))
+	require.NoError(t, err)
+	assert.Equal(t, credential.ID, registered.ID)
+
+	user.Credentials = append(user.Credentials, *registered)
+	authenticator.AddCredential(credential)
+	return credential
+}
+
+func TestWebAuthnLogin(t *testing.T) {
+	setupWebAuthn(t)
+	user := &security.WebAuthnUser{ID: 42, Name: "alice"}
+	authenticator := virtualwebauthn.NewAuthenticator()
+	credential := registerWebAuthnCredential(t, user, &authenticator)
+
+	// 二要素目として使うと、署名カウンタが進む
+	credential.Counter = 5
+	ceremony, err := security.BeginWebAuthnLogin(user)
+	require.NoError(t, err)
+	options, err := virtualwebauthn.ParseAssertionOptions(string(ceremony.Options))
+	require.NoError(t, err)
+	response := virtualwebauthn.CreateAssertionResponse(webAuthnRelyingParty, authenticator, credential, *options)
+
+	verified, err := security.FinishWebAuthnLogin(user, ceremony.SessionData, []byte(response))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5), verified.Authenticator.SignCount)
+	assert.False(t, verified.Authenticator.CloneWarning)
+	user.Credentials[0] = *verified
+
+	// 同じセッションは使い回せない(別のチャレンジに対する署名は通らない)
+	other, err := security.BeginWebAuthnLogin(user)
+	require.NoError(t, err)
+	_, err = security.FinishWebAuthnLogin(user, other.SessionData, []byte(response))
+	assert.Error(t, err)
+
+	// カウンタが戻ったらクローンを疑う
+	credential.Counter = 3
+	ceremony, err = security.BeginWebAuthnLogin(user)
+	require.NoError(t, err)
+	options, err = virtualwebauthn.ParseAssertionOptions(string(ceremony.Options))
+	require.NoError(t, err)
+	response = virtualwebauthn.CreateAssertionResponse(webAuthnRelyingParty, authenticator, credential, *options)
+
+	verified, err = security.FinishWebAuthnLogin(user, ceremony.SessionData, []byte(response))
+	require.NoError(t, err)
+	assert.True(t, verified.Authenticator.CloneWarning)
+}
+
+func TestWebAuthnPasswordlessLogin(t *testing.T) {
+	setupWebAuthn(t)
+	user := &security.WebAuthnUser{ID: 42, Name: "alice"}
+	authenticator := virtualwebauthn.NewAuthenticatorWithOptions(virtualwebauthn.AuthenticatorOptions{
+		UserHandle: security.WebAuthnUserHandle(user.ID),
+	})
+	credential := registerWebAuthnCredential(t, user, &authenticator)
+
+	lookup := func(userId uint) (*security.WebAuthnUser, error) {
+		if userId != user.ID {
+			return nil, nil
+		}
+		return user, nil
+	}
+
+	ceremony, err := security.BeginWebAuthnPasswordlessLogin()
+	require.NoError(t, err)
+	options, err := virtualwebauthn.ParseAssertionOptions(string(ceremony.Options))
+	require.NoError(t, err)
+	assert.Empty(t, options.AllowCredentials)
+	response := virtualwebauthn.CreateAssertionResponse(webAuthnRelyingParty, authenticator, credential, *options)
+
+	found, verified, err := security.FinishWebAuthnPasswordlessLogin(lookup, ceremony.SessionData, []byte(response))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	assert.Equal(t, credential.ID, verified.ID)
+
+	// ユーザ検証をしない認証器ではパスワードの代わりにならない
+	unverified := virtualwebauthn.NewAuthenticatorWithOptions(virtualwebauthn.AuthenticatorOptions{
+		UserHandle:      security.WebAuthnUserHandle(user.ID),
+		UserNotVerified: true,
+	})
+	unverified.AddCredential(credential)
+	ceremony, err = security.BeginWebAuthnPasswordlessLogin()
+	require.NoError(t, err)
+	options, err = virtualwebauthn.ParseAssertionOptions(string(ceremony.Options))
+	require.NoError(t, err)
+	response = virtualwebauthn.CreateAssertionResponse(webAuthnRelyingParty, unverified, credential, *options)
+
+	_, _, err = security.FinishWebAuthnPasswordlessLogin(lookup, ceremony.SessionData, []byte(response))
+	assert.Error(t, err)
+}
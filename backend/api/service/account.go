@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gityard-api/config"
+	"gityard-api/model"
+	"gityard-api/security"
+	"gityard-api/storage"
+	"log/slog"
+	"time"
+)
+
+// AccountService は個人アカウントのプロフィールとハンドルネーム、退会を扱います。
+type AccountService struct {
+	store        AccountServiceStore
+	repositories *storage.RepositoryStorage
+	blobs        storage.BlobStore
+	cfg          *config.Config
+}
+
+// NewAccountService は AccountService を作成します。退会したユーザのリポジトリは repositories から、アイコンは blobs から削除します。
+func NewAccountService(store AccountServiceStore, repositories *storage.RepositoryStorage, blobs storage.BlobStore, cfg *config.Config) *AccountService {
+	return &AccountService{store: store, repositories: repositories, blobs: blobs, cfg: cfg}
+}
+
+const accountDeletionBatchSize = 10
+
+// GetAccountDeletion は退会の予定日時を返します。退会を申し込んでいなければ nil です。
+func (s *AccountService) GetAccountDeletion(userId uint) (*time.Time, error) {
+	userInDB, err := s.store.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if userInDB == nil || userInDB.IsDeleted {
+		return nil, &ErrUserNotFound{UserId: userId}
+	}
+
+	return userInDB.DeletionScheduledAt, nil
+}
+
+// ScheduleAccountDeletion はパスワードと、有効にしていれば二要素認証のコードで本人であることを確認してから退会を申し込みます。
+// 猶予期間が過ぎるまでは CancelAccountDeletion で取り消せます。
+// 申し込んだ時点で他のセッション、パーソナルアクセストークン、SSH公開鍵は削除し、取り消しても元に戻しません。
+// 削除したセッションのアクセストークンも、セッションがなくなるのでその時点で使えなくなります。
+func (s *AccountService) ScheduleAccountDeletion(userId, sessionId uint, password, code string) (time.Time, error) {
+	deleteAt := time.Now().Add(s.cfg.Account.DeletionGracePeriod)
+	err := s.store.Transaction(func(tx AccountServiceStore) error {
+		userInDB, err := tx.GetUserById(userId)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil || userInDB.IsDeleted || userInDB.Email == nil {
+			return &ErrUserNotFound{UserId: userId}
+		}
+		if userInDB.DeletionScheduledAt != nil {
+			return &ErrAccountDeletionAlreadyScheduled{UserId: userId}
+		}
+
+		credInDB, err := tx.GetUserCredentialById(userId)
+		if err != nil {
+			return err
+		}
+		if credInDB == nil {
+			return &ErrCredentialNotFound{UserId: userId}
+		}
+		if !security.VerifyPassword(password, credInDB.HashedPassword) {
+			return &ErrPasswordMissMatch{UserId: userId}
+		}
+
+		twoFactorEnabled, err := isTwoFactorEnabled(tx, userId)
+		if err != nil {
+			return err
+		}
+		if twoFactorEnabled {
+			if err := verifyTwoFactorCodeForSettings(tx, userId, code, false); err != nil {
+				return err
+			}
+		}
+
+		// 組織をオーナーのいない状態にはしない
+		if err := ensureNotLastOrganizationOwner(tx, userId); err != nil {
+			return err
+		}
+
+		if err := tx.UpdateUserDeletionScheduledAt(userId, &deleteAt); err != nil {
+			return err
+		}
+		if err := tx.DeleteUserRefreshTokensByUserId(userId, sessionId); err != nil {
+			return err
+		}
+		if err := tx.DeleteUserAccessTokensByUserId(userId); err != nil {
+			return err
+		}
+		if err := tx.DeletePublicKeysByUserId(userId); err != nil {
+			return err
+		}
+
+		return enqueueAccountDeletionMail(tx, s.cfg, *userInDB.Email, userInDB.Locale, deleteAt)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return deleteAt, nil
+}
+
+// CancelAccountDeletion は猶予期間中の退会を取り消します。
+func (s *AccountService) CancelAccountDeletion(userId uint) error {
+	return s.store.Transaction(func(tx AccountServiceStore) error {
+		userInDB, err := tx.GetUserById(userId)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil || userInDB.IsDeleted {
+			return &ErrUserNotFound{UserId: userId}
+		}
+		if userInDB.DeletionScheduledAt == nil {
+			return &ErrAccountDeletionNotScheduled{UserId: userId}
+		}
+
+		return tx.UpdateUserDeletionScheduledAt(userId, nil)
+	})
+}
+
+// ensureNotLastOrganizationOwner はユーザが唯一のオーナーになっている組織がないことを確認します。
+func ensureNotLastOrganizationOwner(tx OrganizationStore, userId uint) error {
+	memberships, err := tx.GetOrganizationMembersByUserId(userId)
+	if err != nil {
+		return err
+	}
+	for _, membership := range memberships {
+		if model.OrganizationRole(membership.Role) != model.OrganizationRoleOwner {
+			continue
+		}
+		if err := ensureAnotherOrganizationOwner(tx, membership.OrganizationAccountID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAccount はユーザを退会させます。個人アカウントのリポジトリはすべて削除し、メールアドレスとハンドルネームを解放します。
+// ユーザとアカウントの行は、他の行から参照されていることがあるので削除済みの印を付けて残します。
+func (s *AccountService) deleteAccount(tx AccountServiceStore, userId uint) ([]*storage.TrashedRepository, error) {
+	// 猶予期間中に他のオーナーが抜けて、唯一のオーナーになっていることがある
+	if err := ensureNotLastOrganizationOwner(tx, userId); err != nil {
+		return nil, err
+	}
+
+	memberships, err := tx.GetOrganizationMembersByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, membership := range memberships {
+		if err := tx.DeleteTeamMembersByUserId(membership.OrganizationAccountID, userId); err != nil {
+			return nil, err
+		}
+		if err := tx.DeleteOrganizationMember(membership.OrganizationAccountID, userId); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.DeleteRepositoryCollaboratorsByUserId(userId); err != nil {
+		return nil, err
+	}
+	if err := tx.DeleteRepositoryInvitationsByUserId(userId); err != nil {
+		return nil, err
+	}
+
+	var trashed []*storage.TrashedRepository
+	account, err := tx.GetPersonalAccountByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil {
+		repoIds, err := tx.GetRepositoryIdsByOwnerAccountId(account.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, repoId := range repoIds {
+			if err := tx.DeleteRepository(repoId); err != nil {
+				return trashed, err
+			}
+			// 行の削除が確定するまではゴミ箱に退避しておく
+			t, err := s.repositories.Trash(repoId)
+			if err != nil {
+				return trashed, err
+			}
+			trashed = append(trashed, t)
+		}
+
+		if err := tx.SoftDeleteAccount(account.ID); err != nil {
+			return trashed, err
+		}
+		if account.HandlenameID != nil {
+			if err := tx.DeleteHandleName(*account.HandlenameID); err != nil {
+				return trashed, err
+			}
+		}
+		// 改名前のハンドルネームも解放する
+		if err := tx.DeleteHandleNameRedirectsByAccountId(account.ID); err != nil {
+			return trashed, err
+		}
+	}
+
+	// ログインに使えるものはすべて削除する
+	if err := tx.DeleteUserRefreshTokensByUserId(userId, 0); err != nil {
+		return trashed, err
+	}
+	if _, err := revokeAccessTokens(tx, userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserAccessTokensByUserId(userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeletePublicKeysByUserId(userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserTwoFactorChallengesByUserId(userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserTwoFactor(userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserRecoveryCodesByUserId(userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserWebAuthnCredentialsByUserId(userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteWebAuthnCeremoniesByUserId(userId); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserVerificationTokensByUserId(userId, model.EmailVerificationToken); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserVerificationTokensByUserId(userId, model.PasswordResetToken); err != nil {
+		return trashed, err
+	}
+	if err := tx.DeleteUserCredential(userId); err != nil {
+		return trashed, err
+	}
+
+	return trashed, tx.SoftDeleteUser(userId)
+}
+
+// DeleteScheduledAccounts は猶予期間が過ぎたユーザを退会させ、退会させた人数を返します。
+// 唯一のオーナーになっている組織があるユーザは、オーナーが増えるまで1日ずつ退会を延期します。
+// ほかの理由で退会させられなかったユーザもログに残して1日延期し、残りのユーザの退会を続けます。
+func (s *AccountService) DeleteScheduledAccounts() (int, error) {
+	users, err := s.store.GetUsersDueForDeletion(time.Now(), accountDeletionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, user := range users {
+		var trashed []*storage.TrashedRepository
+		var iconpath string
+		err := s.store.Transaction(func(tx AccountServiceStore) error {
+			// アバター画像は行の削除が確定してから消す
+			account, err := tx.GetPersonalAccountByUserId(user.ID)
+			if err != nil {
+				return err
+			}
+			if account != nil {
+				profile, err := tx.GetAccountProfileById(account.ID)
+				if err != nil {
+					return err
+				}
+				if profile != nil {
+					iconpath = profile.Iconpath
+				}
+			}
+
+			trashed, err = s.deleteAccount(tx, user.ID)
+			return err
+		})
+		if err != nil {
+			for _, t := range trashed {
+				if err := t.Restore(); err != nil {
+					slog.Error("failed to restore repository from trash", "detail", err)
+				}
+			}
+			var lastOwnerErr *ErrLastOrganizationOwner
+			if errors.As(err, &lastOwnerErr) {
+				slog.Warn("account deletion postponed", "reason", "last organization owner", "userId", user.ID, "organizationAccountId", lastOwnerErr.OrganizationAccountId)
+			} else {
+				slog.Error("account deletion postponed", "reason", "failed to delete account", "userId", user.ID, "detail", err)
+			}
+			// 他のユーザの退会を妨げないよう、後回しにする
+			postponed := time.Now().Add(time.Hour * 24)
+			if err := s.store.UpdateUserDeletionScheduledAt(user.ID, &postponed); err != nil {
+				return deleted, err
+			}
+			continue
+		}
+
+		for _, t := range trashed {
+			if err := t.Purge(); err != nil {
+				// 行は消えているので、ゴミ箱に残っても利用者からは見えない
+				slog.Error("failed to purge trashed repository", "detail", err)
+			}
+		}
+		deleteAvatarBlob(s.blobs, iconpath)
+		slog.Info("account deleted", "userId", user.ID, "repositories", len(trashed))
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// RunAccountDeletionWorker は ctx が終わるまで、定期的に猶予期間が過ぎたユーザを退会させます。
+func (s *AccountService) RunAccountDeletionWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Account.DeletionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			deleted, err := s.DeleteScheduledAccounts()
+			if err != nil {
+				slog.Error("failed to delete scheduled accounts", "detail", err)
+				break
+			}
+			if deleted < accountDeletionBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
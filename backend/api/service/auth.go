@@ -1,23 +1,69 @@
 package service
 
 import (
-	"gityard-api/database"
+	"gityard-api/config"
 	"gityard-api/model"
 	"gityard-api/security"
-	"gityard-api/service/repository"
-	"gorm.io/gorm"
+	"gityard-api/storage"
 	"log/slog"
 	"time"
 )
 
-func SignUp(email, password, handlename string) (*model.User, *model.RefreshToken, error) {
-	db := database.DB
+// AuthService はユーザの登録とログイン、セッション、パスワードとメールアドレスの確認を扱います。
+type AuthService struct {
+	store AuthServiceStore
+	blobs storage.BlobStore
+	cfg   *config.Config
+}
+
+// NewAuthService は AuthService を作成します。登録したユーザのアイコンは blobs に置きます。
+func NewAuthService(store AuthServiceStore, blobs storage.BlobStore, cfg *config.Config) *AuthService {
+	return &AuthService{store: store, blobs: blobs, cfg: cfg}
+}
+
+// Session はログインで発行したセッションとそのリフレッシュトークンです。
+type Session struct {
+	UserId       uint
+	SessionId    uint
+	RefreshToken *model.RefreshToken
+	// TokenGeneration はアクセストークンに入れるユーザのトークンの世代です。
+	TokenGeneration uint
+}
+
+// SessionClient はセッションを使っている端末の情報です。
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// createSession はユーザの新しいセッションを作成します。
+func createSession(tx userSessionStore, userId uint, client SessionClient) (*Session, error) {
+	userInDB, err := tx.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if userInDB == nil {
+		return nil, &ErrUserNotFound{UserId: userId}
+	}
+
+	userRefreshToken, refreshToken, err := tx.CreateUserRefreshToken(userId, client.UserAgent, client.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		UserId:          userId,
+		SessionId:       userRefreshToken.ID,
+		RefreshToken:    refreshToken,
+		TokenGeneration: userInDB.TokenGeneration,
+	}, nil
+}
 
-	var user *model.User
-	var refreshToken *model.RefreshToken
-	err := db.Transaction(func(tx *gorm.DB) error {
+// SignUp はユーザを登録してセッションを作成します。locale はメールの言語として保存します。
+func (s *AuthService) SignUp(email, password, handlename, locale string, client SessionClient) (*Session, error) {
+	var session *Session
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
 		// 登録済みでないかチェック
-		userInDB, err := repository.GetUserByEmail(tx, email)
+		userInDB, err := tx.GetUserByEmail(email)
 		if err != nil {
 			return err
 		}
@@ -25,29 +71,18 @@ func SignUp(email, password, handlename string) (*model.User, *model.RefreshToke
 			return &ErrRegisteredEmail{Email: email}
 		}
 
-		handlenameInDB, err := repository.GetHandleNameByName(tx, handlename)
+		registeredHandleName, err := claimHandlename(tx, handlename, 0)
 		if err != nil {
 			return err
 		}
-		if handlenameInDB != nil { // handlename登録済み
-			return &ErrRegisteredHandleName{HandleName: handlename}
-		}
 
 		// 登録処理
-		registeredUser, err := repository.CreateUser(tx, email)
+		registeredUser, err := tx.CreateUser(email, locale)
 		if err != nil {
 			return err
 		}
-		user = registeredUser
 
-		registeredHandleName, err := repository.CreateHandleName(tx, handlename)
-		if err != nil {
-			return err
-		}
-
-		registeredAccount, err := repository.CreateAccount(
-			tx,
-			registeredUser.ID,
+		registeredAccount, err := tx.CreateAccount(&registeredUser.ID,
 			registeredHandleName.ID,
 			model.PersonalAccount,
 		)
@@ -55,53 +90,59 @@ func SignUp(email, password, handlename string) (*model.User, *model.RefreshToke
 			return err
 		}
 
-		_, err = repository.CreateAccountProfile(
-			tx,
-			registeredAccount.ID,
+		iconpath, err := createIdenticon(s.blobs, registeredAccount.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateAccountProfile(registeredAccount.ID,
 			registeredHandleName.Handlename,
+			iconpath,
 			false,
 		)
 		if err != nil {
 			return err
 		}
 
-		_, err = repository.CreateUserCredential(tx, registeredUser.ID, password)
+		_, err = tx.CreateUserCredential(registeredUser.ID, password)
 		if err != nil {
 			return err
 		}
 
-		registeredRefreshToken, err := repository.CreateOrUpdateUserRefreshToken(tx, registeredUser.ID)
+		verificationToken, err := createEmailVerificationToken(tx, s.cfg, registeredUser.ID, email)
+		if err != nil {
+			return err
+		}
+		err = enqueueEmailVerificationMail(tx, s.cfg, email, locale, verificationToken)
 		if err != nil {
 			return err
 		}
-		refreshToken = registeredRefreshToken
 
-		return nil
+		session, err = createSession(tx, registeredUser.ID, client)
+		return err
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return user, refreshToken, nil
+	return session, nil
 }
 
-func Login(email, password string) (*model.User, *model.RefreshToken, error) {
-	db := database.DB
-
-	var user *model.User
-	var refreshToken *model.RefreshToken
-	err := db.Transaction(func(tx *gorm.DB) error {
+// Login はパスワードを検証して新しいセッションを作成します。他の端末のセッションはそのまま残します。
+// 二要素認証を有効にしているユーザにはセッションの代わりにチャレンジを返すので、LoginWithTwoFactor で続けてください。
+func (s *AuthService) Login(email, password string, client SessionClient) (*Session, *TwoFactorChallenge, error) {
+	var session *Session
+	var challenge *TwoFactorChallenge
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
 		// credentialはuserIdとしか結びついていないので
-		userInDB, err := repository.GetUserByEmail(tx, email)
+		userInDB, err := tx.GetUserByEmail(email)
 		if err != nil {
 			return err
 		}
 		if userInDB == nil {
 			return &ErrUserNotFound{Email: email}
 		}
-		user = userInDB
 
-		credInDB, err := repository.GetUserCredentialById(tx, userInDB.ID)
+		credInDB, err := tx.GetUserCredentialById(userInDB.ID)
 		if err != nil {
 			return err
 		}
@@ -113,67 +154,153 @@ func Login(email, password string) (*model.User, *model.RefreshToken, error) {
 			return &ErrPasswordMissMatch{UserId: userInDB.ID}
 		}
 
-		registeredRefreshToken, err := repository.CreateOrUpdateUserRefreshToken(tx, userInDB.ID)
+		methods, err := getTwoFactorMethods(tx, userInDB.ID)
 		if err != nil {
 			return err
 		}
-		refreshToken = registeredRefreshToken
+		if len(methods) > 0 {
+			challenge, err = createTwoFactorChallenge(tx, s.cfg, userInDB.ID, methods)
+			return err
+		}
 
-		return nil
+		session, err = createSession(tx, userInDB.ID, client)
+		return err
 	})
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return user, refreshToken, nil
+	return session, challenge, nil
 }
 
-func Logout(userId uint) error {
-	db := database.DB
+// Logout はアクセストークンを発行したセッションだけを終了します。
+// 使っていたアクセストークン tokenId も失効させるので、期限を待たずに使えなくなります。失効の記録はトークンの期限 expiresAt まで残します。
+func (s *AuthService) Logout(userId, sessionId uint, tokenId string, expiresAt time.Time) error {
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		// 失効の記録はアクセストークンの期限が切れたら要らないので、ついでに消す
+		now := time.Now()
+		if err := tx.DeleteExpiredRevokedAccessTokens(now); err != nil {
+			return err
+		}
+		if err := tx.CreateRevokedAccessToken(tokenId, userId, expiresAt); err != nil {
+			return err
+		}
 
-	err := db.Transaction(func(tx *gorm.DB) error {
-		refreshTokenInDB, err := repository.GetUserRefreshTokenById(tx, userId)
+		refreshTokenInDB, err := tx.GetUserRefreshTokenById(sessionId)
 		if err != nil {
 			return err
 		}
-		if refreshTokenInDB == nil { // リフレッシュトークンを削除しようとしたけど、そもそもなかった。な〜ぜな〜ぜ
-			slog.Warn("user refresh token not found")
+		if refreshTokenInDB == nil || refreshTokenInDB.UserID != userId { // リフレッシュトークンを削除しようとしたけど、そもそもなかった。な〜ぜな〜ぜ
+			slog.Warn("user refresh token not found", "userId", userId, "sessionId", sessionId)
+			return nil
 		}
 
-		return repository.DeleteUserRefreshToken(tx, userId)
+		return tx.DeleteUserRefreshToken(sessionId)
 	})
 	return err
 }
 
-func Refresh(refreshToken string) (*uint, *model.RefreshToken, error) {
-	db := database.DB
+// revokeAccessTokens はユーザのトークンの世代を進めて、それまでに発行したアクセストークンをすべて失効させ、新しい世代を返します。
+// セッションを残す場合は、新しい世代でアクセストークンを発行し直すかリフレッシュしてもらってください。
+// 管理者によるユーザの停止はまだないので、実装するときはここを呼んでください。
+func revokeAccessTokens(tx SessionStore, userId uint) (uint, error) {
+	return tx.IncrementUserTokenGeneration(userId)
+}
 
-	var userId *uint
-	var newRefreshToken *model.RefreshToken
-	err := db.Transaction(func(tx *gorm.DB) error {
-		userIdInDB, expiresAt, err := repository.GetUserIdAndExpiresAtByRefreshToken(tx, refreshToken)
+// Refresh はリフレッシュトークンを検証し、同じセッションのまま新しいリフレッシュトークンを発行します。
+// ローテーション済みのトークンが使われた場合は漏洩とみなしてセッションごと失効させます。
+func (s *AuthService) Refresh(refreshToken string, client SessionClient) (*Session, error) {
+	var session *Session
+	var reused *model.UserRefreshToken
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		refreshTokenInDB, err := tx.GetUserRefreshTokenByRefreshToken(refreshToken)
 		if err != nil {
 			return err
 		}
-		if userIdInDB == nil {
-			return &ErrInvalidRefreshTokenProvided{}
+		if refreshTokenInDB == nil {
+			rotated, err := tx.GetRotatedUserRefreshToken(refreshToken)
+			if err != nil {
+				return err
+			}
+			if rotated == nil {
+				return &ErrInvalidRefreshTokenProvided{}
+			}
+			reused, err = tx.GetUserRefreshTokenById(rotated.RefreshTokenID)
+			if err != nil {
+				return err
+			}
+			// 失効をコミットするため、エラーはトランザクションの外で返す
+			return tx.DeleteUserRefreshToken(rotated.RefreshTokenID)
 		}
-		if !time.Now().Before(*expiresAt) {
+		if !time.Now().Before(refreshTokenInDB.ExpiresAt) {
 			return &ErrExpiredRefreshTokenProvided{}
 		}
-		userId = userIdInDB
 
-		generatedRefreshToken, err := repository.CreateOrUpdateUserRefreshToken(tx, *userIdInDB)
+		userInDB, err := tx.GetUserById(refreshTokenInDB.UserID)
 		if err != nil {
 			return err
 		}
-		newRefreshToken = generatedRefreshToken
+		if userInDB == nil {
+			return &ErrUserNotFound{UserId: refreshTokenInDB.UserID}
+		}
+
+		generatedRefreshToken, err := tx.UpdateUserRefreshToken(refreshTokenInDB, client.UserAgent, client.IPAddress)
+		if err != nil {
+			return err
+		}
+		session = &Session{
+			UserId:          refreshTokenInDB.UserID,
+			SessionId:       refreshTokenInDB.ID,
+			RefreshToken:    generatedRefreshToken,
+			TokenGeneration: userInDB.TokenGeneration,
+		}
 
 		return nil
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if reused != nil {
+		slog.Warn(
+			"security event: refresh token reuse detected, session revoked",
+			"userId", reused.UserID,
+			"sessionId", reused.ID,
+			"userAgent", client.UserAgent,
+			"ipAddress", client.IPAddress,
+		)
+		return nil, &ErrRefreshTokenReused{SessionId: reused.ID}
+	}
+
+	return session, nil
+}
+
+// GetUserSessions はユーザの有効なセッションを返します。
+func (s *AuthService) GetUserSessions(userId uint, offset, limit int) ([]model.UserRefreshToken, error) {
+	var sessions []model.UserRefreshToken
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		var err error
+		sessions, err = tx.GetUserRefreshTokensByUserId(userId, offset, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return userId, newRefreshToken, nil
+	return sessions, nil
+}
+
+// RevokeUserSession はユーザのセッションを終了させます。
+// リフレッシュはできなくなり、セッションがなくなるので発行済みのアクセストークンもその時点で使えなくなります。
+func (s *AuthService) RevokeUserSession(userId, sessionId uint) error {
+	return s.store.Transaction(func(tx AuthServiceStore) error {
+		refreshTokenInDB, err := tx.GetUserRefreshTokenById(sessionId)
+		if err != nil {
+			return err
+		}
+		if refreshTokenInDB == nil || refreshTokenInDB.UserID != userId {
+			return &ErrSessionNotFound{SessionId: sessionId}
+		}
+
+		return tx.DeleteUserRefreshToken(sessionId)
+	})
 }
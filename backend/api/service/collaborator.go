@@ -0,0 +1,301 @@
+package service
+
+import (
+	"errors"
+	"gityard-api/model"
+)
+
+// Collaborator はコラボレーターのユーザとハンドルネーム、役割の組です。
+type Collaborator struct {
+	UserId     uint
+	Handlename string
+	Role       model.RepositoryRole
+}
+
+// getAdministrableRepository は管理者権限が必要な操作の対象リポジトリを取得します。
+func getAdministrableRepository(tx permissionStore, userId uint, owner, name string) (*model.Account, *model.Repository, error) {
+	account, repo, permission, err := getOwnerAndRepository(tx, &userId, owner, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if permission < PermissionAdmin {
+		return nil, nil, &ErrPermissionDenied{UserId: userId}
+	}
+	return account, repo, nil
+}
+
+func (s *RepoService) GetRepositoryCollaborators(userId uint, owner, name string, offset, limit int) ([]Collaborator, error) {
+	var collaborators []Collaborator
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repo, permission, err := getOwnerAndRepository(tx, &userId, owner, name)
+		if err != nil {
+			return err
+		}
+		// 一覧はpushできるユーザにだけ見せる
+		if permission < PermissionWrite {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		collaboratorsInDB, err := tx.GetRepositoryCollaborators(repo.ID, offset, limit)
+		if err != nil {
+			return err
+		}
+
+		userIds := make([]uint, 0, len(collaboratorsInDB))
+		for _, c := range collaboratorsInDB {
+			userIds = append(userIds, c.UserID)
+		}
+		accounts, err := tx.GetPersonalAccountsByUserIds(userIds)
+		if err != nil {
+			return err
+		}
+
+		collaborators = make([]Collaborator, 0, len(collaboratorsInDB))
+		for _, c := range collaboratorsInDB {
+			collaborators = append(collaborators, Collaborator{
+				UserId:     c.UserID,
+				Handlename: accounts[c.UserID].Handlename.Handlename,
+				Role:       model.RepositoryRole(c.Role),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return collaborators, nil
+}
+
+// InviteRepositoryCollaborator はユーザをコラボレーターに招待します。招待が承認されるまでアクセス権は与えません。
+func (s *RepoService) InviteRepositoryCollaborator(userId uint, owner, name, inviteeHandlename string, role model.RepositoryRole) (*model.RepositoryInvitation, error) {
+	var invitation *model.RepositoryInvitation
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		account, repo, err := getAdministrableRepository(tx, userId, owner, name)
+		if err != nil {
+			return err
+		}
+
+		invitee, err := getUserByHandlename(tx, inviteeHandlename)
+		if err != nil {
+			return err
+		}
+		if invitee == nil {
+			return &ErrUserNotFound{}
+		}
+		// 所有者は常に管理者なので招待できない
+		if isRepositoryOwner(&invitee.ID, account) {
+			return &ErrInvalidCollaborator{RepositoryId: repo.ID, UserId: invitee.ID}
+		}
+
+		collaborator, err := tx.GetRepositoryCollaborator(repo.ID, invitee.ID)
+		if err != nil {
+			return err
+		}
+		if collaborator != nil {
+			return &ErrRegisteredCollaborator{RepositoryId: repo.ID, UserId: invitee.ID}
+		}
+
+		pending, err := tx.GetPendingRepositoryInvitation(repo.ID, invitee.ID)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			return &ErrRegisteredInvitation{RepositoryId: repo.ID, UserId: invitee.ID}
+		}
+
+		invitation, err = tx.CreateRepositoryInvitation(repo.ID, invitee.ID, userId, role)
+		if err != nil {
+			return err
+		}
+
+		if invitee.Email == nil {
+			return nil
+		}
+		inviter, err := tx.GetPersonalAccountByUserId(userId)
+		if err != nil {
+			return err
+		}
+		if inviter == nil {
+			return &ErrUserNotFound{UserId: userId}
+		}
+		return enqueueRepositoryInvitationMail(tx, s.cfg, *invitee.Email, invitee.Locale, repositoryInvitationMailData{
+			Inviter:    inviter.Handlename.Handlename,
+			Repository: account.Handlename.Handlename + "/" + repo.Name,
+			Role:       role.String(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+func (s *RepoService) GetRepositoryInvitations(userId uint, owner, name string, offset, limit int) ([]model.RepositoryInvitation, error) {
+	var invitations []model.RepositoryInvitation
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repo, err := getAdministrableRepository(tx, userId, owner, name)
+		if err != nil {
+			return err
+		}
+
+		invitations, err = tx.GetPendingRepositoryInvitationsByRepositoryId(repo.ID, offset, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// CancelRepositoryInvitation は保留中の招待を取り消します。
+func (s *RepoService) CancelRepositoryInvitation(userId uint, owner, name string, invitationId uint) error {
+	return s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repo, err := getAdministrableRepository(tx, userId, owner, name)
+		if err != nil {
+			return err
+		}
+
+		invitation, err := tx.GetRepositoryInvitationById(invitationId)
+		if err != nil {
+			return err
+		}
+		if invitation == nil ||
+			invitation.RepositoryID != repo.ID ||
+			invitation.Status != int(model.InvitationPending) {
+			return &ErrInvitationNotFound{InvitationId: invitationId}
+		}
+
+		return tx.DeleteRepositoryInvitation(invitation.ID)
+	})
+}
+
+func (s *RepoService) UpdateRepositoryCollaborator(userId uint, owner, name, collaboratorHandlename string, role model.RepositoryRole) error {
+	return s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repo, err := getAdministrableRepository(tx, userId, owner, name)
+		if err != nil {
+			return err
+		}
+
+		collaborator, err := getCollaboratorByHandlename(tx, repo, collaboratorHandlename)
+		if err != nil {
+			return err
+		}
+
+		return tx.UpdateRepositoryCollaboratorRole(repo.ID, collaborator.UserID, role)
+	})
+}
+
+// RemoveRepositoryCollaborator はコラボレーターを外します。管理者のほか、コラボレーター本人も自分を外せます。
+func (s *RepoService) RemoveRepositoryCollaborator(userId uint, owner, name, collaboratorHandlename string) error {
+	return s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repo, permission, err := getOwnerAndRepository(tx, &userId, owner, name)
+		if err != nil {
+			return err
+		}
+
+		collaborator, err := getCollaboratorByHandlename(tx, repo, collaboratorHandlename)
+		if err != nil {
+			return err
+		}
+		if permission < PermissionAdmin && collaborator.UserID != userId {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		return tx.DeleteRepositoryCollaborator(repo.ID, collaborator.UserID)
+	})
+}
+
+func getCollaboratorByHandlename(tx RepoServiceStore, repo *model.Repository, handlename string) (*model.RepositoryCollaborator, error) {
+	user, err := getUserByHandlename(tx, handlename)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, &ErrCollaboratorNotFound{RepositoryId: repo.ID, Handlename: handlename}
+	}
+
+	collaborator, err := tx.GetRepositoryCollaborator(repo.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if collaborator == nil {
+		return nil, &ErrCollaboratorNotFound{RepositoryId: repo.ID, Handlename: handlename}
+	}
+
+	return collaborator, nil
+}
+
+// GetReceivedRepositoryInvitations はユーザ宛ての保留中の招待を返します。
+func (s *RepoService) GetReceivedRepositoryInvitations(userId uint, offset, limit int) ([]model.RepositoryInvitation, error) {
+	var invitations []model.RepositoryInvitation
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		var err error
+		invitations, err = tx.GetPendingRepositoryInvitationsByInviteeUserId(userId, offset, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// AcceptRepositoryInvitation は招待を承認してコラボレーターとして登録します。
+func (s *RepoService) AcceptRepositoryInvitation(userId, invitationId uint) error {
+	return s.store.Transaction(func(tx RepoServiceStore) error {
+		invitation, err := getReceivedPendingInvitation(tx, userId, invitationId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateRepositoryCollaborator(invitation.RepositoryID,
+			userId,
+			model.RepositoryRole(invitation.Role),
+		)
+		if err != nil {
+			// 招待の後に別経路で登録済みになっていた場合は招待の役割で上書きする
+			if !errors.Is(err, ErrDuplicatedKey) {
+				return err
+			}
+			err = tx.UpdateRepositoryCollaboratorRole(invitation.RepositoryID,
+				userId,
+				model.RepositoryRole(invitation.Role),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.UpdateRepositoryInvitationStatus(invitation.ID, model.InvitationAccepted)
+	})
+}
+
+func (s *RepoService) DeclineRepositoryInvitation(userId, invitationId uint) error {
+	return s.store.Transaction(func(tx RepoServiceStore) error {
+		invitation, err := getReceivedPendingInvitation(tx, userId, invitationId)
+		if err != nil {
+			return err
+		}
+
+		return tx.UpdateRepositoryInvitationStatus(invitation.ID, model.InvitationDeclined)
+	})
+}
+
+// getReceivedPendingInvitation はユーザ宛ての保留中の招待を取得します。他人宛ての招待は存在しないものとして扱います。
+func getReceivedPendingInvitation(tx RepoStore, userId, invitationId uint) (*model.RepositoryInvitation, error) {
+	invitation, err := tx.GetRepositoryInvitationById(invitationId)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil ||
+		invitation.InviteeUserID != userId ||
+		invitation.Status != int(model.InvitationPending) {
+		return nil, &ErrInvitationNotFound{InvitationId: invitationId}
+	}
+
+	return invitation, nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"gityard-api/config"
+	"gityard-api/model"
+	"gityard-api/security"
+	"time"
+)
+
+// createEmailVerificationToken はメールアドレスの確認用のトークンを作り直します。以前に送ったリンクは使えなくなります。
+func createEmailVerificationToken(tx VerificationTokenStore, cfg *config.Config, userId uint, email string) (string, error) {
+	if err := tx.DeleteUserVerificationTokensByUserId(userId, model.EmailVerificationToken); err != nil {
+		return "", err
+	}
+
+	token := security.GenerateVerificationToken()
+	expiresAt := time.Now().Add(cfg.Token.EmailVerificationTokenTTL)
+	if _, err := tx.CreateUserVerificationToken(userId, model.EmailVerificationToken, token, email, expiresAt); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// useVerificationToken はメールで送ったトークンを使用済みにして、その持ち主を返します。
+// 期限切れや、送ったあとにメールアドレスが変わったトークンでは nil を返します。
+func useVerificationToken(tx AuthServiceStore, kind model.VerificationTokenKind, token string) (*model.User, error) {
+	tokenInDB, err := tx.GetUserVerificationTokenByToken(kind, token)
+	if err != nil {
+		return nil, err
+	}
+	if tokenInDB == nil {
+		return nil, nil
+	}
+
+	if err := tx.DeleteUserVerificationTokensByUserId(tokenInDB.UserID, kind); err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(tokenInDB.ExpiresAt) {
+		return nil, nil
+	}
+
+	userInDB, err := tx.GetUserById(tokenInDB.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if userInDB == nil || userInDB.Email == nil || *userInDB.Email != tokenInDB.Email {
+		return nil, nil
+	}
+
+	return userInDB, nil
+}
+
+// GetEmail はユーザのメールアドレスと確認済みかどうかを返します。
+func (s *AuthService) GetEmail(userId uint) (*model.User, error) {
+	userInDB, err := s.store.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if userInDB == nil || userInDB.Email == nil {
+		return nil, &ErrUserNotFound{UserId: userId}
+	}
+
+	return userInDB, nil
+}
+
+// ResendEmailVerification はメールアドレスの確認用のリンクを送り直します。
+func (s *AuthService) ResendEmailVerification(userId uint) error {
+	return s.store.Transaction(func(tx AuthServiceStore) error {
+		userInDB, err := tx.GetUserById(userId)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil || userInDB.Email == nil {
+			return &ErrUserNotFound{UserId: userId}
+		}
+		if userInDB.EmailVerifiedAt != nil {
+			return &ErrEmailAlreadyVerified{UserId: userId}
+		}
+		email := *userInDB.Email
+
+		token, err := createEmailVerificationToken(tx, s.cfg, userId, email)
+		if err != nil {
+			return err
+		}
+		return enqueueEmailVerificationMail(tx, s.cfg, email, userInDB.Locale, token)
+	})
+}
+
+// VerifyEmail はメールで送ったトークンを確認してメールアドレスを確認済みにします。
+func (s *AuthService) VerifyEmail(token string) error {
+	var rejected error
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		userInDB, err := useVerificationToken(tx, model.EmailVerificationToken, token)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil {
+			// 使ったトークンの削除をコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrInvalidVerificationTokenProvided{}
+			return nil
+		}
+		if userInDB.EmailVerifiedAt != nil {
+			return nil
+		}
+
+		return tx.UpdateUserEmailVerifiedAt(userInDB.ID, time.Now())
+	})
+	if err != nil {
+		return err
+	}
+
+	return rejected
+}
@@ -57,6 +57,15 @@ func (err *ErrExpiredRefreshTokenProvided) Error() string {
 	return fmt.Sprintf("Expired RefreshToken Provided")
 }
 
+// ErrRefreshTokenReused はローテーション済みのリフレッシュトークンが使われた場合のエラーです。
+type ErrRefreshTokenReused struct {
+	SessionId uint
+}
+
+func (err *ErrRefreshTokenReused) Error() string {
+	return fmt.Sprintf("Refresh Token Reused: session_id=%v", err.SessionId)
+}
+
 type ErrInvalidPubkeyProvided struct {
 }
 
@@ -70,3 +79,326 @@ type ErrDuplicatesPubkeyFingerprint struct {
 func (err *ErrDuplicatesPubkeyFingerprint) Error() string {
 	return fmt.Sprintf("Registered Fingerprint")
 }
+
+type ErrAccountNotFound struct {
+	AccountId  uint
+	Handlename string
+}
+
+func (err *ErrAccountNotFound) Error() string {
+	return fmt.Sprintf("Account Not Found: account_id=%v, handlename=%v", err.AccountId, err.Handlename)
+}
+
+type ErrRepositoryNotFound struct {
+	Owner string
+	Name  string
+}
+
+func (err *ErrRepositoryNotFound) Error() string {
+	return fmt.Sprintf("Repository Not Found: owner=%v, name=%v", err.Owner, err.Name)
+}
+
+type ErrRegisteredRepositoryName struct {
+	OwnerAccountId uint
+	Name           string
+}
+
+func (err *ErrRegisteredRepositoryName) Error() string {
+	return fmt.Sprintf("Registered Repository Name: owner_account_id=%v, name=%v", err.OwnerAccountId, err.Name)
+}
+
+type ErrPermissionDenied struct {
+	UserId uint
+}
+
+func (err *ErrPermissionDenied) Error() string {
+	return fmt.Sprintf("Permission Denied: user_id=%v", err.UserId)
+}
+
+type ErrCollaboratorNotFound struct {
+	RepositoryId uint
+	Handlename   string
+}
+
+func (err *ErrCollaboratorNotFound) Error() string {
+	return fmt.Sprintf("Collaborator Not Found: repository_id=%v, handlename=%v", err.RepositoryId, err.Handlename)
+}
+
+type ErrRegisteredCollaborator struct {
+	RepositoryId uint
+	UserId       uint
+}
+
+func (err *ErrRegisteredCollaborator) Error() string {
+	return fmt.Sprintf("Registered Collaborator: repository_id=%v, user_id=%v", err.RepositoryId, err.UserId)
+}
+
+type ErrInvalidCollaborator struct {
+	RepositoryId uint
+	UserId       uint
+}
+
+func (err *ErrInvalidCollaborator) Error() string {
+	return fmt.Sprintf("Invalid Collaborator: repository_id=%v, user_id=%v", err.RepositoryId, err.UserId)
+}
+
+type ErrInvitationNotFound struct {
+	InvitationId uint
+}
+
+func (err *ErrInvitationNotFound) Error() string {
+	return fmt.Sprintf("Invitation Not Found: invitation_id=%v", err.InvitationId)
+}
+
+type ErrRegisteredInvitation struct {
+	RepositoryId uint
+	UserId       uint
+}
+
+func (err *ErrRegisteredInvitation) Error() string {
+	return fmt.Sprintf("Registered Invitation: repository_id=%v, user_id=%v", err.RepositoryId, err.UserId)
+}
+
+type ErrLastOrganizationOwner struct {
+	OrganizationAccountId uint
+}
+
+func (err *ErrLastOrganizationOwner) Error() string {
+	return fmt.Sprintf("Last Organization Owner: organization_account_id=%v", err.OrganizationAccountId)
+}
+
+type ErrOrganizationMemberNotFound struct {
+	OrganizationAccountId uint
+	Handlename            string
+}
+
+func (err *ErrOrganizationMemberNotFound) Error() string {
+	return fmt.Sprintf("Organization Member Not Found: organization_account_id=%v, handlename=%v", err.OrganizationAccountId, err.Handlename)
+}
+
+type ErrTeamNotFound struct {
+	OrganizationAccountId uint
+	Name                  string
+}
+
+func (err *ErrTeamNotFound) Error() string {
+	return fmt.Sprintf("Team Not Found: organization_account_id=%v, name=%v", err.OrganizationAccountId, err.Name)
+}
+
+type ErrRegisteredTeamName struct {
+	OrganizationAccountId uint
+	Name                  string
+}
+
+func (err *ErrRegisteredTeamName) Error() string {
+	return fmt.Sprintf("Registered Team Name: organization_account_id=%v, name=%v", err.OrganizationAccountId, err.Name)
+}
+
+// ErrInvalidParentTeam は自分自身や子孫のチームを親チームにしようとした場合のエラーです。
+type ErrInvalidParentTeam struct {
+	TeamId       uint
+	ParentTeamId uint
+}
+
+func (err *ErrInvalidParentTeam) Error() string {
+	return fmt.Sprintf("Invalid Parent Team: team_id=%v, parent_team_id=%v", err.TeamId, err.ParentTeamId)
+}
+
+type ErrTeamMemberNotFound struct {
+	TeamId     uint
+	Handlename string
+}
+
+func (err *ErrTeamMemberNotFound) Error() string {
+	return fmt.Sprintf("Team Member Not Found: team_id=%v, handlename=%v", err.TeamId, err.Handlename)
+}
+
+type ErrTeamRepositoryNotFound struct {
+	TeamId uint
+	Name   string
+}
+
+func (err *ErrTeamRepositoryNotFound) Error() string {
+	return fmt.Sprintf("Team Repository Not Found: team_id=%v, name=%v", err.TeamId, err.Name)
+}
+
+type ErrSessionNotFound struct {
+	SessionId uint
+}
+
+func (err *ErrSessionNotFound) Error() string {
+	return fmt.Sprintf("Session Not Found: session_id=%v", err.SessionId)
+}
+
+type ErrInvalidAccessTokenProvided struct {
+}
+
+func (err *ErrInvalidAccessTokenProvided) Error() string {
+	return fmt.Sprintf("Invalid AccessToken Provided")
+}
+
+type ErrExpiredAccessTokenProvided struct {
+	TokenId uint
+}
+
+func (err *ErrExpiredAccessTokenProvided) Error() string {
+	return fmt.Sprintf("Expired AccessToken Provided: token_id=%v", err.TokenId)
+}
+
+type ErrRevokedAccessTokenProvided struct {
+	UserId  uint
+	TokenId string
+}
+
+func (err *ErrRevokedAccessTokenProvided) Error() string {
+	return fmt.Sprintf("Revoked AccessToken Provided: user_id=%v, token_id=%v", err.UserId, err.TokenId)
+}
+
+type ErrAccessTokenNotFound struct {
+	TokenId uint
+}
+
+func (err *ErrAccessTokenNotFound) Error() string {
+	return fmt.Sprintf("AccessToken Not Found: token_id=%v", err.TokenId)
+}
+
+type ErrTwoFactorAlreadyEnabled struct {
+	UserId uint
+}
+
+func (err *ErrTwoFactorAlreadyEnabled) Error() string {
+	return fmt.Sprintf("Two Factor Already Enabled: user_id=%v", err.UserId)
+}
+
+type ErrTwoFactorNotEnabled struct {
+	UserId uint
+}
+
+func (err *ErrTwoFactorNotEnabled) Error() string {
+	return fmt.Sprintf("Two Factor Not Enabled: user_id=%v", err.UserId)
+}
+
+// ErrTwoFactorEnrollmentNotFound は登録を始めていないのに二要素認証を有効にしようとした場合のエラーです。
+type ErrTwoFactorEnrollmentNotFound struct {
+	UserId uint
+}
+
+func (err *ErrTwoFactorEnrollmentNotFound) Error() string {
+	return fmt.Sprintf("Two Factor Enrollment Not Found: user_id=%v", err.UserId)
+}
+
+type ErrInvalidTwoFactorCode struct {
+	UserId uint
+}
+
+func (err *ErrInvalidTwoFactorCode) Error() string {
+	return fmt.Sprintf("Invalid Two Factor Code: user_id=%v", err.UserId)
+}
+
+type ErrInvalidTwoFactorChallengeProvided struct {
+}
+
+func (err *ErrInvalidTwoFactorChallengeProvided) Error() string {
+	return fmt.Sprintf("Invalid Two Factor Challenge Provided")
+}
+
+// ErrTwoFactorRequired は二要素認証を有効にしていないユーザがpushしようとした場合のエラーです。
+type ErrTwoFactorRequired struct {
+	UserId uint
+}
+
+func (err *ErrTwoFactorRequired) Error() string {
+	return fmt.Sprintf("Two Factor Required: user_id=%v", err.UserId)
+}
+
+// ErrPasswordAuthenticationDisabled は二要素認証を有効にしたユーザがパスワードだけでgitの認証をしようとした場合のエラーです。
+type ErrPasswordAuthenticationDisabled struct {
+	UserId uint
+}
+
+func (err *ErrPasswordAuthenticationDisabled) Error() string {
+	return fmt.Sprintf("Password Authentication Disabled: user_id=%v", err.UserId)
+}
+
+type ErrWebAuthnCredentialNotFound struct {
+	CredentialId uint
+}
+
+func (err *ErrWebAuthnCredentialNotFound) Error() string {
+	return fmt.Sprintf("WebAuthn Credential Not Found: credential_id=%v", err.CredentialId)
+}
+
+// ErrWebAuthnCredentialAlreadyRegistered は同じ認証器のクレデンシャルを二重に登録しようとした場合のエラーです。
+type ErrWebAuthnCredentialAlreadyRegistered struct {
+	UserId uint
+}
+
+func (err *ErrWebAuthnCredentialAlreadyRegistered) Error() string {
+	return fmt.Sprintf("WebAuthn Credential Already Registered: user_id=%v", err.UserId)
+}
+
+type ErrInvalidWebAuthnCeremonyProvided struct {
+}
+
+func (err *ErrInvalidWebAuthnCeremonyProvided) Error() string {
+	return fmt.Sprintf("Invalid WebAuthn Ceremony Provided")
+}
+
+// ErrInvalidWebAuthnResponse は認証器のレスポンスの検証に失敗した場合のエラーです。
+type ErrInvalidWebAuthnResponse struct {
+	Detail error
+}
+
+func (err *ErrInvalidWebAuthnResponse) Error() string {
+	return fmt.Sprintf("Invalid WebAuthn Response: %v", err.Detail)
+}
+
+// ErrWebAuthnCredentialCloned は署名カウンタが巻き戻り、クレデンシャルの複製が疑われる場合のエラーです。
+type ErrWebAuthnCredentialCloned struct {
+	UserId       uint
+	CredentialId uint
+}
+
+func (err *ErrWebAuthnCredentialCloned) Error() string {
+	return fmt.Sprintf("WebAuthn Credential Cloned: user_id=%v, credential_id=%v", err.UserId, err.CredentialId)
+}
+
+// ErrInvalidVerificationTokenProvided はメールで送ったトークンが見つからないか、期限切れか、使えなくなった場合のエラーです。
+type ErrInvalidVerificationTokenProvided struct {
+}
+
+func (err *ErrInvalidVerificationTokenProvided) Error() string {
+	return fmt.Sprintf("Invalid Verification Token Provided")
+}
+
+type ErrEmailAlreadyVerified struct {
+	UserId uint
+}
+
+func (err *ErrEmailAlreadyVerified) Error() string {
+	return fmt.Sprintf("Email Already Verified: user_id=%v", err.UserId)
+}
+
+type ErrAccountDeletionAlreadyScheduled struct {
+	UserId uint
+}
+
+func (err *ErrAccountDeletionAlreadyScheduled) Error() string {
+	return fmt.Sprintf("Account Deletion Already Scheduled: user_id=%v", err.UserId)
+}
+
+type ErrAccountDeletionNotScheduled struct {
+	UserId uint
+}
+
+func (err *ErrAccountDeletionNotScheduled) Error() string {
+	return fmt.Sprintf("Account Deletion Not Scheduled: user_id=%v", err.UserId)
+}
+
+type ErrInvalidAvatar struct {
+	Detail error
+}
+
+func (err *ErrInvalidAvatar) Error() string {
+	return fmt.Sprintf("Invalid Avatar: detail=%v", err.Detail)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"gityard-api/model"
+	"gityard-api/security"
+	"strings"
+)
+
+// AuthenticateGitUser はgit over HTTPのBasic認証を検証してユーザIDを返します。
+// パスワードにはアカウントのパスワード、アクセストークン、パーソナルアクセストークンを受け付けます。
+// ユーザ名にはメールアドレスかハンドルネームを指定します。
+// パーソナルアクセストークンの場合だけ、許可されたスコープを返します。それ以外は nil です。
+func (s *RepoService) AuthenticateGitUser(username, password string) (uint, []security.Scope, error) {
+	// トークンの場合はユーザ名を見ない
+	if security.IsPersonalAccessToken(password) {
+		var userId uint
+		var scopes []security.Scope
+		err := s.store.Transaction(func(tx RepoServiceStore) error {
+			var err error
+			userId, scopes, err = authenticatePersonalAccessToken(tx, password)
+			return err
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+		return userId, scopes, nil
+	}
+	// アクセストークンは AuthHeaderProtection と同じく、ログアウトなどで失効したものも拒否する
+	claims, err := authenticateAccessToken(s.store, password)
+	if err == nil {
+		return claims.UserId, nil, nil
+	}
+	var invalidErr *ErrInvalidAccessTokenProvided
+	if !errors.As(err, &invalidErr) {
+		return 0, nil, err
+	}
+
+	var userId uint
+	err = s.store.Transaction(func(tx RepoServiceStore) error {
+		var user *model.User
+		var err error
+		if strings.Contains(username, "@") {
+			user, err = tx.GetUserByEmail(username)
+		} else {
+			// 保存された認証情報が改名前のハンドルネームのままでも使えるようにする
+			var account *model.Account
+			account, err = getAccountByHandlename(tx, username)
+			if err != nil {
+				return err
+			}
+			user, err = getPersonalAccountUser(tx, account)
+		}
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return &ErrUserNotFound{Email: username}
+		}
+
+		credInDB, err := tx.GetUserCredentialById(user.ID)
+		if err != nil {
+			return err
+		}
+		if credInDB == nil {
+			return &ErrCredentialNotFound{UserId: user.ID}
+		}
+		if !security.VerifyPassword(password, credInDB.HashedPassword) {
+			return &ErrPasswordMissMatch{UserId: user.ID}
+		}
+		// パスワードだけで二要素認証を迂回させない
+		twoFactorEnabled, err := isTwoFactorEnabled(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if twoFactorEnabled {
+			return &ErrPasswordAuthenticationDisabled{UserId: user.ID}
+		}
+		userId = user.ID
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return userId, nil, nil
+}
+
+// getUserByHandlename は個人アカウントのハンドルネームからユーザを取得します。
+func getUserByHandlename(tx userAccountStore, handlename string) (*model.User, error) {
+	account, err := tx.GetAccountByHandlename(handlename)
+	if err != nil {
+		return nil, err
+	}
+	return getPersonalAccountUser(tx, account)
+}
+
+// getPersonalAccountUser は個人アカウントのユーザを取得します。個人アカウントでなければ nil を返します。
+func getPersonalAccountUser(tx UserStore, account *model.Account) (*model.User, error) {
+	if account == nil || account.Kind != int(model.PersonalAccount) || account.UserID == nil {
+		return nil, nil
+	}
+	return tx.GetUserById(*account.UserID)
+}
+
+// GetRepositoryForGit はgitの操作対象のリポジトリを取得します。
+// 読めないリポジトリは ErrRepositoryNotFound、書き込めない場合は ErrPermissionDenied を返します。
+// セキュリティポリシーにより、pushできるのは二要素認証を有効にしたユーザだけです。
+func (s *RepoService) GetRepositoryForGit(userId *uint, owner, name string, write bool) (*model.Repository, error) {
+	access, err := s.GetRepositoryAccess(userId, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	if write && access.Permission < PermissionWrite {
+		var id uint
+		if userId != nil {
+			id = *userId
+		}
+		return nil, &ErrPermissionDenied{UserId: id}
+	}
+	if write && access.TwoFactorRequired {
+		return nil, &ErrTwoFactorRequired{UserId: *userId}
+	}
+
+	return access.Repository, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"gityard-api/config"
+	"gityard-api/model"
+	"time"
+)
+
+// claimHandlename はハンドルネームを新しく割り当てるために確保します。
+// 使用中か、まだ転送に使っているハンドルネームは ErrRegisteredHandleName になります。
+// ただし accountId が改名前に使っていたハンドルネームなら、転送をやめてそのまま返します。
+func claimHandlename(tx AccountStore, name string, accountId uint) (*model.Handlename, error) {
+	handlenameInDB, err := tx.GetHandleNameByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if handlenameInDB != nil {
+		if handlenameInDB.RedirectAccountID == nil { // 使用中
+			return nil, &ErrRegisteredHandleName{HandleName: name}
+		}
+		if accountId != 0 && *handlenameInDB.RedirectAccountID == accountId { // 元の名前に戻す
+			if err := tx.UpdateHandleNameRedirect(handlenameInDB.ID, nil, nil); err != nil {
+				return nil, err
+			}
+			handlenameInDB.RedirectAccountID = nil
+			handlenameInDB.RedirectExpiresAt = nil
+			return handlenameInDB, nil
+		}
+		if handlenameInDB.RedirectExpiresAt != nil && time.Now().Before(*handlenameInDB.RedirectExpiresAt) {
+			// 古いリモートを他人のリポジトリに向けないため、転送の期限までは使わせない
+			return nil, &ErrRegisteredHandleName{HandleName: name}
+		}
+		if err := tx.DeleteHandleName(handlenameInDB.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	handlename, err := tx.CreateHandleName(name)
+	if err != nil {
+		if errors.Is(err, ErrDuplicatedKey) {
+			return nil, &ErrRegisteredHandleName{HandleName: name}
+		}
+		return nil, err
+	}
+	return handlename, nil
+}
+
+// getAccountByHandlename はハンドルネームからアカウントを取得します。改名前のハンドルネームなら転送先のアカウントを返します。
+func getAccountByHandlename(tx AccountStore, name string) (*model.Account, error) {
+	account, err := tx.GetAccountByHandlename(name)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil {
+		return account, nil
+	}
+	return tx.GetAccountByRedirectedHandlename(name, time.Now())
+}
+
+// RenameHandlename はユーザの個人アカウントのハンドルネームを変更します。
+// 改名前のハンドルネームは転送の期限まで予約しておき、その間は古いURLやリモートでもリポジトリにアクセスできます。
+func (s *AccountService) RenameHandlename(userId uint, newHandlename string) (*model.Account, error) {
+	var account *model.Account
+	err := s.store.Transaction(func(tx AccountServiceStore) error {
+		var err error
+		account, err = tx.GetPersonalAccountByUserId(userId)
+		if err != nil {
+			return err
+		}
+		if account == nil || account.IsDeleted || account.HandlenameID == nil {
+			return &ErrAccountNotFound{}
+		}
+		if account.Handlename.Handlename == newHandlename {
+			return nil
+		}
+
+		handlename, err := claimHandlename(tx, newHandlename, account.ID)
+		if err != nil {
+			return err
+		}
+		oldHandlenameId := *account.HandlenameID
+
+		if err := tx.UpdateAccountHandlenameId(account.ID, handlename.ID); err != nil {
+			return err
+		}
+		expiresAt := time.Now().Add(time.Hour * 24 * config.HandlenameRedirectActiveDurationDays)
+		if err := tx.UpdateHandleNameRedirect(oldHandlenameId, &account.ID, &expiresAt); err != nil {
+			return err
+		}
+
+		account.HandlenameID = &handlename.ID
+		account.Handlename = *handlename
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
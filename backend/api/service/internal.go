@@ -0,0 +1,89 @@
+package service
+
+import (
+	"gityard-api/model"
+)
+
+// GetPubkeyOwner はSSH公開鍵のフィンガープリントから鍵の持ち主と個人アカウントを返します。
+func (s *KeyService) GetPubkeyOwner(fingerprint string) (*model.User, *model.Account, error) {
+	var user *model.User
+	var account *model.Account
+	err := s.store.Transaction(func(tx KeyServiceStore) error {
+		pubkey, err := tx.GetPubKeyByFingerprint(fingerprint)
+		if err != nil {
+			return err
+		}
+		if pubkey == nil {
+			return &ErrUserNotFound{}
+		}
+
+		userInDB, err := tx.GetUserById(pubkey.UserID)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil || userInDB.IsDeleted {
+			return &ErrUserNotFound{UserId: pubkey.UserID}
+		}
+		user = userInDB
+
+		accountInDB, err := tx.GetPersonalAccountByUserId(pubkey.UserID)
+		if err != nil {
+			return err
+		}
+		if accountInDB == nil {
+			return &ErrAccountNotFound{}
+		}
+		account = accountInDB
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, account, nil
+}
+
+// RepositoryAccess はgitの操作に使うユーザのリポジトリに対する権限です。
+type RepositoryAccess struct {
+	Repository *model.Repository
+	Permission RepositoryPermission
+	// TwoFactorRequired は書き込み権限があるのに二要素認証を有効にしていないためpushできないことを表します。
+	TwoFactorRequired bool
+}
+
+// CanWrite はpushできるかを返します。
+func (access *RepositoryAccess) CanWrite() bool {
+	return access.Permission >= PermissionWrite && !access.TwoFactorRequired
+}
+
+// GetRepositoryAccess はユーザのリポジトリに対する権限を返します。
+// 読めないリポジトリは ErrRepositoryNotFound を返します。
+func (s *RepoService) GetRepositoryAccess(userId *uint, owner, name string) (*RepositoryAccess, error) {
+	var access *RepositoryAccess
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repo, permission, err := getOwnerAndRepository(tx, userId, owner, name)
+		if err != nil {
+			return err
+		}
+		access = &RepositoryAccess{
+			Repository: repo,
+			Permission: permission,
+		}
+
+		if permission >= PermissionWrite {
+			twoFactorEnabled, err := isTwoFactorEnabled(tx, *userId)
+			if err != nil {
+				return err
+			}
+			access.TwoFactorRequired = !twoFactorEnabled
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return access, nil
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"gityard-api/config"
+	"gityard-api/mail"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MailService は送信待ちのメールを送ります。
+type MailService struct {
+	store  MailStore
+	mailer mail.Mailer
+	cfg    *config.Config
+}
+
+// NewMailService は送信待ちのメールを mailer で送る MailService を作成します。
+func NewMailService(store MailStore, mailer mail.Mailer, cfg *config.Config) *MailService {
+	return &MailService{store: store, mailer: mailer, cfg: cfg}
+}
+
+const (
+	mailOutboxBatchSize   = 10
+	maxMailOutboxAttempts = 8 // 最後の再送は最初の送信からおよそ1時間後
+)
+
+// webURL はメールに載せるWebページのURLを返します。
+func webURL(cfg *config.Config, path string, query url.Values) string {
+	u := cfg.WebBaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// enqueueMail はテンプレートからメールを作って送信待ちにします。業務の変更と同じトランザクションで呼んでください。
+// ロールバックすればメールも送られず、コミットすればワーカーが必ず送信を試みます。
+func enqueueMail(tx MailStore, to, locale string, name mail.Template, data any) error {
+	msg, err := mail.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateMailOutbox(to, msg.Subject, msg.Text, msg.HTML, time.Now())
+	return err
+}
+
+type emailVerificationMailData struct {
+	Link           string
+	ExpiresInHours int
+}
+
+func enqueueEmailVerificationMail(tx MailStore, cfg *config.Config, to, locale, token string) error {
+	return enqueueMail(tx, to, locale, mail.EmailVerificationTemplate, emailVerificationMailData{
+		Link:           webURL(cfg, "/verify-email", url.Values{"token": {token}}),
+		ExpiresInHours: int(cfg.Token.EmailVerificationTokenTTL.Hours()),
+	})
+}
+
+type passwordResetMailData struct {
+	Link             string
+	ExpiresInMinutes int
+}
+
+func enqueuePasswordResetMail(tx MailStore, cfg *config.Config, to, locale, token string) error {
+	return enqueueMail(tx, to, locale, mail.PasswordResetTemplate, passwordResetMailData{
+		Link:             webURL(cfg, "/reset-password", url.Values{"token": {token}}),
+		ExpiresInMinutes: int(cfg.Token.PasswordResetTokenTTL.Minutes()),
+	})
+}
+
+func enqueuePasswordChangedMail(tx MailStore, to, locale string) error {
+	return enqueueMail(tx, to, locale, mail.PasswordChangedTemplate, nil)
+}
+
+type repositoryInvitationMailData struct {
+	Inviter    string
+	Repository string
+	Role       string
+	Link       string
+}
+
+func enqueueRepositoryInvitationMail(tx MailStore, cfg *config.Config, to, locale string, data repositoryInvitationMailData) error {
+	data.Link = webURL(cfg, "/settings/invitations", nil)
+	return enqueueMail(tx, to, locale, mail.RepositoryInvitationTemplate, data)
+}
+
+type accountDeletionMailData struct {
+	DeleteAt string
+	Link     string
+}
+
+func enqueueAccountDeletionMail(tx MailStore, cfg *config.Config, to, locale string, deleteAt time.Time) error {
+	return enqueueMail(tx, to, locale, mail.AccountDeletionTemplate, accountDeletionMailData{
+		DeleteAt: deleteAt.UTC().Format("2006-01-02 15:04 UTC"),
+		Link:     webURL(cfg, "/settings/account", nil),
+	})
+}
+
+// mailRetryDelay は attempts 回目の送信に失敗したあと、次に試みるまでの時間です。30秒から倍々に延ばします。
+func mailRetryDelay(attempts int) time.Duration {
+	return 30 * time.Second << (attempts - 1)
+}
+
+// DeliverMailOutbox は送信待ちのメールを1回分取り出して送信し、送信できた件数を返します。
+// 失敗したメールは間隔を空けて再送し、上限に達したら諦めます。複数のワーカーが同時に動いても同じメールを二重には取り出しません。
+func (s *MailService) DeliverMailOutbox() (int, error) {
+	outboxes, err := s.store.GetDueMailOutboxes(time.Now(), mailOutboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, outbox := range outboxes {
+		if outbox.Attempts >= maxMailOutboxAttempts {
+			// 取り出したあと結果を記録する前に落ちたメール。上限を超えて送らないように、ここで諦める
+			now := time.Now()
+			slog.Error("gave up sending mail", "outboxId", outbox.ID, "attempts", outbox.Attempts, "detail", outbox.LastError)
+			if err := s.store.UpdateMailOutboxError(outbox.ID, outbox.LastError, &now); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		attempts := outbox.Attempts + 1
+		claimed, err := s.store.ClaimMailOutbox(outbox.ID, outbox.Attempts, time.Now().Add(mailRetryDelay(attempts)))
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		err = s.mailer.Send(&mail.Message{
+			To:      outbox.ToAddress,
+			Subject: outbox.Subject,
+			Text:    outbox.TextBody,
+			HTML:    outbox.HTMLBody,
+		})
+		if err == nil {
+			sent++
+			if err := s.store.DeleteMailOutbox(outbox.ID); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		lastError := err.Error()
+		if len(lastError) > 1000 {
+			// 途中で切れた文字は捨てる
+			lastError = strings.ToValidUTF8(lastError[:1000], "")
+		}
+		var failedAt *time.Time
+		if attempts >= maxMailOutboxAttempts {
+			now := time.Now()
+			failedAt = &now
+			slog.Error("gave up sending mail", "outboxId", outbox.ID, "attempts", attempts, "detail", err)
+		} else {
+			slog.Warn("failed to send mail, will retry", "outboxId", outbox.ID, "attempts", attempts, "detail", err)
+		}
+		if err := s.store.UpdateMailOutboxError(outbox.ID, lastError, failedAt); err != nil {
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// RunMailOutboxWorker は ctx が終わるまで、定期的に送信待ちのメールを送信します。
+func (s *MailService) RunMailOutboxWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Mail.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			sent, err := s.DeliverMailOutbox()
+			if err != nil {
+				slog.Error("failed to deliver mail outbox", "detail", err)
+				break
+			}
+			if sent < mailOutboxBatchSize { // 溜まっていなければ次の周期まで待つ
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
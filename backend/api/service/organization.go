@@ -0,0 +1,339 @@
+package service
+
+import (
+	"gityard-api/model"
+	"gityard-api/storage"
+)
+
+// OrganizationService は組織とそのメンバー、チームを扱います。
+type OrganizationService struct {
+	store OrganizationServiceStore
+	blobs storage.BlobStore
+}
+
+// NewOrganizationService は OrganizationService を作成します。作成した組織のアイコンは blobs に置きます。
+func NewOrganizationService(store OrganizationServiceStore, blobs storage.BlobStore) *OrganizationService {
+	return &OrganizationService{store: store, blobs: blobs}
+}
+
+// OrganizationMember は組織のメンバーとハンドルネーム、役割の組です。
+type OrganizationMember struct {
+	UserId     uint
+	Handlename string
+	Role       model.OrganizationRole
+}
+
+// 組織を作成したときにメンバー全員が持つ役割
+const defaultOrganizationBaseRole = model.RepositoryRoleRead
+
+// getOrganization はハンドルネームから組織アカウントを取得します。個人アカウントの場合は見つからない扱いです。
+func getOrganization(tx AccountStore, handlename string) (*model.Account, error) {
+	account, err := tx.GetAccountByHandlename(handlename)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.Kind != int(model.OrganizationAccount) {
+		return nil, &ErrAccountNotFound{Handlename: handlename}
+	}
+	return account, nil
+}
+
+// getOrganizationRole はユーザの組織での役割を返します。メンバーでなければ 0 です。
+func getOrganizationRole(tx OrganizationStore, userId *uint, organization *model.Account) (model.OrganizationRole, error) {
+	if userId == nil || organization.Kind != int(model.OrganizationAccount) {
+		return 0, nil
+	}
+	member, err := tx.GetOrganizationMember(organization.ID, *userId)
+	if err != nil {
+		return 0, err
+	}
+	if member == nil {
+		return 0, nil
+	}
+	return model.OrganizationRole(member.Role), nil
+}
+
+// getOrganizationPermission はユーザが組織の役割によって組織のリポジトリに持つ権限を返します。
+// オーナーは管理者、メンバーは組織の基本権限です。
+func getOrganizationPermission(tx OrganizationStore, userId *uint, organization *model.Account) (RepositoryPermission, error) {
+	role, err := getOrganizationRole(tx, userId, organization)
+	if err != nil {
+		return PermissionNone, err
+	}
+	switch role {
+	case model.OrganizationRoleOwner:
+		return PermissionAdmin, nil
+	case model.OrganizationRoleMember:
+		setting, err := tx.GetOrganizationByAccountId(organization.ID)
+		if err != nil {
+			return PermissionNone, err
+		}
+		if setting == nil {
+			return PermissionNone, nil
+		}
+		return RepositoryPermission(setting.BaseRole), nil
+	default:
+		return PermissionNone, nil
+	}
+}
+
+// CreateOrganization は組織アカウントを作成し、作成したユーザをオーナーにします。
+func (s *OrganizationService) CreateOrganization(userId uint, handlename, displayname string) (*model.Account, error) {
+	var organization *model.Account
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		registeredHandleName, err := claimHandlename(tx, handlename, 0)
+		if err != nil {
+			return err
+		}
+
+		registeredAccount, err := tx.CreateAccount(nil, registeredHandleName.ID, model.OrganizationAccount)
+		if err != nil {
+			return err
+		}
+		registeredAccount.Handlename = *registeredHandleName
+
+		if displayname == "" {
+			displayname = handlename
+		}
+		iconpath, err := createIdenticon(s.blobs, registeredAccount.ID)
+		if err != nil {
+			return err
+		}
+		profile, err := tx.CreateAccountProfile(registeredAccount.ID, displayname, iconpath, false)
+		if err != nil {
+			return err
+		}
+		registeredAccount.AccountProfile = *profile
+
+		setting, err := tx.CreateOrganization(registeredAccount.ID, defaultOrganizationBaseRole)
+		if err != nil {
+			return err
+		}
+		registeredAccount.Organization = setting
+
+		_, err = tx.CreateOrganizationMember(registeredAccount.ID, userId, model.OrganizationRoleOwner)
+		if err != nil {
+			return err
+		}
+		organization = registeredAccount
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (s *OrganizationService) GetOrganization(handlename string) (*model.Account, error) {
+	var organization *model.Account
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		account, err := getOrganization(tx, handlename)
+		if err != nil {
+			return err
+		}
+
+		profile, err := tx.GetAccountProfileById(account.ID)
+		if err != nil {
+			return err
+		}
+		if profile != nil {
+			account.AccountProfile = *profile
+		}
+		account.Organization, err = tx.GetOrganizationByAccountId(account.ID)
+		if err != nil {
+			return err
+		}
+		organization = account
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+// UpdateOrganizationBaseRole はメンバー全員が組織のリポジトリに持つ役割を変更します。0 は権限なしです。オーナーだけが操作できます。
+func (s *OrganizationService) UpdateOrganizationBaseRole(userId uint, handlename string, baseRole model.RepositoryRole) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getOrganization(tx, handlename)
+		if err != nil {
+			return err
+		}
+		role, err := getOrganizationRole(tx, &userId, organization)
+		if err != nil {
+			return err
+		}
+		if role != model.OrganizationRoleOwner {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		return tx.UpdateOrganizationBaseRole(organization.ID, baseRole)
+	})
+}
+
+// GetOrganizationMembers は組織のメンバー一覧を返します。メンバーだけが見られます。
+func (s *OrganizationService) GetOrganizationMembers(userId uint, handlename string, offset, limit int) ([]OrganizationMember, error) {
+	var members []OrganizationMember
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getOrganization(tx, handlename)
+		if err != nil {
+			return err
+		}
+		role, err := getOrganizationRole(tx, &userId, organization)
+		if err != nil {
+			return err
+		}
+		if role == 0 {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		membersInDB, err := tx.GetOrganizationMembers(organization.ID, offset, limit)
+		if err != nil {
+			return err
+		}
+
+		userIds := make([]uint, 0, len(membersInDB))
+		for _, m := range membersInDB {
+			userIds = append(userIds, m.UserID)
+		}
+		accounts, err := tx.GetPersonalAccountsByUserIds(userIds)
+		if err != nil {
+			return err
+		}
+
+		members = make([]OrganizationMember, 0, len(membersInDB))
+		for _, m := range membersInDB {
+			members = append(members, OrganizationMember{
+				UserId:     m.UserID,
+				Handlename: accounts[m.UserID].Handlename.Handlename,
+				Role:       model.OrganizationRole(m.Role),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetOrganizationMember はユーザを組織に追加するか、既にメンバーであれば役割を変更します。オーナーだけが操作できます。
+func (s *OrganizationService) SetOrganizationMember(userId uint, handlename, memberHandlename string, role model.OrganizationRole) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getOrganization(tx, handlename)
+		if err != nil {
+			return err
+		}
+		myRole, err := getOrganizationRole(tx, &userId, organization)
+		if err != nil {
+			return err
+		}
+		if myRole != model.OrganizationRoleOwner {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		user, err := getUserByHandlename(tx, memberHandlename)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return &ErrUserNotFound{}
+		}
+
+		member, err := tx.GetOrganizationMember(organization.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if member == nil {
+			_, err = tx.CreateOrganizationMember(organization.ID, user.ID, role)
+			return err
+		}
+
+		if model.OrganizationRole(member.Role) == model.OrganizationRoleOwner && role != model.OrganizationRoleOwner {
+			if err := ensureAnotherOrganizationOwner(tx, organization.ID); err != nil {
+				return err
+			}
+		}
+		return tx.UpdateOrganizationMemberRole(organization.ID, user.ID, role)
+	})
+}
+
+// RemoveOrganizationMember はメンバーを組織から外します。オーナーのほか、メンバー本人も脱退できます。
+func (s *OrganizationService) RemoveOrganizationMember(userId uint, handlename, memberHandlename string) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getOrganization(tx, handlename)
+		if err != nil {
+			return err
+		}
+		myRole, err := getOrganizationRole(tx, &userId, organization)
+		if err != nil {
+			return err
+		}
+		if myRole == 0 {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		user, err := getUserByHandlename(tx, memberHandlename)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return &ErrOrganizationMemberNotFound{OrganizationAccountId: organization.ID, Handlename: memberHandlename}
+		}
+		if myRole != model.OrganizationRoleOwner && user.ID != userId {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		member, err := tx.GetOrganizationMember(organization.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if member == nil {
+			return &ErrOrganizationMemberNotFound{OrganizationAccountId: organization.ID, Handlename: memberHandlename}
+		}
+		if model.OrganizationRole(member.Role) == model.OrganizationRoleOwner {
+			if err := ensureAnotherOrganizationOwner(tx, organization.ID); err != nil {
+				return err
+			}
+		}
+
+		// チームを通じたアクセス権も残さない
+		if err := tx.DeleteTeamMembersByUserId(organization.ID, user.ID); err != nil {
+			return err
+		}
+		return tx.DeleteOrganizationMember(organization.ID, user.ID)
+	})
+}
+
+// ensureAnotherOrganizationOwner はオーナーが一人もいない組織を作らないための確認です。
+func ensureAnotherOrganizationOwner(tx OrganizationStore, organizationAccountId uint) error {
+	owners, err := tx.CountOrganizationMembersByRole(organizationAccountId, model.OrganizationRoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return &ErrLastOrganizationOwner{OrganizationAccountId: organizationAccountId}
+	}
+	return nil
+}
+
+// GetUserOrganizations はユーザが所属する組織を返します。
+func (s *OrganizationService) GetUserOrganizations(userId uint, offset, limit int) ([]model.Account, error) {
+	var organizations []model.Account
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		var err error
+		organizations, err = tx.GetOrganizationsByUserId(userId, offset, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return organizations, nil
+}
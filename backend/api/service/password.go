@@ -0,0 +1,124 @@
+package service
+
+import (
+	"gityard-api/model"
+	"gityard-api/security"
+	"log/slog"
+	"time"
+)
+
+// ChangePassword は今のパスワードを確認してから新しいパスワードに変更します。
+// 変更を行ったセッション以外はすべて終了させます。アクセストークンはすべて失効するので、
+// 変更を行ったセッションには返したトークンの世代でアクセストークンを発行し直してください。
+func (s *AuthService) ChangePassword(userId, sessionId uint, currentPassword, newPassword string) (uint, error) {
+	var generation uint
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		credInDB, err := tx.GetUserCredentialById(userId)
+		if err != nil {
+			return err
+		}
+		if credInDB == nil {
+			return &ErrCredentialNotFound{UserId: userId}
+		}
+		if !security.VerifyPassword(currentPassword, credInDB.HashedPassword) {
+			return &ErrPasswordMissMatch{UserId: userId}
+		}
+
+		userInDB, err := tx.GetUserById(userId)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil || userInDB.Email == nil {
+			return &ErrUserNotFound{UserId: userId}
+		}
+
+		generation, err = updatePassword(tx, userId, newPassword, sessionId)
+		if err != nil {
+			return err
+		}
+		return enqueuePasswordChangedMail(tx, *userInDB.Email, userInDB.Locale)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return generation, nil
+}
+
+// updatePassword はパスワードを変更し、古いパスワードで始めたログインを無効にして、新しいトークンの世代を返します。
+// keepSessionId が0でなければそのセッションだけ残しますが、そのセッションのアクセストークンも発行し直しが必要です。
+func updatePassword(tx AuthServiceStore, userId uint, plainPassword string, keepSessionId uint) (uint, error) {
+	if err := tx.UpdateUserCredentialPassword(userId, plainPassword); err != nil {
+		return 0, err
+	}
+	if err := tx.DeleteUserRefreshTokensByUserId(userId, keepSessionId); err != nil {
+		return 0, err
+	}
+	if err := tx.DeleteUserTwoFactorChallengesByUserId(userId); err != nil {
+		return 0, err
+	}
+	if err := tx.DeleteUserVerificationTokensByUserId(userId, model.PasswordResetToken); err != nil {
+		return 0, err
+	}
+	return revokeAccessTokens(tx, userId)
+}
+
+// RequestPasswordReset はパスワードの再設定用のリンクをメールで送ります。
+// 登録されているメールアドレスかどうかを知られないように、見つからなくてもエラーにはしません。
+func (s *AuthService) RequestPasswordReset(email string) error {
+	return s.store.Transaction(func(tx AuthServiceStore) error {
+		userInDB, err := tx.GetUserByEmail(email)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil {
+			slog.Info("password reset requested for unknown email")
+			return nil
+		}
+
+		// 最後に送ったリンクだけを使えるようにする
+		if err := tx.DeleteUserVerificationTokensByUserId(userInDB.ID, model.PasswordResetToken); err != nil {
+			return err
+		}
+		token := security.GenerateVerificationToken()
+		expiresAt := time.Now().Add(s.cfg.Token.PasswordResetTokenTTL)
+		_, err = tx.CreateUserVerificationToken(userInDB.ID, model.PasswordResetToken, token, email, expiresAt)
+		if err != nil {
+			return err
+		}
+		return enqueuePasswordResetMail(tx, s.cfg, email, userInDB.Locale, token)
+	})
+}
+
+// ResetPassword はメールで送ったトークンを確認してパスワードを再設定し、すべてのセッションを終了させます。
+// リンクを開けたのでメールアドレスも確認できたものとして扱います。
+func (s *AuthService) ResetPassword(token, newPassword string) error {
+	var rejected error
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		userInDB, err := useVerificationToken(tx, model.PasswordResetToken, token)
+		if err != nil {
+			return err
+		}
+		if userInDB == nil {
+			// 使ったトークンの削除をコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrInvalidVerificationTokenProvided{}
+			return nil
+		}
+
+		if userInDB.EmailVerifiedAt == nil {
+			if err := tx.UpdateUserEmailVerifiedAt(userInDB.ID, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		if _, err := updatePassword(tx, userInDB.ID, newPassword, 0); err != nil {
+			return err
+		}
+		return enqueuePasswordChangedMail(tx, *userInDB.Email, userInDB.Locale)
+	})
+	if err != nil {
+		return err
+	}
+
+	return rejected
+}
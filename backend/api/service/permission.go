@@ -0,0 +1,63 @@
+package service
+
+import (
+	"gityard-api/model"
+)
+
+// RepositoryPermission はリポジトリに対する権限の強さを表します。大きいほど強い権限です。
+// 値は model.RepositoryRole と揃えています。
+type RepositoryPermission int
+
+const (
+	PermissionNone     RepositoryPermission = 0
+	PermissionRead                          = RepositoryPermission(model.RepositoryRoleRead)
+	PermissionTriage                        = RepositoryPermission(model.RepositoryRoleTriage)
+	PermissionWrite                         = RepositoryPermission(model.RepositoryRoleWrite)
+	PermissionMaintain                      = RepositoryPermission(model.RepositoryRoleMaintain)
+	PermissionAdmin                         = RepositoryPermission(model.RepositoryRoleAdmin)
+)
+
+// getRepositoryPermission はユーザがリポジトリに対して持つ権限を返します。userId が nil の場合は未ログインです。
+func getRepositoryPermission(tx permissionStore, userId *uint, owner *model.Account, repo *model.Repository) (RepositoryPermission, error) {
+	if isRepositoryOwner(userId, owner) {
+		return PermissionAdmin, nil
+	}
+
+	permission := PermissionNone
+	if !repo.IsPrivate {
+		permission = PermissionRead
+	}
+	if userId == nil {
+		return permission, nil
+	}
+
+	// 組織の基本権限、コラボレーター、チームのうち最も強い権限を使う
+	organizationPermission, err := getOrganizationPermission(tx, userId, owner)
+	if err != nil {
+		return PermissionNone, err
+	}
+	if organizationPermission == PermissionAdmin {
+		return PermissionAdmin, nil
+	}
+	permission = max(permission, organizationPermission)
+
+	collaborator, err := tx.GetRepositoryCollaborator(repo.ID, *userId)
+	if err != nil {
+		return PermissionNone, err
+	}
+	if collaborator != nil {
+		permission = max(permission, RepositoryPermission(collaborator.Role))
+	}
+
+	teamPermission, err := getTeamPermission(tx, *userId, owner, repo)
+	if err != nil {
+		return PermissionNone, err
+	}
+	permission = max(permission, teamPermission)
+
+	return permission, nil
+}
+
+func (p RepositoryPermission) String() string {
+	return model.RepositoryRole(p).String()
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"gityard-api/avatar"
+	"gityard-api/model"
+	"gityard-api/storage"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// getPersonalAccountWithProfile はユーザの個人アカウントをプロフィールとともに取得します。
+func getPersonalAccountWithProfile(tx AccountStore, userId uint) (*model.Account, error) {
+	account, err := tx.GetPersonalAccountByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.IsDeleted {
+		return nil, &ErrAccountNotFound{}
+	}
+
+	profile, err := tx.GetAccountProfileById(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		account.AccountProfile = *profile
+	}
+	return account, nil
+}
+
+// GetProfile はハンドルネームから個人アカウントをプロフィールとともに取得します。改名前のハンドルネームでも取得できます。
+// 非公開のプロフィールは本人以外には存在しないものとして扱います。
+func (s *AccountService) GetProfile(viewerUserId *uint, handlename string) (*model.Account, error) {
+	var account *model.Account
+	err := s.store.Transaction(func(tx AccountServiceStore) error {
+		accountInDB, err := getAccountByHandlename(tx, handlename)
+		if err != nil {
+			return err
+		}
+		if accountInDB == nil || accountInDB.Kind != int(model.PersonalAccount) || accountInDB.UserID == nil {
+			return &ErrAccountNotFound{Handlename: handlename}
+		}
+
+		profile, err := tx.GetAccountProfileById(accountInDB.ID)
+		if err != nil {
+			return err
+		}
+		if profile != nil {
+			accountInDB.AccountProfile = *profile
+		}
+		isOwner := viewerUserId != nil && *viewerUserId == *accountInDB.UserID
+		if accountInDB.AccountProfile.IsPrivate && !isOwner {
+			return &ErrAccountNotFound{Handlename: handlename}
+		}
+		account = accountInDB
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// UpdateProfile はユーザの個人アカウントの表示名と公開範囲を変更します。nil の項目は変更しません。
+func (s *AccountService) UpdateProfile(userId uint, displayname *string, private *bool) (*model.Account, error) {
+	var account *model.Account
+	err := s.store.Transaction(func(tx AccountServiceStore) error {
+		var err error
+		account, err = getPersonalAccountWithProfile(tx, userId)
+		if err != nil {
+			return err
+		}
+
+		if displayname != nil {
+			account.AccountProfile.Displayname = *displayname
+			if account.AccountProfile.Displayname == "" {
+				account.AccountProfile.Displayname = account.Handlename.Handlename
+			}
+		}
+		if private != nil {
+			account.AccountProfile.IsPrivate = *private
+		}
+
+		return tx.UpdateAccountProfile(account.ID, account.AccountProfile.Displayname, account.AccountProfile.IsPrivate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// UploadAvatar はアップロードされた画像を正方形のPNGに変換して保存し、ユーザのアバター画像にします。
+// 画像は変更のたびに新しいキーで保存するので、配信時に長くキャッシュさせられます。
+func (s *AccountService) UploadAvatar(userId uint, r io.Reader) (*model.Account, error) {
+	data, err := avatar.Process(r)
+	if err != nil {
+		if errors.Is(err, avatar.ErrTooLarge) || errors.Is(err, avatar.ErrUnsupported) {
+			return nil, &ErrInvalidAvatar{Detail: err}
+		}
+		return nil, err
+	}
+
+	account, err := s.store.GetPersonalAccountByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.IsDeleted {
+		return nil, &ErrAccountNotFound{}
+	}
+	key := fmt.Sprintf("avatars/%d/%s.png", account.ID, strings.ToLower(rand.Text()))
+	if err := s.blobs.Put(key, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	account, err = s.setAvatar(userId, key)
+	if err != nil {
+		deleteAvatarBlob(s.blobs, key)
+		return nil, err
+	}
+	return account, nil
+}
+
+// DeleteAvatar はユーザのアバター画像を削除して、アイデンティコンに戻します。
+func (s *AccountService) DeleteAvatar(userId uint) (*model.Account, error) {
+	account, err := s.store.GetPersonalAccountByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.IsDeleted {
+		return nil, &ErrAccountNotFound{}
+	}
+	iconpath, err := createIdenticon(s.blobs, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setAvatar(userId, iconpath)
+}
+
+// createIdenticon はアカウントIDから決まるアイデンティコンを保存し、その Iconpath を返します。
+// 画像もキーもアカウントIDだけで決まるので、作り直しても同じものになり、トランザクションがロールバックして残っても害はありません。
+func createIdenticon(blobs storage.BlobStore, accountId uint) (string, error) {
+	data, err := avatar.Identicon(strconv.FormatUint(uint64(accountId), 10))
+	if err != nil {
+		return "", err
+	}
+
+	key := identiconKey(accountId)
+	if err := blobs.Put(key, bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func identiconKey(accountId uint) string {
+	return fmt.Sprintf("avatars/%d/identicon.png", accountId)
+}
+
+// AvatarKey はアカウントのアバター画像のキーを返します。
+// アイデンティコンを作るようになる前のアカウントは Iconpath が既定値のままなので、アイデンティコンのキーを返します。
+func AvatarKey(account *model.Account) string {
+	iconpath := account.AccountProfile.Iconpath
+	if iconpath == "" || iconpath == model.DefaultIconpath {
+		return identiconKey(account.ID)
+	}
+	return iconpath
+}
+
+// OpenAvatar はアバター画像を読み出します。
+// アイデンティコンがまだ保存されていなければ、その場で作って返します。
+func (s *AccountService) OpenAvatar(key string) (io.ReadCloser, error) {
+	r, err := s.blobs.Open(key)
+	if !errors.Is(err, storage.ErrBlobNotFound) {
+		return r, err
+	}
+
+	var accountId uint
+	if _, scanErr := fmt.Sscanf(key, "avatars/%d/identicon.png", &accountId); scanErr != nil || identiconKey(accountId) != key {
+		return nil, err
+	}
+	data, err := avatar.Identicon(strconv.FormatUint(uint64(accountId), 10))
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// setAvatar はユーザの個人アカウントの Iconpath を iconpath に置き換え、以前の画像を削除します。
+func (s *AccountService) setAvatar(userId uint, iconpath string) (*model.Account, error) {
+	var account *model.Account
+	var oldIconpath string
+	err := s.store.Transaction(func(tx AccountServiceStore) error {
+		var err error
+		account, err = getPersonalAccountWithProfile(tx, userId)
+		if err != nil {
+			return err
+		}
+
+		oldIconpath = account.AccountProfile.Iconpath
+		if err := tx.UpdateAccountProfileIconpath(account.ID, iconpath); err != nil {
+			return err
+		}
+		account.AccountProfile.Iconpath = iconpath
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if oldIconpath != iconpath {
+		deleteAvatarBlob(s.blobs, oldIconpath)
+	}
+	return account, nil
+}
+
+// deleteAvatarBlob はアバター画像をブロブストアから削除します。
+// 参照する行はもうないので、削除できなくてもログに残すだけにします。
+func deleteAvatarBlob(blobs storage.BlobStore, iconpath string) {
+	if iconpath == "" || iconpath == model.DefaultIconpath {
+		return
+	}
+	if err := blobs.Delete(iconpath); err != nil {
+		slog.Error("failed to delete avatar", "iconpath", iconpath, "detail", err)
+	}
+}
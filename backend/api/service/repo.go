@@ -0,0 +1,291 @@
+package service
+
+import (
+	"errors"
+	"gityard-api/config"
+	"gityard-api/model"
+	"gityard-api/storage"
+	"log/slog"
+)
+
+// RepoService はリポジトリとコラボレーター、gitの操作の認可を扱います。
+type RepoService struct {
+	store        RepoServiceStore
+	repositories *storage.RepositoryStorage
+	cfg          *config.Config
+}
+
+// NewRepoService は RepoService を作成します。リポジトリの中身は repositories に置きます。
+func NewRepoService(store RepoServiceStore, repositories *storage.RepositoryStorage, cfg *config.Config) *RepoService {
+	return &RepoService{store: store, repositories: repositories, cfg: cfg}
+}
+
+// isRepositoryOwner はユーザがリポジトリを所有する個人アカウントの持ち主であるかを返します。
+// 組織アカウントには持ち主がいないので常に false です。
+func isRepositoryOwner(userId *uint, owner *model.Account) bool {
+	return userId != nil && owner.UserID != nil && *owner.UserID == *userId
+}
+
+// getOwnerAndRepository は owner/name からアカウントとリポジトリ、ユーザの権限を取得します。
+// 非公開リポジトリは閲覧権限のないユーザには存在しないものとして扱います。
+// owner が改名前のハンドルネームなら改名後のアカウントのリポジトリを返すので、古いリモートのままでも使えます。
+func getOwnerAndRepository(tx permissionStore, userId *uint, owner, name string) (*model.Account, *model.Repository, RepositoryPermission, error) {
+	account, err := getAccountByHandlename(tx, owner)
+	if err != nil {
+		return nil, nil, PermissionNone, err
+	}
+	if account == nil {
+		return nil, nil, PermissionNone, &ErrRepositoryNotFound{Owner: owner, Name: name}
+	}
+
+	repo, err := tx.GetRepositoryByOwnerAndName(account.ID, name)
+	if err != nil {
+		return nil, nil, PermissionNone, err
+	}
+	if repo == nil {
+		return nil, nil, PermissionNone, &ErrRepositoryNotFound{Owner: owner, Name: name}
+	}
+
+	permission, err := getRepositoryPermission(tx, userId, account, repo)
+	if err != nil {
+		return nil, nil, PermissionNone, err
+	}
+	if permission < PermissionRead {
+		return nil, nil, PermissionNone, &ErrRepositoryNotFound{Owner: owner, Name: name}
+	}
+
+	repo.OwnerAccount = *account
+	return account, repo, permission, nil
+}
+
+// getRepositoryCreationAccount はリポジトリを作成するアカウントを返します。
+// owner が空なら自分の個人アカウント、組織のハンドルネームならメンバーである場合に限りその組織です。
+func getRepositoryCreationAccount(tx RepoServiceStore, userId uint, owner string) (*model.Account, error) {
+	if owner == "" {
+		account, err := tx.GetPersonalAccountByUserId(userId)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return nil, &ErrAccountNotFound{}
+		}
+		return account, nil
+	}
+
+	account, err := tx.GetAccountByHandlename(owner)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, &ErrAccountNotFound{Handlename: owner}
+	}
+	if isRepositoryOwner(&userId, account) {
+		return account, nil
+	}
+
+	role, err := getOrganizationRole(tx, &userId, account)
+	if err != nil {
+		return nil, err
+	}
+	if role == 0 {
+		return nil, &ErrPermissionDenied{UserId: userId}
+	}
+	return account, nil
+}
+
+// CreateRepository はリポジトリを作成します。owner が空の場合は自分の個人アカウントに作成します。
+func (s *RepoService) CreateRepository(userId uint, owner, name string, private bool) (*model.Repository, error) {
+	var repo *model.Repository
+	createdOnDisk := false
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		account, err := getRepositoryCreationAccount(tx, userId, owner)
+		if err != nil {
+			return err
+		}
+
+		repoInDB, err := tx.GetRepositoryByOwnerAndName(account.ID, name)
+		if err != nil {
+			return err
+		}
+		if repoInDB != nil { // 同じアカウントに同名のリポジトリがある
+			return &ErrRegisteredRepositoryName{OwnerAccountId: account.ID, Name: name}
+		}
+
+		registeredRepo, err := tx.CreateRepository(account.ID, name, private)
+		if err != nil {
+			// 上のチェックをすり抜けた同時リクエストは uq_idx_repositories_owner_account_id_and_name で弾かれる
+			if errors.Is(err, ErrDuplicatedKey) {
+				return &ErrRegisteredRepositoryName{OwnerAccountId: account.ID, Name: name}
+			}
+			return err
+		}
+		registeredRepo.OwnerAccount = *account
+		repo = registeredRepo
+
+		// ディスクへの作成に失敗したら行の作成もロールバックする
+		if err := s.repositories.Create(registeredRepo.ID); err != nil {
+			return err
+		}
+		createdOnDisk = true
+
+		return nil
+	})
+	if err != nil {
+		// コミットに失敗した場合はディスクのリポジトリを片付ける
+		if createdOnDisk {
+			if err := s.repositories.Remove(repo.ID); err != nil {
+				slog.Error("failed to remove repository from disk", "repositoryId", repo.ID, "detail", err)
+			}
+		}
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (s *RepoService) GetRepository(userId *uint, owner, name string) (*model.Repository, error) {
+	var repo *model.Repository
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repoInDB, _, err := getOwnerAndRepository(tx, userId, owner, name)
+		if err != nil {
+			return err
+		}
+		repo = repoInDB
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (s *RepoService) GetRepositories(userId *uint, owner string, offset, limit int) ([]model.Repository, error) {
+	var repos []model.Repository
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		account, err := tx.GetAccountByHandlename(owner)
+		if err != nil {
+			return err
+		}
+		if account == nil {
+			return &ErrAccountNotFound{Handlename: owner}
+		}
+
+		organizationPermission, err := getOrganizationPermission(tx, userId, account)
+		if err != nil {
+			return err
+		}
+		// 組織の基本権限で読めるなら組織の全リポジトリを返す
+		includePrivate := isRepositoryOwner(userId, account) || organizationPermission >= PermissionRead
+
+		var teamIds []uint
+		if userId != nil && !includePrivate {
+			teamIds, err = getUserTeamIds(tx, account, *userId)
+			if err != nil {
+				return err
+			}
+		}
+
+		reposInDB, err := tx.GetRepositoriesByOwnerAccountId(account.ID,
+			includePrivate,
+			userId,
+			teamIds,
+			offset,
+			limit,
+		)
+		if err != nil {
+			return err
+		}
+		for i := range reposInDB {
+			reposInDB[i].OwnerAccount = *account
+		}
+		repos = reposInDB
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return repos, nil
+}
+
+// UpdateRepository はリポジトリの名前と公開設定を更新します。nil のフィールドは変更しません。
+func (s *RepoService) UpdateRepository(userId uint, owner, name string, newName *string, private *bool) (*model.Repository, error) {
+	var repo *model.Repository
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		account, repoInDB, permission, err := getOwnerAndRepository(tx, &userId, owner, name)
+		if err != nil {
+			return err
+		}
+		if permission < PermissionAdmin {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		if newName != nil && *newName != repoInDB.Name {
+			sameNameRepo, err := tx.GetRepositoryByOwnerAndName(account.ID, *newName)
+			if err != nil {
+				return err
+			}
+			if sameNameRepo != nil {
+				return &ErrRegisteredRepositoryName{OwnerAccountId: account.ID, Name: *newName}
+			}
+			repoInDB.Name = *newName
+		}
+		if private != nil {
+			repoInDB.IsPrivate = *private
+		}
+
+		err = tx.UpdateRepository(repoInDB.ID, repoInDB.Name, repoInDB.IsPrivate)
+		if err != nil {
+			if errors.Is(err, ErrDuplicatedKey) {
+				return &ErrRegisteredRepositoryName{OwnerAccountId: account.ID, Name: repoInDB.Name}
+			}
+			return err
+		}
+		repo = repoInDB
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (s *RepoService) DeleteRepository(userId uint, owner, name string) error {
+	var trashed *storage.TrashedRepository
+	err := s.store.Transaction(func(tx RepoServiceStore) error {
+		_, repo, permission, err := getOwnerAndRepository(tx, &userId, owner, name)
+		if err != nil {
+			return err
+		}
+		if permission < PermissionAdmin {
+			return &ErrPermissionDenied{UserId: userId}
+		}
+
+		if err := tx.DeleteRepository(repo.ID); err != nil {
+			return err
+		}
+
+		// 行の削除が確定するまではゴミ箱に退避しておく
+		trashed, err = s.repositories.Trash(repo.ID)
+		return err
+	})
+	if err != nil {
+		if trashed != nil {
+			if err := trashed.Restore(); err != nil {
+				slog.Error("failed to restore repository from trash", "detail", err)
+			}
+		}
+		return err
+	}
+
+	if err := trashed.Purge(); err != nil {
+		// 行は消えているので、ゴミ箱に残っても利用者からは見えない
+		slog.Error("failed to purge trashed repository", "detail", err)
+	}
+	return nil
+}
@@ -4,22 +4,23 @@ import (
 	"errors"
 	"gityard-api/model"
 	"gorm.io/gorm"
+	"time"
 )
 
-func CreateHandleName(db *gorm.DB, name string) (*model.Handlename, error) {
+func (s *Store) CreateHandleName(name string) (*model.Handlename, error) {
 	handlename := new(model.Handlename)
 	handlename.Handlename = name
 
-	if err := db.Create(&handlename).Error; err != nil {
+	if err := s.db.Create(&handlename).Error; err != nil {
 		return nil, err
 	}
 
 	return handlename, nil
 }
 
-func GetHandleNameById(db *gorm.DB, handlenameId uint) (*model.Handlename, error) {
+func (s *Store) GetHandleNameById(handlenameId uint) (*model.Handlename, error) {
 	var handlename model.Handlename
-	if err := db.Model(&handlename).Where(&model.Handlename{ID: handlenameId}).First(&handlename).Error; err != nil {
+	if err := s.db.Model(&handlename).Where(&model.Handlename{ID: handlenameId}).First(&handlename).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -29,9 +30,9 @@ func GetHandleNameById(db *gorm.DB, handlenameId uint) (*model.Handlename, error
 	return &handlename, nil
 }
 
-func GetHandleNameByName(db *gorm.DB, name string) (*model.Handlename, error) {
+func (s *Store) GetHandleNameByName(name string) (*model.Handlename, error) {
 	var handlename model.Handlename
-	if err := db.Model(handlename).Where(&model.Handlename{Handlename: name}).First(&handlename).Error; err != nil {
+	if err := s.db.Model(handlename).Where(&model.Handlename{Handlename: name}).First(&handlename).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -41,22 +42,36 @@ func GetHandleNameByName(db *gorm.DB, name string) (*model.Handlename, error) {
 	return &handlename, nil
 }
 
-func CreateAccount(db *gorm.DB, userId uint, handlenameId uint, kind model.AccountKind) (*model.Account, error) {
+// UpdateHandleNameRedirect はハンドルネームを転送に使うか設定します。redirectAccountId が nil なら転送をやめて使用中に戻します。
+func (s *Store) UpdateHandleNameRedirect(handlenameId uint, redirectAccountId *uint, expiresAt *time.Time) error {
+	return s.db.Model(&model.Handlename{ID: handlenameId}).Updates(map[string]any{
+		"redirect_account_id": redirectAccountId,
+		"redirect_expires_at": expiresAt,
+	}).Error
+}
+
+// DeleteHandleNameRedirectsByAccountId はアカウントへの転送に使っている改名前のハンドルネームをすべて削除します。
+func (s *Store) DeleteHandleNameRedirectsByAccountId(accountId uint) error {
+	return s.db.Where(&model.Handlename{RedirectAccountID: &accountId}).Delete(&model.Handlename{}).Error
+}
+
+// CreateAccount はアカウントを作成します。組織アカウントの場合 userId は nil です。
+func (s *Store) CreateAccount(userId *uint, handlenameId uint, kind model.AccountKind) (*model.Account, error) {
 	account := new(model.Account)
 	account.UserID = userId
 	account.HandlenameID = &handlenameId
 	account.Kind = int(kind)
 
-	if err := db.Create(&account).Error; err != nil {
+	if err := s.db.Create(&account).Error; err != nil {
 		return nil, err
 	}
 
 	return account, nil
 }
 
-func GetAccountById(db *gorm.DB, accountId uint) (*model.Account, error) {
+func (s *Store) GetAccountById(accountId uint) (*model.Account, error) {
 	var account model.Account
-	if err := db.Model(&account).Where(&model.Account{ID: accountId}).First(&account).Error; err != nil {
+	if err := s.db.Model(&account).Where(&model.Account{ID: accountId}).First(&account).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -66,23 +81,23 @@ func GetAccountById(db *gorm.DB, accountId uint) (*model.Account, error) {
 	return &account, nil
 }
 
-func CreateAccountProfile(db *gorm.DB, accountId uint, displayName string, private bool) (*model.AccountProfile, error) {
+func (s *Store) CreateAccountProfile(accountId uint, displayName, iconpath string, private bool) (*model.AccountProfile, error) {
 	profile := new(model.AccountProfile)
 	profile.AccountID = accountId
 	profile.Displayname = displayName
-	profile.Iconpath = "noimage001"
+	profile.Iconpath = iconpath
 	profile.IsPrivate = private
 
-	if err := db.Create(&profile).Error; err != nil {
+	if err := s.db.Create(&profile).Error; err != nil {
 		return nil, err
 	}
 
 	return profile, nil
 }
 
-func GetAccountProfileById(db *gorm.DB, accountId uint) (*model.AccountProfile, error) {
+func (s *Store) GetAccountProfileById(accountId uint) (*model.AccountProfile, error) {
 	var profile model.AccountProfile
-	if err := db.Model(&profile).Where(&model.AccountProfile{AccountID: accountId}).First(&profile).Error; err != nil {
+	if err := s.db.Model(&profile).Where(&model.AccountProfile{AccountID: accountId}).First(&profile).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -91,3 +106,105 @@ func GetAccountProfileById(db *gorm.DB, accountId uint) (*model.AccountProfile,
 
 	return &profile, nil
 }
+
+func (s *Store) UpdateAccountProfile(accountId uint, displayname string, private bool) error {
+	return s.db.Model(&model.AccountProfile{AccountID: accountId}).
+		Select("displayname", "is_private").
+		Updates(&model.AccountProfile{Displayname: displayname, IsPrivate: private}).Error
+}
+
+func (s *Store) UpdateAccountProfileIconpath(accountId uint, iconpath string) error {
+	return s.db.Model(&model.AccountProfile{AccountID: accountId}).
+		Update("iconpath", iconpath).Error
+}
+
+func (s *Store) GetAccountByHandlename(name string) (*model.Account, error) {
+	var account model.Account
+	if err := s.db.Model(&account).
+		Joins("Handlename").
+		Where("Handlename.handlename = ?", name).
+		Where("accounts.is_deleted = ?", false).
+		First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// GetAccountByRedirectedHandlename は改名前のハンドルネームから、転送先のアカウントを今のハンドルネームとともに返します。
+// 転送の期限が過ぎていれば nil を返します。
+func (s *Store) GetAccountByRedirectedHandlename(name string, now time.Time) (*model.Account, error) {
+	var account model.Account
+	if err := s.db.Model(&account).
+		Joins("Handlename").
+		Joins("JOIN handlenames AS redirects ON redirects.redirect_account_id = accounts.id").
+		Where("redirects.handlename = ?", name).
+		Where("redirects.redirect_expires_at > ?", now).
+		Where("accounts.is_deleted = ?", false).
+		First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// UpdateAccountHandlenameId はアカウントに別のハンドルネームを割り当てます。
+func (s *Store) UpdateAccountHandlenameId(accountId, handlenameId uint) error {
+	return s.db.Model(&model.Account{ID: accountId}).Update("handlename_id", handlenameId).Error
+}
+
+func (s *Store) GetPersonalAccountByUserId(userId uint) (*model.Account, error) {
+	var account model.Account
+	if err := s.db.Model(&account).
+		Joins("Handlename").
+		Where(&model.Account{UserID: &userId, Kind: int(model.PersonalAccount)}).
+		First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// GetPersonalAccountsByUserIds はユーザIDをキーにした個人アカウントのマップを返します。
+func (s *Store) GetPersonalAccountsByUserIds(userIds []uint) (map[uint]model.Account, error) {
+	accounts := map[uint]model.Account{}
+	if len(userIds) == 0 {
+		return accounts, nil
+	}
+
+	var rows []model.Account
+	if err := s.db.Model(&model.Account{}).
+		Joins("Handlename").
+		Where("accounts.user_id IN ?", userIds).
+		Where(&model.Account{Kind: int(model.PersonalAccount)}).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, account := range rows {
+		accounts[*account.UserID] = account
+	}
+
+	return accounts, nil
+}
+
+// DeleteHandleName はハンドルネームを削除して、他のアカウントが使えるようにします。
+func (s *Store) DeleteHandleName(handlenameId uint) error {
+	return s.db.Delete(&model.Handlename{}, handlenameId).Error
+}
+
+// SoftDeleteAccount はアカウントを削除済みにし、ハンドルネームとの結びつきを外します。
+func (s *Store) SoftDeleteAccount(accountId uint) error {
+	return s.db.Model(&model.Account{ID: accountId}).Updates(map[string]any{
+		"handlename_id": nil,
+		"is_deleted":    true,
+	}).Error
+}
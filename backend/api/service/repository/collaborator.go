@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"errors"
+	"gityard-api/model"
+	"gorm.io/gorm"
+)
+
+func (s *Store) CreateRepositoryCollaborator(repoId, userId uint, role model.RepositoryRole) (*model.RepositoryCollaborator, error) {
+	collaborator := new(model.RepositoryCollaborator)
+	collaborator.RepositoryID = repoId
+	collaborator.UserID = userId
+	collaborator.Role = int(role)
+
+	if err := s.db.Create(&collaborator).Error; err != nil {
+		return nil, err
+	}
+
+	return collaborator, nil
+}
+
+func (s *Store) GetRepositoryCollaborator(repoId, userId uint) (*model.RepositoryCollaborator, error) {
+	var collaborator model.RepositoryCollaborator
+	if err := s.db.Model(&collaborator).
+		Where(&model.RepositoryCollaborator{RepositoryID: repoId, UserID: userId}).
+		First(&collaborator).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &collaborator, nil
+}
+
+func (s *Store) GetRepositoryCollaborators(repoId uint, offset, limit int) ([]model.RepositoryCollaborator, error) {
+	var collaborators []model.RepositoryCollaborator
+	if err := s.db.Model(&model.RepositoryCollaborator{}).
+		Where(&model.RepositoryCollaborator{RepositoryID: repoId}).
+		Order("created_at").
+		Offset(offset).Limit(limit).
+		Find(&collaborators).Error; err != nil {
+		return nil, err
+	}
+
+	return collaborators, nil
+}
+
+func (s *Store) UpdateRepositoryCollaboratorRole(repoId, userId uint, role model.RepositoryRole) error {
+	return s.db.Model(&model.RepositoryCollaborator{}).
+		Where(&model.RepositoryCollaborator{RepositoryID: repoId, UserID: userId}).
+		Update("role", int(role)).Error
+}
+
+func (s *Store) DeleteRepositoryCollaborator(repoId, userId uint) error {
+	return s.db.Where(&model.RepositoryCollaborator{RepositoryID: repoId, UserID: userId}).
+		Delete(&model.RepositoryCollaborator{}).Error
+}
+
+// DeleteRepositoryCollaboratorsByUserId はユーザをすべてのリポジトリのコラボレーターから外します。
+func (s *Store) DeleteRepositoryCollaboratorsByUserId(userId uint) error {
+	return s.db.Where(&model.RepositoryCollaborator{UserID: userId}).Delete(&model.RepositoryCollaborator{}).Error
+}
+
+func (s *Store) CreateRepositoryInvitation(repoId, inviteeUserId, inviterUserId uint, role model.RepositoryRole) (*model.RepositoryInvitation, error) {
+	invitation := new(model.RepositoryInvitation)
+	invitation.RepositoryID = repoId
+	invitation.InviteeUserID = inviteeUserId
+	invitation.InviterUserID = inviterUserId
+	invitation.Role = int(role)
+	invitation.Status = int(model.InvitationPending)
+
+	if err := s.db.Create(&invitation).Error; err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+func (s *Store) GetRepositoryInvitationById(invitationId uint) (*model.RepositoryInvitation, error) {
+	var invitation model.RepositoryInvitation
+	if err := s.db.Model(&invitation).Where(&model.RepositoryInvitation{ID: invitationId}).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+func (s *Store) GetPendingRepositoryInvitation(repoId, inviteeUserId uint) (*model.RepositoryInvitation, error) {
+	var invitation model.RepositoryInvitation
+	if err := s.db.Model(&invitation).
+		Where(&model.RepositoryInvitation{
+			RepositoryID:  repoId,
+			InviteeUserID: inviteeUserId,
+			Status:        int(model.InvitationPending),
+		}).
+		First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// GetPendingRepositoryInvitationsByRepositoryId はリポジトリの保留中の招待を返します。
+func (s *Store) GetPendingRepositoryInvitationsByRepositoryId(repoId uint, offset, limit int) ([]model.RepositoryInvitation, error) {
+	var invitations []model.RepositoryInvitation
+	if err := s.db.Model(&model.RepositoryInvitation{}).
+		Where(&model.RepositoryInvitation{RepositoryID: repoId, Status: int(model.InvitationPending)}).
+		Order("created_at").
+		Offset(offset).Limit(limit).
+		Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// GetPendingRepositoryInvitationsByInviteeUserId はユーザ宛ての保留中の招待をリポジトリと一緒に返します。
+func (s *Store) GetPendingRepositoryInvitationsByInviteeUserId(inviteeUserId uint, offset, limit int) ([]model.RepositoryInvitation, error) {
+	var invitations []model.RepositoryInvitation
+	if err := s.db.Model(&model.RepositoryInvitation{}).
+		Preload("Repository.OwnerAccount.Handlename").
+		Where(&model.RepositoryInvitation{InviteeUserID: inviteeUserId, Status: int(model.InvitationPending)}).
+		Order("created_at").
+		Offset(offset).Limit(limit).
+		Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+func (s *Store) UpdateRepositoryInvitationStatus(invitationId uint, status model.InvitationStatus) error {
+	return s.db.Model(&model.RepositoryInvitation{ID: invitationId}).Update("status", int(status)).Error
+}
+
+func (s *Store) DeleteRepositoryInvitation(invitationId uint) error {
+	return s.db.Delete(&model.RepositoryInvitation{}, invitationId).Error
+}
+
+// DeleteRepositoryInvitationsByUserId はユーザが招待した、または招待されたすべての招待を削除します。
+func (s *Store) DeleteRepositoryInvitationsByUserId(userId uint) error {
+	return s.db.Where("invitee_user_id = ? OR inviter_user_id = ?", userId, userId).
+		Delete(&model.RepositoryInvitation{}).Error
+}
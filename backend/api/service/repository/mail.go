@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"gityard-api/model"
+	"time"
+)
+
+func (s *Store) CreateMailOutbox(to, subject, textBody, htmlBody string, nextAttemptAt time.Time) (*model.MailOutbox, error) {
+	outbox := new(model.MailOutbox)
+	outbox.ToAddress = to
+	outbox.Subject = subject
+	outbox.TextBody = textBody
+	outbox.HTMLBody = htmlBody
+	outbox.NextAttemptAt = nextAttemptAt
+
+	if err := s.db.Create(outbox).Error; err != nil {
+		return nil, err
+	}
+
+	return outbox, nil
+}
+
+// GetDueMailOutboxes は送信する時刻を過ぎた、諦めていないメールを古い順に返します。
+func (s *Store) GetDueMailOutboxes(now time.Time, limit int) ([]model.MailOutbox, error) {
+	var outboxes []model.MailOutbox
+	if err := s.db.Model(&model.MailOutbox{}).
+		Where("failed_at IS NULL AND next_attempt_at <= ?", now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&outboxes).Error; err != nil {
+		return nil, err
+	}
+
+	return outboxes, nil
+}
+
+// ClaimMailOutbox は送信を試みる前に回数を増やし、次に試みる時刻を先に延ばします。
+// 他のワーカーが先に取り出していたら false を返します。送信中に落ちても、次の時刻になれば再送されます。
+func (s *Store) ClaimMailOutbox(outboxId uint, attempts int, nextAttemptAt time.Time) (bool, error) {
+	result := s.db.Model(&model.MailOutbox{}).
+		Where("id = ? AND attempts = ?", outboxId, attempts).
+		Updates(map[string]any{
+			"attempts":        attempts + 1,
+			"next_attempt_at": nextAttemptAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateMailOutboxError は送信に失敗した理由を記録します。failedAt を渡すとそれ以上は再送しません。
+func (s *Store) UpdateMailOutboxError(outboxId uint, lastError string, failedAt *time.Time) error {
+	return s.db.Model(&model.MailOutbox{ID: outboxId}).
+		Select("last_error", "failed_at").
+		Updates(&model.MailOutbox{
+			LastError: lastError,
+			FailedAt:  failedAt,
+		}).Error
+}
+
+func (s *Store) DeleteMailOutbox(outboxId uint) error {
+	return s.db.Delete(&model.MailOutbox{}, outboxId).Error
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"errors"
+	"gityard-api/model"
+	"gorm.io/gorm"
+)
+
+func (s *Store) CreateOrganizationMember(organizationAccountId, userId uint, role model.OrganizationRole) (*model.OrganizationMember, error) {
+	member := new(model.OrganizationMember)
+	member.OrganizationAccountID = organizationAccountId
+	member.UserID = userId
+	member.Role = int(role)
+
+	if err := s.db.Create(&member).Error; err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (s *Store) GetOrganizationMember(organizationAccountId, userId uint) (*model.OrganizationMember, error) {
+	var member model.OrganizationMember
+	if err := s.db.Model(&member).
+		Where(&model.OrganizationMember{OrganizationAccountID: organizationAccountId, UserID: userId}).
+		First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (s *Store) GetOrganizationMembers(organizationAccountId uint, offset, limit int) ([]model.OrganizationMember, error) {
+	var members []model.OrganizationMember
+	if err := s.db.Model(&model.OrganizationMember{}).
+		Where(&model.OrganizationMember{OrganizationAccountID: organizationAccountId}).
+		Order("created_at").
+		Offset(offset).Limit(limit).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// GetOrganizationMembersByUserId はユーザのすべての組織での所属を返します。
+func (s *Store) GetOrganizationMembersByUserId(userId uint) ([]model.OrganizationMember, error) {
+	var members []model.OrganizationMember
+	if err := s.db.Model(&model.OrganizationMember{}).
+		Where(&model.OrganizationMember{UserID: userId}).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (s *Store) CountOrganizationMembersByRole(organizationAccountId uint, role model.OrganizationRole) (int64, error) {
+	var count int64
+	if err := s.db.Model(&model.OrganizationMember{}).
+		Where(&model.OrganizationMember{OrganizationAccountID: organizationAccountId, Role: int(role)}).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetOrganizationsByUserId はユーザが所属する組織アカウントを返します。
+func (s *Store) GetOrganizationsByUserId(userId uint, offset, limit int) ([]model.Account, error) {
+	var accounts []model.Account
+	if err := s.db.Model(&model.Account{}).
+		Joins("Handlename").
+		Joins("JOIN organization_members ON organization_members.organization_account_id = accounts.id").
+		Where("organization_members.user_id = ?", userId).
+		Where("accounts.is_deleted = ?", false).
+		Order("Handlename.handlename").
+		Offset(offset).Limit(limit).
+		Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+func (s *Store) UpdateOrganizationMemberRole(organizationAccountId, userId uint, role model.OrganizationRole) error {
+	return s.db.Model(&model.OrganizationMember{}).
+		Where(&model.OrganizationMember{OrganizationAccountID: organizationAccountId, UserID: userId}).
+		Update("role", int(role)).Error
+}
+
+func (s *Store) DeleteOrganizationMember(organizationAccountId, userId uint) error {
+	return s.db.Where(&model.OrganizationMember{OrganizationAccountID: organizationAccountId, UserID: userId}).
+		Delete(&model.OrganizationMember{}).Error
+}
+
+func (s *Store) CreateOrganization(accountId uint, baseRole model.RepositoryRole) (*model.Organization, error) {
+	organization := new(model.Organization)
+	organization.AccountID = accountId
+	organization.BaseRole = int(baseRole)
+
+	if err := s.db.Create(&organization).Error; err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (s *Store) GetOrganizationByAccountId(accountId uint) (*model.Organization, error) {
+	var organization model.Organization
+	if err := s.db.Model(&organization).Where(&model.Organization{AccountID: accountId}).First(&organization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+// UpdateOrganizationBaseRole はメンバー全員が持つ役割を更新します。0 は権限なしです。
+func (s *Store) UpdateOrganizationBaseRole(accountId uint, baseRole model.RepositoryRole) error {
+	return s.db.Model(&model.Organization{}).
+		Where(&model.Organization{AccountID: accountId}).
+		Update("base_role", int(baseRole)).Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"gityard-api/model"
+	"gorm.io/gorm"
+)
+
+func (s *Store) CreatePublicKey(userId uint, name, fullKeyText, algorithm, keyBody, comment, fingerprint string) (*model.UserPublicKey, error) {
+	pubkey := new(model.UserPublicKey)
+	pubkey.UserID = userId
+	pubkey.Name = name
+	pubkey.FullKeyText = fullKeyText
+	pubkey.Algorithm = algorithm
+	pubkey.Keybody = keyBody
+	pubkey.Comment = comment
+	pubkey.Fingerprint = fingerprint
+
+	if err := s.db.Create(&pubkey).Error; err != nil {
+		return nil, err
+	}
+
+	return pubkey, nil
+}
+
+func (s *Store) GetPubKeyByFingerprint(fingerprint string) (*model.UserPublicKey, error) {
+	var pubkey model.UserPublicKey
+	if err := s.db.Model(&pubkey).Where(&model.UserPublicKey{Fingerprint: fingerprint}).First(&pubkey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &pubkey, nil
+}
+
+func (s *Store) GetPubkeysByUserId(userId uint, offset, limit int) ([]model.UserPublicKey, error) {
+	var pubkeys []model.UserPublicKey
+	if err := s.db.Model(&model.UserPublicKey{}).
+		Where(&model.UserPublicKey{UserID: userId}).
+		Find(&pubkeys).
+		Offset(offset).Limit(limit).Error; err != nil {
+		return nil, err
+	}
+
+	return pubkeys, nil
+}
+
+func (s *Store) DeletePublicKeyByFingerprint(userId uint, fingerprint string) error {
+	return s.db.Where(&model.UserPublicKey{UserID: userId, Fingerprint: fingerprint}).Delete(&model.UserPublicKey{}).Error
+}
+
+func (s *Store) DeletePublicKeysByUserId(userId uint) error {
+	return s.db.Where(&model.UserPublicKey{UserID: userId}).Delete(&model.UserPublicKey{}).Error
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"errors"
+	"gityard-api/model"
+	"gorm.io/gorm"
+)
+
+func (s *Store) CreateRepository(ownerAccountId uint, name string, private bool) (*model.Repository, error) {
+	repo := new(model.Repository)
+	repo.OwnerAccountID = &ownerAccountId
+	repo.Name = name
+	repo.IsPrivate = private
+
+	if err := s.db.Create(&repo).Error; err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (s *Store) GetRepositoryById(repoId uint) (*model.Repository, error) {
+	var repo model.Repository
+	if err := s.db.Model(&repo).Where(&model.Repository{ID: repoId}).First(&repo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &repo, nil
+}
+
+func (s *Store) GetRepositoryByOwnerAndName(ownerAccountId uint, name string) (*model.Repository, error) {
+	var repo model.Repository
+	if err := s.db.Model(&repo).
+		Where(&model.Repository{OwnerAccountID: &ownerAccountId, Name: name}).
+		First(&repo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &repo, nil
+}
+
+// GetRepositoriesByOwnerAccountId はアカウントが所有するリポジトリを名前順で返します。
+// includePrivate が false の場合は、公開リポジトリと viewerUserId がコラボレーターの非公開リポジトリ、
+// viewerTeamIds のチームに与えられた非公開リポジトリを返します。
+func (s *Store) GetRepositoriesByOwnerAccountId(ownerAccountId uint, includePrivate bool, viewerUserId *uint, viewerTeamIds []uint, offset, limit int) ([]model.Repository, error) {
+	query := s.db.Model(&model.Repository{}).Where(&model.Repository{OwnerAccountID: &ownerAccountId})
+	if !includePrivate {
+		if viewerUserId != nil {
+			condition := s.db.Where("repositories.is_private = ?", false).
+				Or("repositories.id IN (SELECT repository_id FROM repository_collaborators WHERE user_id = ?)", *viewerUserId)
+			if len(viewerTeamIds) > 0 {
+				condition = condition.Or("repositories.id IN (SELECT repository_id FROM team_repositories WHERE team_id IN ?)", viewerTeamIds)
+			}
+			query = query.Where(condition)
+		} else {
+			query = query.Where("repositories.is_private = ?", false)
+		}
+	}
+
+	var repos []model.Repository
+	if err := query.Order("name").Offset(offset).Limit(limit).Find(&repos).Error; err != nil {
+		return nil, err
+	}
+
+	return repos, nil
+}
+
+// GetRepositoryIdsByOwnerAccountId はアカウントが所有するすべてのリポジトリのIDを返します。
+func (s *Store) GetRepositoryIdsByOwnerAccountId(ownerAccountId uint) ([]uint, error) {
+	var repoIds []uint
+	if err := s.db.Model(&model.Repository{}).
+		Where(&model.Repository{OwnerAccountID: &ownerAccountId}).
+		Pluck("id", &repoIds).Error; err != nil {
+		return nil, err
+	}
+
+	return repoIds, nil
+}
+
+func (s *Store) UpdateRepository(repoId uint, name string, private bool) error {
+	return s.db.Model(&model.Repository{ID: repoId}).
+		Select("name", "is_private").
+		Updates(&model.Repository{Name: name, IsPrivate: private}).Error
+}
+
+func (s *Store) DeleteRepository(repoId uint) error {
+	return s.db.Delete(&model.Repository{}, repoId).Error
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"gityard-api/service"
+	"gorm.io/gorm"
+)
+
+// Store はGORMでデータベースに保存する service.Store の実装です。
+// サービスには AuthServiceStore などのメソッドで、そのサービスが使うストアとして渡してください。
+type Store struct {
+	db *gorm.DB
+}
+
+var _ service.Store = (*Store)(nil)
+
+// NewStore は db に読み書きする Store を返します。
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// narrowed は Store をサービスのストア S として使うための型です。Transaction の中でも S として渡します。
+type narrowed[S any] struct {
+	*Store
+	as func(n *narrowed[S]) S
+}
+
+// narrow は s を S として返します。as は n をそのまま返す関数で、Store が S を満たさなければコンパイルできません。
+func narrow[S any](s *Store, as func(n *narrowed[S]) S) S {
+	return as(&narrowed[S]{Store: s, as: as})
+}
+
+// Transaction は fn をデータベースのトランザクションの中で実行します。
+func (n *narrowed[S]) Transaction(fn func(tx S) error) error {
+	return n.db.Transaction(func(tx *gorm.DB) error {
+		return fn(narrow(&Store{db: tx}, n.as))
+	})
+}
+
+// AuthServiceStore は AuthService に渡すストアを返します。
+func (s *Store) AuthServiceStore() service.AuthServiceStore {
+	return narrow(s, func(n *narrowed[service.AuthServiceStore]) service.AuthServiceStore { return n })
+}
+
+// TwoFactorServiceStore は TwoFactorService に渡すストアを返します。
+func (s *Store) TwoFactorServiceStore() service.TwoFactorServiceStore {
+	return narrow(s, func(n *narrowed[service.TwoFactorServiceStore]) service.TwoFactorServiceStore { return n })
+}
+
+// TokenServiceStore は TokenService に渡すストアを返します。
+func (s *Store) TokenServiceStore() service.TokenServiceStore {
+	return narrow(s, func(n *narrowed[service.TokenServiceStore]) service.TokenServiceStore { return n })
+}
+
+// KeyServiceStore は KeyService に渡すストアを返します。
+func (s *Store) KeyServiceStore() service.KeyServiceStore {
+	return narrow(s, func(n *narrowed[service.KeyServiceStore]) service.KeyServiceStore { return n })
+}
+
+// AccountServiceStore は AccountService に渡すストアを返します。
+func (s *Store) AccountServiceStore() service.AccountServiceStore {
+	return narrow(s, func(n *narrowed[service.AccountServiceStore]) service.AccountServiceStore { return n })
+}
+
+// OrganizationServiceStore は OrganizationService に渡すストアを返します。
+func (s *Store) OrganizationServiceStore() service.OrganizationServiceStore {
+	return narrow(s, func(n *narrowed[service.OrganizationServiceStore]) service.OrganizationServiceStore { return n })
+}
+
+// RepoServiceStore は RepoService に渡すストアを返します。
+func (s *Store) RepoServiceStore() service.RepoServiceStore {
+	return narrow(s, func(n *narrowed[service.RepoServiceStore]) service.RepoServiceStore { return n })
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"errors"
+	"gityard-api/model"
+	"gorm.io/gorm"
+)
+
+func (s *Store) CreateTeam(organizationAccountId uint, name, description string, parentTeamId *uint) (*model.Team, error) {
+	team := new(model.Team)
+	team.OrganizationAccountID = organizationAccountId
+	team.Name = name
+	team.Description = description
+	team.ParentTeamID = parentTeamId
+
+	if err := s.db.Create(&team).Error; err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (s *Store) GetTeamById(teamId uint) (*model.Team, error) {
+	var team model.Team
+	if err := s.db.Model(&team).Where(&model.Team{ID: teamId}).First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (s *Store) GetTeamByName(organizationAccountId uint, name string) (*model.Team, error) {
+	var team model.Team
+	if err := s.db.Model(&team).
+		Preload("ParentTeam").
+		Where(&model.Team{OrganizationAccountID: organizationAccountId, Name: name}).
+		First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (s *Store) GetTeamsByOrganizationAccountId(organizationAccountId uint, offset, limit int) ([]model.Team, error) {
+	var teams []model.Team
+	if err := s.db.Model(&model.Team{}).
+		Preload("ParentTeam").
+		Where(&model.Team{OrganizationAccountID: organizationAccountId}).
+		Order("name").
+		Offset(offset).Limit(limit).
+		Find(&teams).Error; err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// UpdateTeam はチームの名前と説明、親チームを更新します。parentTeamId が nil なら親チームを外します。
+func (s *Store) UpdateTeam(teamId uint, name, description string, parentTeamId *uint) error {
+	return s.db.Model(&model.Team{ID: teamId}).
+		Select("name", "description", "parent_team_id").
+		Updates(&model.Team{Name: name, Description: description, ParentTeamID: parentTeamId}).Error
+}
+
+// ReparentChildTeams は子チームの親チームを付け替えます。チームを削除する前に呼びます。
+func (s *Store) ReparentChildTeams(teamId uint, parentTeamId *uint) error {
+	return s.db.Model(&model.Team{}).
+		Where("parent_team_id = ?", teamId).
+		Update("parent_team_id", parentTeamId).Error
+}
+
+func (s *Store) DeleteTeam(teamId uint) error {
+	return s.db.Delete(&model.Team{}, teamId).Error
+}
+
+func (s *Store) CreateTeamMember(teamId, userId uint) (*model.TeamMember, error) {
+	member := new(model.TeamMember)
+	member.TeamID = teamId
+	member.UserID = userId
+
+	if err := s.db.Create(&member).Error; err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (s *Store) GetTeamMember(teamId, userId uint) (*model.TeamMember, error) {
+	var member model.TeamMember
+	if err := s.db.Model(&member).
+		Where(&model.TeamMember{TeamID: teamId, UserID: userId}).
+		First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (s *Store) GetTeamMembers(teamId uint, offset, limit int) ([]model.TeamMember, error) {
+	var members []model.TeamMember
+	if err := s.db.Model(&model.TeamMember{}).
+		Where(&model.TeamMember{TeamID: teamId}).
+		Order("created_at").
+		Offset(offset).Limit(limit).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// GetTeamIdsByUserId はユーザが直接所属している組織内のチームのIDを返します。
+func (s *Store) GetTeamIdsByUserId(organizationAccountId, userId uint) ([]uint, error) {
+	var teamIds []uint
+	if err := s.db.Model(&model.TeamMember{}).
+		Joins("JOIN teams ON teams.id = team_members.team_id").
+		Where("teams.organization_account_id = ? AND team_members.user_id = ?", organizationAccountId, userId).
+		Pluck("team_members.team_id", &teamIds).Error; err != nil {
+		return nil, err
+	}
+
+	return teamIds, nil
+}
+
+func (s *Store) DeleteTeamMember(teamId, userId uint) error {
+	return s.db.Where(&model.TeamMember{TeamID: teamId, UserID: userId}).
+		Delete(&model.TeamMember{}).Error
+}
+
+// DeleteTeamMembersByUserId はユーザを組織内の全チームから外します。組織から脱退するときに使います。
+func (s *Store) DeleteTeamMembersByUserId(organizationAccountId, userId uint) error {
+	return s.db.Where("user_id = ? AND team_id IN (SELECT id FROM teams WHERE organization_account_id = ?)", userId, organizationAccountId).
+		Delete(&model.TeamMember{}).Error
+}
+
+func (s *Store) CreateTeamRepository(teamId, repoId uint, role model.RepositoryRole) (*model.TeamRepository, error) {
+	teamRepo := new(model.TeamRepository)
+	teamRepo.TeamID = teamId
+	teamRepo.RepositoryID = repoId
+	teamRepo.Role = int(role)
+
+	if err := s.db.Create(&teamRepo).Error; err != nil {
+		return nil, err
+	}
+
+	return teamRepo, nil
+}
+
+func (s *Store) GetTeamRepository(teamId, repoId uint) (*model.TeamRepository, error) {
+	var teamRepo model.TeamRepository
+	if err := s.db.Model(&teamRepo).
+		Where(&model.TeamRepository{TeamID: teamId, RepositoryID: repoId}).
+		First(&teamRepo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &teamRepo, nil
+}
+
+func (s *Store) GetTeamRepositories(teamId uint, offset, limit int) ([]model.TeamRepository, error) {
+	var teamRepos []model.TeamRepository
+	if err := s.db.Model(&model.TeamRepository{}).
+		Joins("Repository").
+		Where(&model.TeamRepository{TeamID: teamId}).
+		Order("Repository.name").
+		Offset(offset).Limit(limit).
+		Find(&teamRepos).Error; err != nil {
+		return nil, err
+	}
+
+	return teamRepos, nil
+}
+
+// GetMaxTeamRepositoryRole はチームがリポジトリに持つ役割のうち最も強いものを返します。どのチームにも与えられていなければ 0 です。
+func (s *Store) GetMaxTeamRepositoryRole(repoId uint, teamIds []uint) (model.RepositoryRole, error) {
+	if len(teamIds) == 0 {
+		return 0, nil
+	}
+
+	var role *int
+	if err := s.db.Model(&model.TeamRepository{}).
+		Select("MAX(role)").
+		Where("repository_id = ? AND team_id IN ?", repoId, teamIds).
+		Scan(&role).Error; err != nil {
+		return 0, err
+	}
+	if role == nil {
+		return 0, nil
+	}
+
+	return model.RepositoryRole(*role), nil
+}
+
+func (s *Store) UpdateTeamRepositoryRole(teamId, repoId uint, role model.RepositoryRole) error {
+	return s.db.Model(&model.TeamRepository{}).
+		Where(&model.TeamRepository{TeamID: teamId, RepositoryID: repoId}).
+		Update("role", int(role)).Error
+}
+
+func (s *Store) DeleteTeamRepository(teamId, repoId uint) error {
+	return s.db.Where(&model.TeamRepository{TeamID: teamId, RepositoryID: repoId}).
+		Delete(&model.TeamRepository{}).Error
+}
@@ -10,20 +10,21 @@ import (
 	"time"
 )
 
-func CreateUser(db *gorm.DB, email string) (*model.User, error) {
+func (s *Store) CreateUser(email, locale string) (*model.User, error) {
 	user := new(model.User)
 	user.Email = &email
+	user.Locale = locale
 
-	if err := db.Create(&user).Error; err != nil {
+	if err := s.db.Create(&user).Error; err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-func GetUserById(db *gorm.DB, userId uint) (*model.User, error) {
+func (s *Store) GetUserById(userId uint) (*model.User, error) {
 	var user model.User
-	if err := db.Model(&user).Where(&model.User{ID: userId}).First(&user).Error; err != nil {
+	if err := s.db.Model(&user).Where(&model.User{ID: userId}).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -32,9 +33,9 @@ func GetUserById(db *gorm.DB, userId uint) (*model.User, error) {
 	return &user, nil
 }
 
-func GetUserByEmail(db *gorm.DB, email string) (*model.User, error) {
+func (s *Store) GetUserByEmail(email string) (*model.User, error) {
 	var user model.User
-	if err := db.Model(&user).Where(&model.User{Email: &email}).First(&user).Error; err != nil {
+	if err := s.db.Model(&user).Where(&model.User{Email: &email}).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -44,7 +45,37 @@ func GetUserByEmail(db *gorm.DB, email string) (*model.User, error) {
 	return &user, nil
 }
 
-func CreateUserCredential(db *gorm.DB, userId uint, plainPassword string) (*model.UserCredential, error) {
+// UpdateUserDeletionScheduledAt は退会の予定日時を設定します。nil なら退会を取り消します。
+func (s *Store) UpdateUserDeletionScheduledAt(userId uint, scheduledAt *time.Time) error {
+	return s.db.Model(&model.User{ID: userId}).Update("deletion_scheduled_at", scheduledAt).Error
+}
+
+// GetUsersDueForDeletion は退会の予定日時を過ぎたユーザを返します。
+func (s *Store) GetUsersDueForDeletion(now time.Time, limit int) ([]model.User, error) {
+	var users []model.User
+	if err := s.db.Model(&model.User{}).
+		Where("deletion_scheduled_at <= ?", now).
+		Where("is_deleted = ?", false).
+		Order("deletion_scheduled_at").
+		Limit(limit).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SoftDeleteUser はユーザを退会済みにし、メールアドレスを解放します。
+func (s *Store) SoftDeleteUser(userId uint) error {
+	return s.db.Model(&model.User{ID: userId}).Updates(map[string]any{
+		"email":                 nil,
+		"is_deleted":            true,
+		"email_verified_at":     nil,
+		"deletion_scheduled_at": nil,
+	}).Error
+}
+
+func (s *Store) CreateUserCredential(userId uint, plainPassword string) (*model.UserCredential, error) {
 	hashedPassword, err := security.HashPassword(plainPassword)
 	if err != nil {
 		return nil, err
@@ -54,16 +85,16 @@ func CreateUserCredential(db *gorm.DB, userId uint, plainPassword string) (*mode
 	credential.UserID = userId
 	credential.HashedPassword = hashedPassword
 
-	if err := db.Create(credential).Error; err != nil {
+	if err := s.db.Create(credential).Error; err != nil {
 		return nil, err
 	}
 
 	return credential, nil
 }
 
-func GetUserCredentialById(db *gorm.DB, userId uint) (*model.UserCredential, error) {
+func (s *Store) GetUserCredentialById(userId uint) (*model.UserCredential, error) {
 	var credential model.UserCredential
-	if err := db.Model(&credential).Where(&model.UserCredential{UserID: userId}).First(&credential).Error; err != nil {
+	if err := s.db.Model(&credential).Where(&model.UserCredential{UserID: userId}).First(&credential).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -73,29 +104,124 @@ func GetUserCredentialById(db *gorm.DB, userId uint) (*model.UserCredential, err
 	return &credential, nil
 }
 
-func CreateOrUpdateUserRefreshToken(db *gorm.DB, userId uint) (*model.RefreshToken, error) {
+// UpdateUserCredentialPassword はパスワードを変更します。
+func (s *Store) UpdateUserCredentialPassword(userId uint, plainPassword string) error {
+	hashedPassword, err := security.HashPassword(plainPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Model(&model.UserCredential{UserID: userId}).Update("hashed_password", hashedPassword).Error
+}
+
+func (s *Store) UpdateUserEmailVerifiedAt(userId uint, verifiedAt time.Time) error {
+	return s.db.Model(&model.User{ID: userId}).Update("email_verified_at", verifiedAt).Error
+}
+
+func (s *Store) CreateUserVerificationToken(userId uint, kind model.VerificationTokenKind, token, email string, expiresAt time.Time) (*model.UserVerificationToken, error) {
+	verificationToken := new(model.UserVerificationToken)
+	verificationToken.UserID = userId
+	verificationToken.Kind = int(kind)
+	verificationToken.HashedToken = hashToken(token)
+	verificationToken.Email = email
+	verificationToken.ExpiresAt = expiresAt
+
+	if err := s.db.Create(verificationToken).Error; err != nil {
+		return nil, err
+	}
+
+	return verificationToken, nil
+}
+
+func (s *Store) GetUserVerificationTokenByToken(kind model.VerificationTokenKind, token string) (*model.UserVerificationToken, error) {
+	var verificationToken model.UserVerificationToken
+	if err := s.db.Model(&verificationToken).
+		Where(&model.UserVerificationToken{Kind: int(kind), HashedToken: hashToken(token)}).
+		First(&verificationToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &verificationToken, nil
+}
+
+// DeleteUserVerificationTokensByUserId はユーザの同じ種類のトークンをすべて削除します。最後に送ったリンクだけを使えるようにするためです。
+func (s *Store) DeleteUserVerificationTokensByUserId(userId uint, kind model.VerificationTokenKind) error {
+	return s.db.Where(&model.UserVerificationToken{UserID: userId, Kind: int(kind)}).Delete(&model.UserVerificationToken{}).Error
+}
+
+// hashToken はリフレッシュトークンやアクセストークンをDBに保存する形にします。
+func hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+// DeleteUserCredential はパスワードを削除します。退会したユーザはログインできなくなります。
+func (s *Store) DeleteUserCredential(userId uint) error {
+	return s.db.Where(&model.UserCredential{UserID: userId}).Delete(&model.UserCredential{}).Error
+}
+
+// CreateUserRefreshToken は新しいセッションのリフレッシュトークンを発行します。
+func (s *Store) CreateUserRefreshToken(userId uint, userAgent, ipAddress string) (*model.UserRefreshToken, *model.RefreshToken, error) {
+	refreshToken, err := security.GenerateRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	userRefreshToken := new(model.UserRefreshToken)
+	userRefreshToken.UserID = userId
+	userRefreshToken.HashedRefreshToken = hashToken(refreshToken.Body)
+	userRefreshToken.UserAgent = userAgent
+	userRefreshToken.IPAddress = ipAddress
+	userRefreshToken.ExpiresAt = now.Add(refreshToken.ExpiresIn)
+	userRefreshToken.LastUsedAt = now
+
+	if err := s.db.Create(&userRefreshToken).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return userRefreshToken, refreshToken, nil
+}
+
+// UpdateUserRefreshToken はセッションのリフレッシュトークンを新しいものに差し替え、最終利用日時を更新します。
+// 差し替え前のトークンは再利用を検知できるようにローテーション済みとして残します。
+func (s *Store) UpdateUserRefreshToken(current *model.UserRefreshToken, userAgent, ipAddress string) (*model.RefreshToken, error) {
 	refreshToken, err := security.GenerateRefreshToken()
 	if err != nil {
 		return nil, err
 	}
 
-	hashedRefreshToken := fmt.Sprintf("%x", sha256.Sum256([]byte(refreshToken.Body)))
-	data := model.UserRefreshToken{
-		HashedRefreshToken: hashedRefreshToken,
-		ExpiresAt:          time.Now().Add(refreshToken.ExpiresIn),
+	rotated := model.UserRotatedRefreshToken{
+		HashedRefreshToken: current.HashedRefreshToken,
+		RefreshTokenID:     current.ID,
+		ExpiresAt:          current.ExpiresAt,
+	}
+	if err := s.db.Create(&rotated).Error; err != nil {
+		return nil, err
 	}
-	userRefreshToken := model.UserRefreshToken{UserID: userId}
+	sessionId := current.ID
 
-	if err := db.Where(&userRefreshToken).Assign(data).FirstOrCreate(&userRefreshToken).Error; err != nil {
+	now := time.Now()
+	if err := s.db.Model(&model.UserRefreshToken{ID: sessionId}).
+		Select("hashed_refresh_token", "user_agent", "ip_address", "expires_at", "last_used_at").
+		Updates(&model.UserRefreshToken{
+			HashedRefreshToken: hashToken(refreshToken.Body),
+			UserAgent:          userAgent,
+			IPAddress:          ipAddress,
+			ExpiresAt:          now.Add(refreshToken.ExpiresIn),
+			LastUsedAt:         now,
+		}).Error; err != nil {
 		return nil, err
 	}
 
 	return refreshToken, nil
 }
 
-func GetUserRefreshTokenById(db *gorm.DB, userId uint) (*model.UserRefreshToken, error) {
+func (s *Store) GetUserRefreshTokenById(sessionId uint) (*model.UserRefreshToken, error) {
 	var refreshToken model.UserRefreshToken
-	if err := db.Model(&refreshToken).Where(&model.UserRefreshToken{UserID: userId}).First(&refreshToken).Error; err != nil {
+	if err := s.db.Model(&refreshToken).Where(&model.UserRefreshToken{ID: sessionId}).First(&refreshToken).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -105,64 +231,404 @@ func GetUserRefreshTokenById(db *gorm.DB, userId uint) (*model.UserRefreshToken,
 	return &refreshToken, nil
 }
 
-func GetUserIdAndExpiresAtByRefreshToken(db *gorm.DB, refreshToken string) (*uint, *time.Time, error) {
-	hashedRefreshToken := fmt.Sprintf("%x", sha256.Sum256([]byte(refreshToken)))
+func (s *Store) GetUserRefreshTokenByRefreshToken(refreshToken string) (*model.UserRefreshToken, error) {
 	var userRefreshToken model.UserRefreshToken
-	if err := db.Model(&userRefreshToken).Where(&model.UserRefreshToken{HashedRefreshToken: hashedRefreshToken}).First(&userRefreshToken).Error; err != nil {
+	if err := s.db.Model(&userRefreshToken).
+		Where(&model.UserRefreshToken{HashedRefreshToken: hashToken(refreshToken)}).
+		First(&userRefreshToken).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil, nil
+			return nil, nil
 		}
-		return nil, nil, err
+		return nil, err
+	}
+
+	return &userRefreshToken, nil
+}
+
+// GetRotatedUserRefreshToken はローテーション済みのリフレッシュトークンを取得します。
+func (s *Store) GetRotatedUserRefreshToken(refreshToken string) (*model.UserRotatedRefreshToken, error) {
+	var rotated model.UserRotatedRefreshToken
+	if err := s.db.Model(&rotated).
+		Where(&model.UserRotatedRefreshToken{HashedRefreshToken: hashToken(refreshToken)}).
+		First(&rotated).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &rotated, nil
+}
+
+// GetUserRefreshTokensByUserId はユーザの期限切れでないセッションを最近使った順に返します。
+func (s *Store) GetUserRefreshTokensByUserId(userId uint, offset, limit int) ([]model.UserRefreshToken, error) {
+	var refreshTokens []model.UserRefreshToken
+	if err := s.db.Model(&model.UserRefreshToken{}).
+		Where(&model.UserRefreshToken{UserID: userId}).
+		Where("expires_at > ?", time.Now()).
+		Order("last_used_at DESC").
+		Offset(offset).Limit(limit).
+		Find(&refreshTokens).Error; err != nil {
+		return nil, err
 	}
 
-	return &userRefreshToken.UserID, &userRefreshToken.ExpiresAt, nil
+	return refreshTokens, nil
+}
+
+func (s *Store) DeleteUserRefreshToken(sessionId uint) error {
+	return s.db.Delete(&model.UserRefreshToken{}, sessionId).Error
 }
 
-func DeleteUserRefreshToken(db *gorm.DB, userId uint) error {
-	return db.Delete(&model.UserRefreshToken{}, userId).Error
+// DeleteUserRefreshTokensByUserId はユーザのセッションをすべて削除します。exceptSessionId が0でなければそのセッションだけ残します。
+func (s *Store) DeleteUserRefreshTokensByUserId(userId, exceptSessionId uint) error {
+	query := s.db.Where(&model.UserRefreshToken{UserID: userId})
+	if exceptSessionId != 0 {
+		query = query.Where("id <> ?", exceptSessionId)
+	}
+	return query.Delete(&model.UserRefreshToken{}).Error
 }
 
-func CreatePublicKey(db *gorm.DB, userId uint, name, fullKeyText, algorithm, keyBody, comment, fingerprint string) (*model.UserPublicKey, error) {
-	pubkey := new(model.UserPublicKey)
-	pubkey.UserID = userId
-	pubkey.Name = name
-	pubkey.FullKeyText = fullKeyText
-	pubkey.Algorithm = algorithm
-	pubkey.Keybody = keyBody
-	pubkey.Comment = comment
-	pubkey.Fingerprint = fingerprint
+// IncrementUserTokenGeneration はユーザのトークンの世代を1つ進めて、新しい世代を返します。
+// それまでに発行したアクセストークンはすべて使えなくなります。
+func (s *Store) IncrementUserTokenGeneration(userId uint) (uint, error) {
+	if err := s.db.Model(&model.User{ID: userId}).
+		Update("token_generation", gorm.Expr("token_generation + 1")).Error; err != nil {
+		return 0, err
+	}
+
+	var user model.User
+	if err := s.db.Model(&user).Select("token_generation").Where(&model.User{ID: userId}).First(&user).Error; err != nil {
+		return 0, err
+	}
+
+	return user.TokenGeneration, nil
+}
+
+// CreateRevokedAccessToken はアクセストークンを期限まで失効させます。
+func (s *Store) CreateRevokedAccessToken(tokenId string, userId uint, expiresAt time.Time) error {
+	revoked := model.RevokedAccessToken{
+		JTI:       tokenId,
+		UserID:    userId,
+		ExpiresAt: expiresAt,
+	}
+	return s.db.Create(&revoked).Error
+}
 
-	if err := db.Create(&pubkey).Error; err != nil {
+func (s *Store) GetRevokedAccessToken(tokenId string) (*model.RevokedAccessToken, error) {
+	var revoked model.RevokedAccessToken
+	if err := s.db.Model(&revoked).Where(&model.RevokedAccessToken{JTI: tokenId}).First(&revoked).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	return pubkey, nil
+	return &revoked, nil
 }
 
-func GetPubKeyByFingerprint(db *gorm.DB, fingerprint string) (*model.UserPublicKey, error) {
-	var pubkey model.UserPublicKey
-	if err := db.Model(&pubkey).Where(&model.UserPublicKey{Fingerprint: fingerprint}).First(&pubkey).Error; err != nil {
+// DeleteExpiredRevokedAccessTokens は期限が切れて、記録しておく必要がなくなった失効済みのアクセストークンを削除します。
+func (s *Store) DeleteExpiredRevokedAccessTokens(now time.Time) error {
+	return s.db.Where("expires_at <= ?", now).Delete(&model.RevokedAccessToken{}).Error
+}
+
+func (s *Store) CreateUserAccessToken(userId uint, name, token, scopes string, expiresAt *time.Time) (*model.UserAccessToken, error) {
+	accessToken := new(model.UserAccessToken)
+	accessToken.UserID = userId
+	accessToken.Name = name
+	accessToken.HashedToken = hashToken(token)
+	accessToken.Scopes = scopes
+	accessToken.ExpiresAt = expiresAt
+
+	if err := s.db.Create(&accessToken).Error; err != nil {
+		return nil, err
+	}
+
+	return accessToken, nil
+}
+
+func (s *Store) GetUserAccessTokenById(tokenId uint) (*model.UserAccessToken, error) {
+	var accessToken model.UserAccessToken
+	if err := s.db.Model(&accessToken).Where(&model.UserAccessToken{ID: tokenId}).First(&accessToken).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
 
-	return &pubkey, nil
+	return &accessToken, nil
 }
 
-func GetPubkeysByUserId(db *gorm.DB, userId uint, offset, limit int) ([]model.UserPublicKey, error) {
-	var pubkeys []model.UserPublicKey
-	if err := db.Model(&model.UserPublicKey{}).
-		Where(&model.UserPublicKey{UserID: userId}).
-		Find(&pubkeys).
-		Offset(offset).Limit(limit).Error; err != nil {
+func (s *Store) GetUserAccessTokenByToken(token string) (*model.UserAccessToken, error) {
+	var accessToken model.UserAccessToken
+	if err := s.db.Model(&accessToken).Where(&model.UserAccessToken{HashedToken: hashToken(token)}).First(&accessToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &accessToken, nil
+}
+
+func (s *Store) GetUserAccessTokensByUserId(userId uint, offset, limit int) ([]model.UserAccessToken, error) {
+	var accessTokens []model.UserAccessToken
+	if err := s.db.Model(&model.UserAccessToken{}).
+		Where(&model.UserAccessToken{UserID: userId}).
+		Order("id DESC").
+		Offset(offset).Limit(limit).
+		Find(&accessTokens).Error; err != nil {
 		return nil, err
 	}
 
-	return pubkeys, nil
+	return accessTokens, nil
+}
+
+func (s *Store) UpdateUserAccessTokenLastUsedAt(tokenId uint, lastUsedAt time.Time) error {
+	return s.db.Model(&model.UserAccessToken{ID: tokenId}).Update("last_used_at", lastUsedAt).Error
+}
+
+func (s *Store) DeleteUserAccessToken(tokenId uint) error {
+	return s.db.Delete(&model.UserAccessToken{}, tokenId).Error
+}
+
+func (s *Store) DeleteUserAccessTokensByUserId(userId uint) error {
+	return s.db.Where(&model.UserAccessToken{UserID: userId}).Delete(&model.UserAccessToken{}).Error
+}
+
+func (s *Store) GetUserTwoFactorByUserId(userId uint) (*model.UserTwoFactor, error) {
+	var twoFactor model.UserTwoFactor
+	if err := s.db.Model(&twoFactor).Where(&model.UserTwoFactor{UserID: userId}).First(&twoFactor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+// CreateUserTwoFactor は無効の状態で二要素認証の設定を作成します。
+func (s *Store) CreateUserTwoFactor(userId uint, secret string) (*model.UserTwoFactor, error) {
+	twoFactor := new(model.UserTwoFactor)
+	twoFactor.UserID = userId
+	twoFactor.Secret = secret
+
+	if err := s.db.Create(&twoFactor).Error; err != nil {
+		return nil, err
+	}
+
+	return twoFactor, nil
+}
+
+// EnableUserTwoFactor は二要素認証を有効にします。確認に使ったコードのステップを使用済みにします。
+func (s *Store) EnableUserTwoFactor(userId uint, step int64, enabledAt time.Time) error {
+	return s.db.Model(&model.UserTwoFactor{UserID: userId}).
+		Select("is_enabled", "last_used_step", "enabled_at").
+		Updates(&model.UserTwoFactor{
+			IsEnabled:    true,
+			LastUsedStep: step,
+			EnabledAt:    &enabledAt,
+		}).Error
+}
+
+// UpdateUserTwoFactorLastUsedStep は使用済みのステップを進めます。
+// 同じか前のステップが既に使われていた場合は更新せずに false を返します。
+func (s *Store) UpdateUserTwoFactorLastUsedStep(userId uint, step int64) (bool, error) {
+	result := s.db.Model(&model.UserTwoFactor{}).
+		Where(&model.UserTwoFactor{UserID: userId}).
+		Where("last_used_step < ?", step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (s *Store) DeleteUserTwoFactor(userId uint) error {
+	return s.db.Delete(&model.UserTwoFactor{}, userId).Error
+}
+
+func (s *Store) CreateUserRecoveryCodes(userId uint, codes []string) error {
+	recoveryCodes := make([]model.UserRecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		recoveryCodes = append(recoveryCodes, model.UserRecoveryCode{
+			UserID:     userId,
+			HashedCode: hashToken(code),
+		})
+	}
+
+	return s.db.Create(&recoveryCodes).Error
+}
+
+// CountUnusedUserRecoveryCodes はユーザの未使用のリカバリーコードの数を返します。
+func (s *Store) CountUnusedUserRecoveryCodes(userId uint) (int64, error) {
+	var count int64
+	if err := s.db.Model(&model.UserRecoveryCode{}).
+		Where(&model.UserRecoveryCode{UserID: userId}).
+		Where("used_at IS NULL").
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// UseUserRecoveryCode は未使用のリカバリーコードを使用済みにします。該当するコードがなければ false を返します。
+func (s *Store) UseUserRecoveryCode(userId uint, code string, usedAt time.Time) (bool, error) {
+	result := s.db.Model(&model.UserRecoveryCode{}).
+		Where(&model.UserRecoveryCode{UserID: userId, HashedCode: hashToken(code)}).
+		Where("used_at IS NULL").
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (s *Store) DeleteUserRecoveryCodesByUserId(userId uint) error {
+	return s.db.Where(&model.UserRecoveryCode{UserID: userId}).Delete(&model.UserRecoveryCode{}).Error
+}
+
+func (s *Store) CreateUserTwoFactorChallenge(userId uint, token string, expiresAt time.Time) (*model.UserTwoFactorChallenge, error) {
+	challenge := new(model.UserTwoFactorChallenge)
+	challenge.UserID = userId
+	challenge.HashedToken = hashToken(token)
+	challenge.ExpiresAt = expiresAt
+
+	if err := s.db.Create(&challenge).Error; err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (s *Store) GetUserTwoFactorChallengeByToken(token string) (*model.UserTwoFactorChallenge, error) {
+	var challenge model.UserTwoFactorChallenge
+	if err := s.db.Model(&challenge).
+		Where(&model.UserTwoFactorChallenge{HashedToken: hashToken(token)}).
+		First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (s *Store) UpdateUserTwoFactorChallengeFailedAttempts(challengeId uint, failedAttempts int) error {
+	return s.db.Model(&model.UserTwoFactorChallenge{ID: challengeId}).Update("failed_attempts", failedAttempts).Error
+}
+
+func (s *Store) DeleteUserTwoFactorChallenge(challengeId uint) error {
+	return s.db.Delete(&model.UserTwoFactorChallenge{}, challengeId).Error
+}
+
+// DeleteUserTwoFactorChallengesByUserId はパスワードを確認済みで二要素認証を待っているログインをすべて破棄します。
+func (s *Store) DeleteUserTwoFactorChallengesByUserId(userId uint) error {
+	return s.db.Where(&model.UserTwoFactorChallenge{UserID: userId}).Delete(&model.UserTwoFactorChallenge{}).Error
+}
+
+// UpdateUserTwoFactorChallengeWebAuthnSessionData はチャレンジにWebAuthnのセレモニーのセッションを保存します。
+func (s *Store) UpdateUserTwoFactorChallengeWebAuthnSessionData(challengeId uint, sessionData string) error {
+	return s.db.Model(&model.UserTwoFactorChallenge{ID: challengeId}).Update("webauthn_session_data", sessionData).Error
+}
+
+func (s *Store) CreateUserWebAuthnCredential(credential *model.UserWebAuthnCredential) error {
+	return s.db.Create(credential).Error
+}
+
+func (s *Store) GetUserWebAuthnCredentialById(credentialId uint) (*model.UserWebAuthnCredential, error) {
+	var credential model.UserWebAuthnCredential
+	if err := s.db.Model(&credential).Where(&model.UserWebAuthnCredential{ID: credentialId}).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+func (s *Store) GetUserWebAuthnCredentialsByUserId(userId uint) ([]model.UserWebAuthnCredential, error) {
+	var credentials []model.UserWebAuthnCredential
+	if err := s.db.Model(&model.UserWebAuthnCredential{}).
+		Where(&model.UserWebAuthnCredential{UserID: userId}).
+		Order("id").
+		Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+func (s *Store) CountUserWebAuthnCredentials(userId uint) (int64, error) {
+	var count int64
+	if err := s.db.Model(&model.UserWebAuthnCredential{}).
+		Where(&model.UserWebAuthnCredential{UserID: userId}).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// UpdateUserWebAuthnCredentialUsage はログインに使ったクレデンシャルの署名カウンタと最終利用日時を更新します。
+func (s *Store) UpdateUserWebAuthnCredentialUsage(credentialId uint, signCount uint32, lastUsedAt time.Time) error {
+	return s.db.Model(&model.UserWebAuthnCredential{ID: credentialId}).
+		Select("sign_count", "last_used_at").
+		Updates(&model.UserWebAuthnCredential{
+			SignCount:  signCount,
+			LastUsedAt: &lastUsedAt,
+		}).Error
+}
+
+// UpdateUserWebAuthnCredentialCloneWarning はクローンが疑われるクレデンシャルに印を付けます。
+func (s *Store) UpdateUserWebAuthnCredentialCloneWarning(credentialId uint) error {
+	return s.db.Model(&model.UserWebAuthnCredential{ID: credentialId}).Update("clone_warning", true).Error
+}
+
+func (s *Store) DeleteUserWebAuthnCredential(credentialId uint) error {
+	return s.db.Delete(&model.UserWebAuthnCredential{}, credentialId).Error
+}
+
+func (s *Store) DeleteUserWebAuthnCredentialsByUserId(userId uint) error {
+	return s.db.Where(&model.UserWebAuthnCredential{UserID: userId}).Delete(&model.UserWebAuthnCredential{}).Error
+}
+
+func (s *Store) CreateWebAuthnCeremony(userId *uint, kind model.WebAuthnCeremonyKind, token, sessionData string, expiresAt time.Time) (*model.WebAuthnCeremony, error) {
+	ceremony := new(model.WebAuthnCeremony)
+	ceremony.UserID = userId
+	ceremony.HashedToken = hashToken(token)
+	ceremony.Kind = int(kind)
+	ceremony.SessionData = sessionData
+	ceremony.ExpiresAt = expiresAt
+
+	if err := s.db.Create(&ceremony).Error; err != nil {
+		return nil, err
+	}
+
+	return ceremony, nil
+}
+
+func (s *Store) GetWebAuthnCeremonyByToken(token string) (*model.WebAuthnCeremony, error) {
+	var ceremony model.WebAuthnCeremony
+	if err := s.db.Model(&ceremony).Where(&model.WebAuthnCeremony{HashedToken: hashToken(token)}).First(&ceremony).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &ceremony, nil
+}
+
+func (s *Store) DeleteWebAuthnCeremony(ceremonyId uint) error {
+	return s.db.Delete(&model.WebAuthnCeremony{}, ceremonyId).Error
 }
 
-func DeletePublicKeyByFingerprint(db *gorm.DB, userId uint, fingerprint string) error {
-	return db.Where(&model.UserPublicKey{UserID: userId, Fingerprint: fingerprint}).Delete(&model.UserPublicKey{}).Error
+func (s *Store) DeleteWebAuthnCeremoniesByUserId(userId uint) error {
+	return s.db.Where(&model.WebAuthnCeremony{UserID: &userId}).Delete(&model.WebAuthnCeremony{}).Error
 }
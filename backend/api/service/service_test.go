@@ -0,0 +1,770 @@
+package service_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"gityard-api/config"
+	"gityard-api/database"
+	"gityard-api/model"
+	"gityard-api/security"
+	"gityard-api/service"
+	"gityard-api/service/repository"
+	"gityard-api/storage"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+type testServices struct {
+	Auth   *service.AuthService
+	Keys   *service.KeyService
+	Tokens *service.TokenService
+	Repos  *service.RepoService
+
+	Accounts      *service.AccountService
+	Organizations *service.OrganizationService
+
+	DB           *gorm.DB
+	Repositories *storage.RepositoryStorage
+}
+
+// setupTestDB はテストごとに新しいSQLiteのデータベースとストレージを用意して、それを使うサービスを返します。
+func setupTestDB(t *testing.T) *testServices {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Secret = "0123456789abcdef0123456789abcdef"
+	assert.Nil(t, security.Setup(&cfg))
+
+	db, err := database.OpenSQLite(filepath.Join(dir, "gityard.db"))
+	assert.Nil(t, err)
+	migrator, err := database.NewMigrator(db)
+	assert.Nil(t, err)
+	_, err = migrator.Up()
+	assert.Nil(t, err)
+
+	blobs, err := storage.NewBlobStore("local", filepath.Join(dir, "blobs"))
+	assert.Nil(t, err)
+	repositories, err := storage.NewRepositoryStorage(filepath.Join(dir, "git"))
+	assert.Nil(t, err)
+
+	store := repository.NewStore(db)
+	return &testServices{
+		Auth:   service.NewAuthService(store.AuthServiceStore(), blobs, &cfg),
+		Keys:   service.NewKeyService(store.KeyServiceStore()),
+		Tokens: service.NewTokenService(store.TokenServiceStore()),
+		Repos:  service.NewRepoService(store.RepoServiceStore(), repositories, &cfg),
+
+		Accounts:      service.NewAccountService(store.AccountServiceStore(), repositories, blobs, &cfg),
+		Organizations: service.NewOrganizationService(store.OrganizationServiceStore(), blobs),
+		DB:            db,
+		Repositories:  repositories,
+	}
+}
+
+func signUp(t *testing.T, s *testServices, email, handlename string) *service.Session {
+	session, err := s.Auth.SignUp(email, "password123", handlename, "en", service.SessionClient{})
+	assert.Nil(t, err)
+	return session
+}
+
+// enableTwoFactor は認証アプリのコードを使わずに、ユーザの二要素認証を有効にします。
+func enableTwoFactor(t *testing.T, s *testServices, userId uint) {
+	assert.Nil(t, s.DB.Exec("insert into user_two_factors (user_id, secret, is_enabled) values (?, ?, 1)", userId, security.GenerateTOTPSecret()).Error)
+}
+
+func TestSignUpAndLogin(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+
+	t.Run("email and handlename are unique", func(t *testing.T) {
+		_, err := s.Auth.SignUp("alice@example.com", "password123", "alice2", "en", service.SessionClient{})
+		var registeredEmailErr *service.ErrRegisteredEmail
+		assert.ErrorAs(t, err, &registeredEmailErr)
+
+		_, err = s.Auth.SignUp("alice2@example.com", "password123", "alice", "en", service.SessionClient{})
+		var registeredHandleNameErr *service.ErrRegisteredHandleName
+		assert.ErrorAs(t, err, &registeredHandleNameErr)
+	})
+
+	t.Run("login with password", func(t *testing.T) {
+		session, challenge, err := s.Auth.Login("alice@example.com", "password123", service.SessionClient{UserAgent: "test"})
+		assert.Nil(t, err)
+		assert.Nil(t, challenge)
+		assert.Equal(t, alice.UserId, session.UserId)
+		assert.NotEqual(t, alice.SessionId, session.SessionId)
+
+		_, _, err = s.Auth.Login("alice@example.com", "wrong-password", service.SessionClient{})
+		var passwordMissMatchErr *service.ErrPasswordMissMatch
+		assert.ErrorAs(t, err, &passwordMissMatchErr)
+
+		_, _, err = s.Auth.Login("nobody@example.com", "password123", service.SessionClient{})
+		var userNotFoundErr *service.ErrUserNotFound
+		assert.ErrorAs(t, err, &userNotFoundErr)
+	})
+}
+
+func TestRegisterSSHPublicKey(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	sshPub, err := ssh.NewPublicKey(pub)
+	assert.Nil(t, err)
+	fullText := string(ssh.MarshalAuthorizedKey(sshPub))
+
+	key, err := s.Keys.RegisterSSHPublicKey(alice.UserId, "laptop", fullText)
+	assert.Nil(t, err)
+	assert.Equal(t, ssh.FingerprintSHA256(sshPub), key.Fingerprint)
+
+	keys, err := s.Keys.GetSSHPublicKeys(alice.UserId, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+
+	// 同じ鍵は他のユーザも登録できない
+	_, err = s.Keys.RegisterSSHPublicKey(bob.UserId, "desktop", fullText)
+	var duplicatesErr *service.ErrDuplicatesPubkeyFingerprint
+	assert.ErrorAs(t, err, &duplicatesErr)
+
+	_, err = s.Keys.RegisterSSHPublicKey(bob.UserId, "desktop", "ssh-ed25519 not-a-key")
+	var invalidErr *service.ErrInvalidPubkeyProvided
+	assert.ErrorAs(t, err, &invalidErr)
+}
+
+func TestAccessTokenRevocation(t *testing.T) {
+	s := setupTestDB(t)
+	signUp(t, s, "alice@example.com", "alice")
+
+	login := func() (*service.Session, string) {
+		session, _, err := s.Auth.Login("alice@example.com", "password123", service.SessionClient{})
+		assert.Nil(t, err)
+		accessToken, err := security.GenerateAccessToken(session.UserId, session.SessionId, session.TokenGeneration)
+		assert.Nil(t, err)
+		return session, accessToken.Body
+	}
+	authenticate := func(token string) error {
+		_, err := s.Tokens.AuthenticateAccessToken(token)
+		return err
+	}
+	var revokedErr *service.ErrRevokedAccessTokenProvided
+
+	t.Run("logout revokes only the token of the session", func(t *testing.T) {
+		laptop, laptopToken := login()
+		_, phoneToken := login()
+		assert.Nil(t, authenticate(laptopToken))
+
+		claims, err := s.Tokens.AuthenticateAccessToken(laptopToken)
+		assert.Nil(t, err)
+		assert.Nil(t, s.Auth.Logout(laptop.UserId, laptop.SessionId, claims.TokenId, claims.ExpiresAt))
+
+		assert.ErrorAs(t, authenticate(laptopToken), &revokedErr)
+		assert.Nil(t, authenticate(phoneToken))
+
+		// git over HTTP のパスワードに使っても拒否する
+		_, _, err = s.Repos.AuthenticateGitUser("alice", laptopToken)
+		assert.ErrorAs(t, err, &revokedErr)
+		userId, _, err := s.Repos.AuthenticateGitUser("alice", phoneToken)
+		assert.Nil(t, err)
+		assert.Equal(t, laptop.UserId, userId)
+	})
+
+	t.Run("revoking a session revokes its token", func(t *testing.T) {
+		laptop, laptopToken := login()
+		phone, phoneToken := login()
+
+		assert.Nil(t, authenticate(phoneToken))
+		assert.Nil(t, s.Auth.RevokeUserSession(laptop.UserId, phone.SessionId))
+		// 有効期限を待たずに使えなくなる
+		assert.ErrorAs(t, authenticate(phoneToken), &revokedErr)
+		assert.Nil(t, authenticate(laptopToken))
+
+		_, err := s.Auth.Refresh(phone.RefreshToken.Body, service.SessionClient{})
+		var invalidRefreshErr *service.ErrInvalidRefreshTokenProvided
+		assert.ErrorAs(t, err, &invalidRefreshErr)
+	})
+
+	t.Run("password change revokes all tokens", func(t *testing.T) {
+		laptop, laptopToken := login()
+		_, phoneToken := login()
+
+		generation, err := s.Auth.ChangePassword(laptop.UserId, laptop.SessionId, "password123", "password456")
+		assert.Nil(t, err)
+		assert.ErrorAs(t, authenticate(laptopToken), &revokedErr)
+		assert.ErrorAs(t, authenticate(phoneToken), &revokedErr)
+
+		// 変更したセッションは新しい世代で発行し直せば使える
+		accessToken, err := security.GenerateAccessToken(laptop.UserId, laptop.SessionId, generation)
+		assert.Nil(t, err)
+		assert.Nil(t, authenticate(accessToken.Body))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		var invalidErr *service.ErrInvalidAccessTokenProvided
+		assert.ErrorAs(t, authenticate("not-a-token"), &invalidErr)
+	})
+}
+
+func TestRepositoryCRUD(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+
+	repo, err := s.Repos.CreateRepository(alice.UserId, "", "hello", false)
+	assert.Nil(t, err)
+	assert.True(t, s.Repositories.Exists(repo.ID))
+	secret, err := s.Repos.CreateRepository(alice.UserId, "", "secret", true)
+	assert.Nil(t, err)
+
+	t.Run("name is unique per owner", func(t *testing.T) {
+		_, err := s.Repos.CreateRepository(alice.UserId, "", "hello", true)
+		var registeredErr *service.ErrRegisteredRepositoryName
+		assert.ErrorAs(t, err, &registeredErr)
+
+		_, err = s.Repos.CreateRepository(bob.UserId, "", "hello", false)
+		assert.Nil(t, err)
+	})
+
+	t.Run("private repository is hidden from others", func(t *testing.T) {
+		got, err := s.Repos.GetRepository(&bob.UserId, "alice", "hello")
+		assert.Nil(t, err)
+		assert.Equal(t, repo.ID, got.ID)
+
+		var notFoundErr *service.ErrRepositoryNotFound
+		_, err = s.Repos.GetRepository(&bob.UserId, "alice", "secret")
+		assert.ErrorAs(t, err, &notFoundErr)
+		_, err = s.Repos.GetRepository(nil, "alice", "secret")
+		assert.ErrorAs(t, err, &notFoundErr)
+
+		repos, err := s.Repos.GetRepositories(nil, "alice", 0, 10)
+		assert.Nil(t, err)
+		assert.Len(t, repos, 1)
+		repos, err = s.Repos.GetRepositories(&alice.UserId, "alice", 0, 10)
+		assert.Nil(t, err)
+		assert.Len(t, repos, 2)
+	})
+
+	t.Run("only the owner can update and delete", func(t *testing.T) {
+		newName := "world"
+		_, err := s.Repos.UpdateRepository(bob.UserId, "alice", "hello", &newName, nil)
+		var permissionDeniedErr *service.ErrPermissionDenied
+		assert.ErrorAs(t, err, &permissionDeniedErr)
+
+		updated, err := s.Repos.UpdateRepository(alice.UserId, "alice", "hello", &newName, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "world", updated.Name)
+
+		taken := "secret"
+		_, err = s.Repos.UpdateRepository(alice.UserId, "alice", "world", &taken, nil)
+		var registeredErr *service.ErrRegisteredRepositoryName
+		assert.ErrorAs(t, err, &registeredErr)
+
+		assert.Nil(t, s.Repos.DeleteRepository(alice.UserId, "alice", "secret"))
+		assert.False(t, s.Repositories.Exists(secret.ID))
+		_, err = s.Repos.GetRepository(&alice.UserId, "alice", "secret")
+		var notFoundErr *service.ErrRepositoryNotFound
+		assert.ErrorAs(t, err, &notFoundErr)
+	})
+}
+
+func TestGitAccess(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+	_, err := s.Repos.CreateRepository(alice.UserId, "", "hello", false)
+	assert.Nil(t, err)
+	_, err = s.Repos.CreateRepository(alice.UserId, "", "secret", true)
+	assert.Nil(t, err)
+
+	t.Run("authenticate with password", func(t *testing.T) {
+		userId, scopes, err := s.Repos.AuthenticateGitUser("alice", "password123")
+		assert.Nil(t, err)
+		assert.Equal(t, alice.UserId, userId)
+		assert.Nil(t, scopes)
+		userId, _, err = s.Repos.AuthenticateGitUser("alice@example.com", "password123")
+		assert.Nil(t, err)
+		assert.Equal(t, alice.UserId, userId)
+
+		_, _, err = s.Repos.AuthenticateGitUser("alice", "wrong-password")
+		var passwordMissMatchErr *service.ErrPasswordMissMatch
+		assert.ErrorAs(t, err, &passwordMissMatchErr)
+		_, _, err = s.Repos.AuthenticateGitUser("nobody", "password123")
+		var userNotFoundErr *service.ErrUserNotFound
+		assert.ErrorAs(t, err, &userNotFoundErr)
+	})
+
+	t.Run("clone and push", func(t *testing.T) {
+		_, err := s.Repos.GetRepositoryForGit(nil, "alice", "hello", false)
+		assert.Nil(t, err)
+		var notFoundErr *service.ErrRepositoryNotFound
+		_, err = s.Repos.GetRepositoryForGit(nil, "alice", "secret", false)
+		assert.ErrorAs(t, err, &notFoundErr)
+		_, err = s.Repos.GetRepositoryForGit(&bob.UserId, "alice", "secret", false)
+		assert.ErrorAs(t, err, &notFoundErr)
+
+		var permissionDeniedErr *service.ErrPermissionDenied
+		_, err = s.Repos.GetRepositoryForGit(nil, "alice", "hello", true)
+		assert.ErrorAs(t, err, &permissionDeniedErr)
+		_, err = s.Repos.GetRepositoryForGit(&bob.UserId, "alice", "hello", true)
+		assert.ErrorAs(t, err, &permissionDeniedErr)
+
+		// pushには二要素認証が必要
+		_, err = s.Repos.GetRepositoryForGit(&alice.UserId, "alice", "hello", true)
+		var twoFactorRequiredErr *service.ErrTwoFactorRequired
+		assert.ErrorAs(t, err, &twoFactorRequiredErr)
+		enableTwoFactor(t, s, alice.UserId)
+		_, err = s.Repos.GetRepositoryForGit(&alice.UserId, "alice", "hello", true)
+		assert.Nil(t, err)
+
+		// 二要素認証を有効にするとパスワードでは認証できない
+		_, _, err = s.Repos.AuthenticateGitUser("alice", "password123")
+		var passwordDisabledErr *service.ErrPasswordAuthenticationDisabled
+		assert.ErrorAs(t, err, &passwordDisabledErr)
+	})
+}
+
+func TestInternalAuthorization(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+	_, err := s.Repos.CreateRepository(alice.UserId, "", "hello", false)
+	assert.Nil(t, err)
+
+	t.Run("pubkey owner", func(t *testing.T) {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		assert.Nil(t, err)
+		sshPub, err := ssh.NewPublicKey(pub)
+		assert.Nil(t, err)
+		_, err = s.Keys.RegisterSSHPublicKey(alice.UserId, "laptop", string(ssh.MarshalAuthorizedKey(sshPub)))
+		assert.Nil(t, err)
+
+		user, account, err := s.Keys.GetPubkeyOwner(ssh.FingerprintSHA256(sshPub))
+		assert.Nil(t, err)
+		assert.Equal(t, alice.UserId, user.ID)
+		assert.Equal(t, "alice", account.Handlename.Handlename)
+
+		_, _, err = s.Keys.GetPubkeyOwner("SHA256:unknown")
+		var userNotFoundErr *service.ErrUserNotFound
+		assert.ErrorAs(t, err, &userNotFoundErr)
+	})
+
+	t.Run("repository access", func(t *testing.T) {
+		access, err := s.Repos.GetRepositoryAccess(&bob.UserId, "alice", "hello")
+		assert.Nil(t, err)
+		assert.Equal(t, service.PermissionRead, access.Permission)
+		assert.False(t, access.CanWrite())
+
+		access, err = s.Repos.GetRepositoryAccess(&alice.UserId, "alice", "hello")
+		assert.Nil(t, err)
+		assert.Equal(t, service.PermissionAdmin, access.Permission)
+		assert.True(t, access.TwoFactorRequired)
+		assert.False(t, access.CanWrite())
+
+		enableTwoFactor(t, s, alice.UserId)
+		access, err = s.Repos.GetRepositoryAccess(&alice.UserId, "alice", "hello")
+		assert.Nil(t, err)
+		assert.True(t, access.CanWrite())
+
+		_, err = s.Repos.GetRepositoryAccess(&alice.UserId, "alice", "missing")
+		var notFoundErr *service.ErrRepositoryNotFound
+		assert.ErrorAs(t, err, &notFoundErr)
+	})
+}
+
+func TestRepositoryCollaborators(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+	carol := signUp(t, s, "carol@example.com", "carol")
+	_, err := s.Repos.CreateRepository(alice.UserId, "", "secret", true)
+	assert.Nil(t, err)
+
+	permission := func(userId uint) service.RepositoryPermission {
+		access, err := s.Repos.GetRepositoryAccess(&userId, "alice", "secret")
+		var notFoundErr *service.ErrRepositoryNotFound
+		if errors.As(err, &notFoundErr) {
+			return service.PermissionNone
+		}
+		assert.Nil(t, err)
+		return access.Permission
+	}
+
+	t.Run("access is granted after accepting the invitation", func(t *testing.T) {
+		invitation, err := s.Repos.InviteRepositoryCollaborator(alice.UserId, "alice", "secret", "bob", model.RepositoryRoleRead)
+		assert.Nil(t, err)
+		assert.Equal(t, service.PermissionNone, permission(bob.UserId))
+
+		_, err = s.Repos.InviteRepositoryCollaborator(alice.UserId, "alice", "secret", "bob", model.RepositoryRoleWrite)
+		var registeredInvitationErr *service.ErrRegisteredInvitation
+		assert.ErrorAs(t, err, &registeredInvitationErr)
+
+		// 他人宛ての招待は受けられない
+		var invitationNotFoundErr *service.ErrInvitationNotFound
+		assert.ErrorAs(t, s.Repos.AcceptRepositoryInvitation(carol.UserId, invitation.ID), &invitationNotFoundErr)
+
+		assert.Nil(t, s.Repos.AcceptRepositoryInvitation(bob.UserId, invitation.ID))
+		assert.Equal(t, service.PermissionRead, permission(bob.UserId))
+		assert.ErrorAs(t, s.Repos.AcceptRepositoryInvitation(bob.UserId, invitation.ID), &invitationNotFoundErr)
+
+		_, err = s.Repos.InviteRepositoryCollaborator(alice.UserId, "alice", "secret", "bob", model.RepositoryRoleWrite)
+		var registeredCollaboratorErr *service.ErrRegisteredCollaborator
+		assert.ErrorAs(t, err, &registeredCollaboratorErr)
+	})
+
+	t.Run("only admins manage collaborators", func(t *testing.T) {
+		_, err := s.Repos.InviteRepositoryCollaborator(bob.UserId, "alice", "secret", "carol", model.RepositoryRoleRead)
+		var permissionDeniedErr *service.ErrPermissionDenied
+		assert.ErrorAs(t, err, &permissionDeniedErr)
+
+		assert.Nil(t, s.Repos.UpdateRepositoryCollaborator(alice.UserId, "alice", "secret", "bob", model.RepositoryRoleWrite))
+		assert.Equal(t, service.PermissionWrite, permission(bob.UserId))
+
+		collaborators, err := s.Repos.GetRepositoryCollaborators(alice.UserId, "alice", "secret", 0, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []service.Collaborator{{UserId: bob.UserId, Handlename: "bob", Role: model.RepositoryRoleWrite}}, collaborators)
+	})
+
+	t.Run("decline and leave", func(t *testing.T) {
+		invitation, err := s.Repos.InviteRepositoryCollaborator(alice.UserId, "alice", "secret", "carol", model.RepositoryRoleRead)
+		assert.Nil(t, err)
+		assert.Nil(t, s.Repos.DeclineRepositoryInvitation(carol.UserId, invitation.ID))
+		assert.Equal(t, service.PermissionNone, permission(carol.UserId))
+
+		// コラボレーター本人は自分を外せる
+		assert.Nil(t, s.Repos.RemoveRepositoryCollaborator(bob.UserId, "alice", "secret", "bob"))
+		assert.Equal(t, service.PermissionNone, permission(bob.UserId))
+	})
+}
+
+func TestOrganizationMembers(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+	carol := signUp(t, s, "carol@example.com", "carol")
+
+	_, err := s.Organizations.CreateOrganization(alice.UserId, "acme", "")
+	assert.Nil(t, err)
+	// 組織とユーザのハンドルネームは重ならない
+	_, err = s.Organizations.CreateOrganization(alice.UserId, "bob", "")
+	var registeredHandleNameErr *service.ErrRegisteredHandleName
+	assert.ErrorAs(t, err, &registeredHandleNameErr)
+
+	var permissionDeniedErr *service.ErrPermissionDenied
+	assert.Nil(t, s.Organizations.SetOrganizationMember(alice.UserId, "acme", "bob", model.OrganizationRoleMember))
+	assert.ErrorAs(t, s.Organizations.SetOrganizationMember(bob.UserId, "acme", "carol", model.OrganizationRoleMember), &permissionDeniedErr)
+	_, err = s.Organizations.GetOrganizationMembers(carol.UserId, "acme", 0, 10)
+	assert.ErrorAs(t, err, &permissionDeniedErr)
+
+	members, err := s.Organizations.GetOrganizationMembers(bob.UserId, "acme", 0, 10)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []service.OrganizationMember{
+		{UserId: alice.UserId, Handlename: "alice", Role: model.OrganizationRoleOwner},
+		{UserId: bob.UserId, Handlename: "bob", Role: model.OrganizationRoleMember},
+	}, members)
+
+	organizations, err := s.Organizations.GetUserOrganizations(bob.UserId, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, organizations, 1)
+
+	t.Run("members create repositories in the organization", func(t *testing.T) {
+		repo, err := s.Repos.CreateRepository(bob.UserId, "acme", "tools", true)
+		assert.Nil(t, err)
+		assert.Equal(t, "acme", repo.OwnerAccount.Handlename.Handlename)
+
+		_, err = s.Repos.CreateRepository(carol.UserId, "acme", "other", true)
+		assert.ErrorAs(t, err, &permissionDeniedErr)
+	})
+
+	t.Run("organization keeps an owner", func(t *testing.T) {
+		var lastOwnerErr *service.ErrLastOrganizationOwner
+		assert.ErrorAs(t, s.Organizations.RemoveOrganizationMember(alice.UserId, "acme", "alice"), &lastOwnerErr)
+		assert.ErrorAs(t, s.Organizations.SetOrganizationMember(alice.UserId, "acme", "alice", model.OrganizationRoleMember), &lastOwnerErr)
+
+		assert.Nil(t, s.Organizations.SetOrganizationMember(alice.UserId, "acme", "bob", model.OrganizationRoleOwner))
+		assert.Nil(t, s.Organizations.RemoveOrganizationMember(alice.UserId, "acme", "alice"))
+		assert.ErrorAs(t, s.Organizations.RemoveOrganizationMember(bob.UserId, "acme", "bob"), &lastOwnerErr)
+	})
+}
+
+func TestTeamRepositoryPermissions(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+	_, err := s.Organizations.CreateOrganization(alice.UserId, "acme", "")
+	assert.Nil(t, err)
+	assert.Nil(t, s.Organizations.SetOrganizationMember(alice.UserId, "acme", "bob", model.OrganizationRoleMember))
+	_, err = s.Repos.CreateRepository(alice.UserId, "acme", "tools", true)
+	assert.Nil(t, err)
+
+	permission := func() service.RepositoryPermission {
+		access, err := s.Repos.GetRepositoryAccess(&bob.UserId, "acme", "tools")
+		var notFoundErr *service.ErrRepositoryNotFound
+		if errors.As(err, &notFoundErr) {
+			return service.PermissionNone
+		}
+		assert.Nil(t, err)
+		return access.Permission
+	}
+
+	// メンバーは組織の基本権限で読める
+	assert.Equal(t, service.PermissionRead, permission())
+	assert.Nil(t, s.Organizations.UpdateOrganizationBaseRole(alice.UserId, "acme", 0))
+	assert.Equal(t, service.PermissionNone, permission())
+
+	_, err = s.Organizations.CreateTeam(alice.UserId, "acme", "devs", "", "")
+	assert.Nil(t, err)
+	_, err = s.Organizations.CreateTeam(alice.UserId, "acme", "frontend", "", "devs")
+	assert.Nil(t, err)
+	_, err = s.Organizations.CreateTeam(bob.UserId, "acme", "mine", "", "")
+	var permissionDeniedErr *service.ErrPermissionDenied
+	assert.ErrorAs(t, err, &permissionDeniedErr)
+
+	t.Run("child team inherits the parent's grants", func(t *testing.T) {
+		assert.Nil(t, s.Organizations.AddTeamMember(alice.UserId, "acme", "frontend", "bob"))
+		assert.Nil(t, s.Organizations.SetTeamRepository(alice.UserId, "acme", "devs", "tools", model.RepositoryRoleWrite))
+		assert.Equal(t, service.PermissionWrite, permission())
+
+		repos, err := s.Repos.GetRepositories(&bob.UserId, "acme", 0, 10)
+		assert.Nil(t, err)
+		assert.Len(t, repos, 1)
+	})
+
+	t.Run("parent must not be a descendant", func(t *testing.T) {
+		parent := "frontend"
+		_, err := s.Organizations.UpdateTeam(alice.UserId, "acme", "devs", nil, nil, &parent)
+		var invalidParentErr *service.ErrInvalidParentTeam
+		assert.ErrorAs(t, err, &invalidParentErr)
+	})
+
+	t.Run("leaving the organization removes team access", func(t *testing.T) {
+		assert.Nil(t, s.Organizations.RemoveOrganizationMember(bob.UserId, "acme", "bob"))
+		assert.Equal(t, service.PermissionNone, permission())
+	})
+}
+
+func TestMultipleSessions(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+
+	laptop, _, err := s.Auth.Login("alice@example.com", "password123", service.SessionClient{UserAgent: "laptop", IPAddress: "192.0.2.1"})
+	assert.Nil(t, err)
+	phone, _, err := s.Auth.Login("alice@example.com", "password123", service.SessionClient{UserAgent: "phone", IPAddress: "2001:db8::1"})
+	assert.Nil(t, err)
+
+	sessions, err := s.Auth.GetUserSessions(alice.UserId, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 3)
+	clients := map[uint]string{}
+	for _, session := range sessions {
+		clients[session.ID] = session.UserAgent + " " + session.IPAddress
+	}
+	assert.Equal(t, "laptop 192.0.2.1", clients[laptop.SessionId])
+	assert.Equal(t, "phone 2001:db8::1", clients[phone.SessionId])
+
+	// 他人のセッションは終了できない
+	var sessionNotFoundErr *service.ErrSessionNotFound
+	assert.ErrorAs(t, s.Auth.RevokeUserSession(bob.UserId, phone.SessionId), &sessionNotFoundErr)
+
+	assert.Nil(t, s.Auth.RevokeUserSession(alice.UserId, phone.SessionId))
+	sessions, err = s.Auth.GetUserSessions(alice.UserId, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+
+	// 残りのセッションはそのままリフレッシュできる
+	refreshed, err := s.Auth.Refresh(laptop.RefreshToken.Body, service.SessionClient{UserAgent: "laptop"})
+	assert.Nil(t, err)
+	assert.Equal(t, laptop.SessionId, refreshed.SessionId)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	phone, _, err := s.Auth.Login("alice@example.com", "password123", service.SessionClient{})
+	assert.Nil(t, err)
+
+	rotated, err := s.Auth.Refresh(alice.RefreshToken.Body, service.SessionClient{})
+	assert.Nil(t, err)
+	assert.Equal(t, alice.SessionId, rotated.SessionId)
+	assert.NotEqual(t, alice.RefreshToken.Body, rotated.RefreshToken.Body)
+
+	// ローテーション済みのトークンが使われたら、盗まれたものとしてセッションごと失効させる
+	_, err = s.Auth.Refresh(alice.RefreshToken.Body, service.SessionClient{})
+	var reusedErr *service.ErrRefreshTokenReused
+	assert.ErrorAs(t, err, &reusedErr)
+	assert.Equal(t, alice.SessionId, reusedErr.SessionId)
+
+	_, err = s.Auth.Refresh(rotated.RefreshToken.Body, service.SessionClient{})
+	var invalidErr *service.ErrInvalidRefreshTokenProvided
+	assert.ErrorAs(t, err, &invalidErr)
+
+	// 他のセッションには影響しない
+	_, err = s.Auth.Refresh(phone.RefreshToken.Body, service.SessionClient{})
+	assert.Nil(t, err)
+}
+
+func TestAccountDeletion(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+	carol := signUp(t, s, "carol@example.com", "carol")
+
+	// 猶予期間が過ぎたことにする
+	makeDue := func(userId uint) {
+		assert.Nil(t, s.DB.Exec("update users set deletion_scheduled_at = ? where id = ?", time.Now().Add(-time.Minute), userId).Error)
+	}
+
+	t.Run("schedule and cancel", func(t *testing.T) {
+		phone, _, err := s.Auth.Login("carol@example.com", "password123", service.SessionClient{})
+		assert.Nil(t, err)
+
+		_, err = s.Accounts.ScheduleAccountDeletion(carol.UserId, carol.SessionId, "wrong-password", "")
+		var passwordMissMatchErr *service.ErrPasswordMissMatch
+		assert.ErrorAs(t, err, &passwordMissMatchErr)
+
+		deleteAt, err := s.Accounts.ScheduleAccountDeletion(carol.UserId, carol.SessionId, "password123", "")
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now().Add(14*24*time.Hour), deleteAt, time.Minute)
+		scheduled, err := s.Accounts.GetAccountDeletion(carol.UserId)
+		assert.Nil(t, err)
+		assert.WithinDuration(t, deleteAt, *scheduled, time.Second)
+
+		_, err = s.Accounts.ScheduleAccountDeletion(carol.UserId, carol.SessionId, "password123", "")
+		var alreadyScheduledErr *service.ErrAccountDeletionAlreadyScheduled
+		assert.ErrorAs(t, err, &alreadyScheduledErr)
+
+		// 申し込んだセッション以外は終了する
+		_, err = s.Auth.Refresh(phone.RefreshToken.Body, service.SessionClient{})
+		var invalidRefreshErr *service.ErrInvalidRefreshTokenProvided
+		assert.ErrorAs(t, err, &invalidRefreshErr)
+		_, err = s.Auth.Refresh(carol.RefreshToken.Body, service.SessionClient{})
+		assert.Nil(t, err)
+
+		assert.Nil(t, s.Accounts.CancelAccountDeletion(carol.UserId))
+		scheduled, err = s.Accounts.GetAccountDeletion(carol.UserId)
+		assert.Nil(t, err)
+		assert.Nil(t, scheduled)
+	})
+
+	t.Run("delete due accounts", func(t *testing.T) {
+		repo, err := s.Repos.CreateRepository(carol.UserId, "", "hello", false)
+		assert.Nil(t, err)
+		_, err = s.Accounts.ScheduleAccountDeletion(carol.UserId, carol.SessionId, "password123", "")
+		assert.Nil(t, err)
+
+		// 期限前は退会させない
+		deleted, err := s.Accounts.DeleteScheduledAccounts()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, deleted)
+
+		makeDue(carol.UserId)
+		deleted, err = s.Accounts.DeleteScheduledAccounts()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, deleted)
+		assert.False(t, s.Repositories.Exists(repo.ID))
+
+		_, _, err = s.Auth.Login("carol@example.com", "password123", service.SessionClient{})
+		var userNotFoundErr *service.ErrUserNotFound
+		assert.ErrorAs(t, err, &userNotFoundErr)
+		// メールアドレスとハンドルネームは解放する
+		signUp(t, s, "carol@example.com", "carol")
+	})
+
+	t.Run("last organization owner is postponed", func(t *testing.T) {
+		_, err := s.Organizations.CreateOrganization(alice.UserId, "acme", "")
+		assert.Nil(t, err)
+
+		// 唯一のオーナーは申し込めない
+		_, err = s.Accounts.ScheduleAccountDeletion(alice.UserId, alice.SessionId, "password123", "")
+		var lastOwnerErr *service.ErrLastOrganizationOwner
+		assert.ErrorAs(t, err, &lastOwnerErr)
+
+		assert.Nil(t, s.Organizations.SetOrganizationMember(alice.UserId, "acme", "bob", model.OrganizationRoleOwner))
+		_, err = s.Accounts.ScheduleAccountDeletion(alice.UserId, alice.SessionId, "password123", "")
+		assert.Nil(t, err)
+		// 猶予期間中に他のオーナーが抜けた
+		assert.Nil(t, s.Organizations.RemoveOrganizationMember(bob.UserId, "acme", "bob"))
+
+		dave := signUp(t, s, "dave@example.com", "dave")
+		_, err = s.Accounts.ScheduleAccountDeletion(dave.UserId, dave.SessionId, "password123", "")
+		assert.Nil(t, err)
+		makeDue(alice.UserId)
+		makeDue(dave.UserId)
+
+		// 退会させられないユーザは1日延期して、他のユーザの退会は続ける
+		deleted, err := s.Accounts.DeleteScheduledAccounts()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, deleted)
+
+		scheduled, err := s.Accounts.GetAccountDeletion(alice.UserId)
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), *scheduled, time.Minute)
+		members, err := s.Organizations.GetOrganizationMembers(alice.UserId, "acme", 0, 10)
+		assert.Nil(t, err)
+		assert.Len(t, members, 1)
+		_, err = s.Accounts.GetAccountDeletion(dave.UserId)
+		var userNotFoundErr *service.ErrUserNotFound
+		assert.ErrorAs(t, err, &userNotFoundErr)
+	})
+}
+
+func TestRenameHandlename(t *testing.T) {
+	s := setupTestDB(t)
+	alice := signUp(t, s, "alice@example.com", "alice")
+	bob := signUp(t, s, "bob@example.com", "bob")
+	_, err := s.Repos.CreateRepository(alice.UserId, "", "hello", false)
+	assert.Nil(t, err)
+	var registeredHandleNameErr *service.ErrRegisteredHandleName
+
+	_, err = s.Accounts.RenameHandlename(alice.UserId, "bob")
+	assert.ErrorAs(t, err, &registeredHandleNameErr)
+
+	account, err := s.Accounts.RenameHandlename(alice.UserId, "alice2")
+	assert.Nil(t, err)
+	assert.Equal(t, "alice2", account.Handlename.Handlename)
+
+	t.Run("old handlename redirects", func(t *testing.T) {
+		profile, err := s.Accounts.GetProfile(nil, "alice")
+		assert.Nil(t, err)
+		assert.Equal(t, account.ID, profile.ID)
+		repo, err := s.Repos.GetRepository(nil, "alice", "hello")
+		assert.Nil(t, err)
+		assert.Equal(t, "alice2", repo.OwnerAccount.Handlename.Handlename)
+		userId, _, err := s.Repos.AuthenticateGitUser("alice", "password123")
+		assert.Nil(t, err)
+		assert.Equal(t, alice.UserId, userId)
+
+		// 転送している間は他人が使えない
+		_, err = s.Accounts.RenameHandlename(bob.UserId, "alice")
+		assert.ErrorAs(t, err, &registeredHandleNameErr)
+		_, err = s.Auth.SignUp("carol@example.com", "password123", "alice", "en", service.SessionClient{})
+		assert.ErrorAs(t, err, &registeredHandleNameErr)
+	})
+
+	t.Run("owner reclaims the old handlename", func(t *testing.T) {
+		account, err := s.Accounts.RenameHandlename(alice.UserId, "alice")
+		assert.Nil(t, err)
+		assert.Equal(t, "alice", account.Handlename.Handlename)
+
+		profile, err := s.Accounts.GetProfile(nil, "alice2")
+		assert.Nil(t, err)
+		assert.Equal(t, account.ID, profile.ID)
+	})
+
+	t.Run("expired handlename is released", func(t *testing.T) {
+		assert.Nil(t, s.DB.Exec("update handlenames set redirect_expires_at = ? where handlename = ?", time.Now().Add(-time.Minute), "alice2").Error)
+
+		var accountNotFoundErr *service.ErrAccountNotFound
+		_, err := s.Accounts.GetProfile(nil, "alice2")
+		assert.ErrorAs(t, err, &accountNotFoundErr)
+
+		account, err := s.Accounts.RenameHandlename(bob.UserId, "alice2")
+		assert.Nil(t, err)
+		assert.Equal(t, "alice2", account.Handlename.Handlename)
+	})
+}
@@ -1,19 +1,24 @@
 package service
 
 import (
-	"gityard-api/database"
 	"gityard-api/model"
 	"gityard-api/security"
-	"gityard-api/service/repository"
 	"golang.org/x/crypto/ssh"
-	"gorm.io/gorm"
 	"log/slog"
 	"strings"
 )
 
-func RegisterSSHPublicKey(userId uint, keyName string, pubkeyFullText string) (*model.UserPublicKey, error) {
-	db := database.DB
+// KeyService はSSH公開鍵を扱います。
+type KeyService struct {
+	store KeyServiceStore
+}
+
+// NewKeyService は KeyService を作成します。
+func NewKeyService(store KeyServiceStore) *KeyService {
+	return &KeyService{store: store}
+}
 
+func (s *KeyService) RegisterSSHPublicKey(userId uint, keyName string, pubkeyFullText string) (*model.UserPublicKey, error) {
 	if strings.Contains(pubkeyFullText, "BEGIN") {
 		convertedPk, ok := security.ConvertPKCSToOpenSSH(pubkeyFullText)
 		if !ok {
@@ -38,8 +43,8 @@ func RegisterSSHPublicKey(userId uint, keyName string, pubkeyFullText string) (*
 	}
 
 	var pubkey *model.UserPublicKey
-	err = db.Transaction(func(tx *gorm.DB) error {
-		pubkeyInDB, err := repository.GetPubKeyByFingerprint(tx, fingerprint)
+	err = s.store.Transaction(func(tx KeyServiceStore) error {
+		pubkeyInDB, err := tx.GetPubKeyByFingerprint(fingerprint)
 		if err != nil {
 			return err
 		}
@@ -48,7 +53,7 @@ func RegisterSSHPublicKey(userId uint, keyName string, pubkeyFullText string) (*
 			return &ErrDuplicatesPubkeyFingerprint{}
 		}
 
-		pubkey, err = repository.CreatePublicKey(tx, userId, keyName, pubkeyFullText, alg, body, comment, fingerprint)
+		pubkey, err = tx.CreatePublicKey(userId, keyName, pubkeyFullText, alg, body, comment, fingerprint)
 		if err != nil {
 			return err
 		}
@@ -62,13 +67,11 @@ func RegisterSSHPublicKey(userId uint, keyName string, pubkeyFullText string) (*
 	return pubkey, nil
 }
 
-func GetSSHPublicKeys(userId uint, offset, limit int) ([]model.UserPublicKey, error) {
-	db := database.DB
-
+func (s *KeyService) GetSSHPublicKeys(userId uint, offset, limit int) ([]model.UserPublicKey, error) {
 	var pubkeys []model.UserPublicKey
-	err := db.Transaction(func(tx *gorm.DB) error {
+	err := s.store.Transaction(func(tx KeyServiceStore) error {
 		// 存在チェック
-		user, err := repository.GetUserById(tx, userId)
+		user, err := tx.GetUserById(userId)
 		if err != nil {
 			return err
 		}
@@ -76,7 +79,7 @@ func GetSSHPublicKeys(userId uint, offset, limit int) ([]model.UserPublicKey, er
 			return &ErrUserNotFound{UserId: userId}
 		}
 
-		pks, err := repository.GetPubkeysByUserId(tx, userId, offset, limit)
+		pks, err := tx.GetPubkeysByUserId(userId, offset, limit)
 		if err != nil {
 			return err
 		}
@@ -91,11 +94,9 @@ func GetSSHPublicKeys(userId uint, offset, limit int) ([]model.UserPublicKey, er
 	return pubkeys, nil
 }
 
-func DeleteSSHPublicKeyByFingerprint(userId uint, fingerprint string) error {
-	db := database.DB
-
-	return db.Transaction(func(tx *gorm.DB) error {
-		user, err := repository.GetUserById(tx, userId)
+func (s *KeyService) DeleteSSHPublicKeyByFingerprint(userId uint, fingerprint string) error {
+	return s.store.Transaction(func(tx KeyServiceStore) error {
+		user, err := tx.GetUserById(userId)
 		if err != nil {
 			return err
 		}
@@ -103,6 +104,6 @@ func DeleteSSHPublicKeyByFingerprint(userId uint, fingerprint string) error {
 			return &ErrUserNotFound{UserId: userId}
 		}
 
-		return repository.DeletePublicKeyByFingerprint(tx, userId, fingerprint)
+		return tx.DeletePublicKeyByFingerprint(userId, fingerprint)
 	})
 }
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"gityard-api/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// サービスはデータの読み書きをこのファイルのストアのインターフェースを通して行います。
+// 本番では service/repository のGORMの実装を使い、テストではメモリ上の実装に差し替えられます。
+// 各サービスは使うストアだけをまとめた XxxServiceStore を受け取るので、テストの実装もその分だけで済みます。
+//
+// 実装は次の約束を守ってください。
+//   - 1件を取得するメソッドは、見つからなければエラーではなく nil を返す
+//   - 一意制約に反する作成や更新は ErrDuplicatedKey を返す
+
+// ErrDuplicatedKey は一意制約に反したことを表します。GORMの実装ではデータベースの一意制約違反がこのエラーになります。
+var ErrDuplicatedKey = gorm.ErrDuplicatedKey
+
+// UserStore はユーザを保存します。
+type UserStore interface {
+	CreateUser(email, locale string) (*model.User, error)
+	GetUserById(userId uint) (*model.User, error)
+	GetUserByEmail(email string) (*model.User, error)
+	UpdateUserDeletionScheduledAt(userId uint, scheduledAt *time.Time) error
+	GetUsersDueForDeletion(now time.Time, limit int) ([]model.User, error)
+	SoftDeleteUser(userId uint) error
+	UpdateUserEmailVerifiedAt(userId uint, verifiedAt time.Time) error
+}
+
+// CredentialStore はユーザのパスワードを保存します。
+type CredentialStore interface {
+	CreateUserCredential(userId uint, plainPassword string) (*model.UserCredential, error)
+	GetUserCredentialById(userId uint) (*model.UserCredential, error)
+	UpdateUserCredentialPassword(userId uint, plainPassword string) error
+	DeleteUserCredential(userId uint) error
+}
+
+// VerificationTokenStore はメールアドレスの確認やパスワードリセットのトークンを保存します。
+type VerificationTokenStore interface {
+	CreateUserVerificationToken(userId uint, kind model.VerificationTokenKind, token, email string, expiresAt time.Time) (*model.UserVerificationToken, error)
+	GetUserVerificationTokenByToken(kind model.VerificationTokenKind, token string) (*model.UserVerificationToken, error)
+	DeleteUserVerificationTokensByUserId(userId uint, kind model.VerificationTokenKind) error
+}
+
+// SessionStore はログインのセッションと、アクセストークンの失効を保存します。
+type SessionStore interface {
+	CreateUserRefreshToken(userId uint, userAgent, ipAddress string) (*model.UserRefreshToken, *model.RefreshToken, error)
+	UpdateUserRefreshToken(current *model.UserRefreshToken, userAgent, ipAddress string) (*model.RefreshToken, error)
+	GetUserRefreshTokenById(sessionId uint) (*model.UserRefreshToken, error)
+	GetUserRefreshTokenByRefreshToken(refreshToken string) (*model.UserRefreshToken, error)
+	GetRotatedUserRefreshToken(refreshToken string) (*model.UserRotatedRefreshToken, error)
+	GetUserRefreshTokensByUserId(userId uint, offset, limit int) ([]model.UserRefreshToken, error)
+	DeleteUserRefreshToken(sessionId uint) error
+	DeleteUserRefreshTokensByUserId(userId, exceptSessionId uint) error
+	IncrementUserTokenGeneration(userId uint) (uint, error)
+	CreateRevokedAccessToken(tokenId string, userId uint, expiresAt time.Time) error
+	GetRevokedAccessToken(tokenId string) (*model.RevokedAccessToken, error)
+	DeleteExpiredRevokedAccessTokens(now time.Time) error
+}
+
+// AccessTokenStore はパーソナルアクセストークンを保存します。
+type AccessTokenStore interface {
+	CreateUserAccessToken(userId uint, name, token, scopes string, expiresAt *time.Time) (*model.UserAccessToken, error)
+	GetUserAccessTokenById(tokenId uint) (*model.UserAccessToken, error)
+	GetUserAccessTokenByToken(token string) (*model.UserAccessToken, error)
+	GetUserAccessTokensByUserId(userId uint, offset, limit int) ([]model.UserAccessToken, error)
+	UpdateUserAccessTokenLastUsedAt(tokenId uint, lastUsedAt time.Time) error
+	DeleteUserAccessToken(tokenId uint) error
+	DeleteUserAccessTokensByUserId(userId uint) error
+}
+
+// TwoFactorStore はTOTPとリカバリーコード、二要素認証のチャレンジを保存します。
+type TwoFactorStore interface {
+	GetUserTwoFactorByUserId(userId uint) (*model.UserTwoFactor, error)
+	CreateUserTwoFactor(userId uint, secret string) (*model.UserTwoFactor, error)
+	EnableUserTwoFactor(userId uint, step int64, enabledAt time.Time) error
+	UpdateUserTwoFactorLastUsedStep(userId uint, step int64) (bool, error)
+	DeleteUserTwoFactor(userId uint) error
+	CreateUserRecoveryCodes(userId uint, codes []string) error
+	CountUnusedUserRecoveryCodes(userId uint) (int64, error)
+	UseUserRecoveryCode(userId uint, code string, usedAt time.Time) (bool, error)
+	DeleteUserRecoveryCodesByUserId(userId uint) error
+	CreateUserTwoFactorChallenge(userId uint, token string, expiresAt time.Time) (*model.UserTwoFactorChallenge, error)
+	GetUserTwoFactorChallengeByToken(token string) (*model.UserTwoFactorChallenge, error)
+	UpdateUserTwoFactorChallengeFailedAttempts(challengeId uint, failedAttempts int) error
+	DeleteUserTwoFactorChallenge(challengeId uint) error
+	DeleteUserTwoFactorChallengesByUserId(userId uint) error
+	UpdateUserTwoFactorChallengeWebAuthnSessionData(challengeId uint, sessionData string) error
+}
+
+// WebAuthnStore はWebAuthnのクレデンシャルとセレモニーを保存します。
+type WebAuthnStore interface {
+	CreateUserWebAuthnCredential(credential *model.UserWebAuthnCredential) error
+	GetUserWebAuthnCredentialById(credentialId uint) (*model.UserWebAuthnCredential, error)
+	GetUserWebAuthnCredentialsByUserId(userId uint) ([]model.UserWebAuthnCredential, error)
+	CountUserWebAuthnCredentials(userId uint) (int64, error)
+	UpdateUserWebAuthnCredentialUsage(credentialId uint, signCount uint32, lastUsedAt time.Time) error
+	UpdateUserWebAuthnCredentialCloneWarning(credentialId uint) error
+	DeleteUserWebAuthnCredential(credentialId uint) error
+	DeleteUserWebAuthnCredentialsByUserId(userId uint) error
+	CreateWebAuthnCeremony(userId *uint, kind model.WebAuthnCeremonyKind, token, sessionData string, expiresAt time.Time) (*model.WebAuthnCeremony, error)
+	GetWebAuthnCeremonyByToken(token string) (*model.WebAuthnCeremony, error)
+	DeleteWebAuthnCeremony(ceremonyId uint) error
+	DeleteWebAuthnCeremoniesByUserId(userId uint) error
+}
+
+// KeyStore はユーザのSSH公開鍵を保存します。
+type KeyStore interface {
+	CreatePublicKey(userId uint, name, fullKeyText, algorithm, keyBody, comment, fingerprint string) (*model.UserPublicKey, error)
+	GetPubKeyByFingerprint(fingerprint string) (*model.UserPublicKey, error)
+	GetPubkeysByUserId(userId uint, offset, limit int) ([]model.UserPublicKey, error)
+	DeletePublicKeyByFingerprint(userId uint, fingerprint string) error
+	DeletePublicKeysByUserId(userId uint) error
+}
+
+// AccountStore はハンドルネームとアカウント、プロフィールを保存します。
+type AccountStore interface {
+	CreateHandleName(name string) (*model.Handlename, error)
+	GetHandleNameById(handlenameId uint) (*model.Handlename, error)
+	GetHandleNameByName(name string) (*model.Handlename, error)
+	UpdateHandleNameRedirect(handlenameId uint, redirectAccountId *uint, expiresAt *time.Time) error
+	DeleteHandleNameRedirectsByAccountId(accountId uint) error
+	CreateAccount(userId *uint, handlenameId uint, kind model.AccountKind) (*model.Account, error)
+	GetAccountById(accountId uint) (*model.Account, error)
+	CreateAccountProfile(accountId uint, displayName, iconpath string, private bool) (*model.AccountProfile, error)
+	GetAccountProfileById(accountId uint) (*model.AccountProfile, error)
+	UpdateAccountProfile(accountId uint, displayname string, private bool) error
+	UpdateAccountProfileIconpath(accountId uint, iconpath string) error
+	GetAccountByHandlename(name string) (*model.Account, error)
+	GetAccountByRedirectedHandlename(name string, now time.Time) (*model.Account, error)
+	UpdateAccountHandlenameId(accountId, handlenameId uint) error
+	GetPersonalAccountByUserId(userId uint) (*model.Account, error)
+	GetPersonalAccountsByUserIds(userIds []uint) (map[uint]model.Account, error)
+	DeleteHandleName(handlenameId uint) error
+	SoftDeleteAccount(accountId uint) error
+}
+
+// OrganizationStore は組織とメンバー、チームを保存します。
+type OrganizationStore interface {
+	CreateOrganizationMember(organizationAccountId, userId uint, role model.OrganizationRole) (*model.OrganizationMember, error)
+	GetOrganizationMember(organizationAccountId, userId uint) (*model.OrganizationMember, error)
+	GetOrganizationMembers(organizationAccountId uint, offset, limit int) ([]model.OrganizationMember, error)
+	GetOrganizationMembersByUserId(userId uint) ([]model.OrganizationMember, error)
+	CountOrganizationMembersByRole(organizationAccountId uint, role model.OrganizationRole) (int64, error)
+	GetOrganizationsByUserId(userId uint, offset, limit int) ([]model.Account, error)
+	UpdateOrganizationMemberRole(organizationAccountId, userId uint, role model.OrganizationRole) error
+	DeleteOrganizationMember(organizationAccountId, userId uint) error
+	CreateOrganization(accountId uint, baseRole model.RepositoryRole) (*model.Organization, error)
+	GetOrganizationByAccountId(accountId uint) (*model.Organization, error)
+	UpdateOrganizationBaseRole(accountId uint, baseRole model.RepositoryRole) error
+	CreateTeam(organizationAccountId uint, name, description string, parentTeamId *uint) (*model.Team, error)
+	GetTeamById(teamId uint) (*model.Team, error)
+	GetTeamByName(organizationAccountId uint, name string) (*model.Team, error)
+	GetTeamsByOrganizationAccountId(organizationAccountId uint, offset, limit int) ([]model.Team, error)
+	UpdateTeam(teamId uint, name, description string, parentTeamId *uint) error
+	ReparentChildTeams(teamId uint, parentTeamId *uint) error
+	DeleteTeam(teamId uint) error
+	CreateTeamMember(teamId, userId uint) (*model.TeamMember, error)
+	GetTeamMember(teamId, userId uint) (*model.TeamMember, error)
+	GetTeamMembers(teamId uint, offset, limit int) ([]model.TeamMember, error)
+	GetTeamIdsByUserId(organizationAccountId, userId uint) ([]uint, error)
+	DeleteTeamMember(teamId, userId uint) error
+	DeleteTeamMembersByUserId(organizationAccountId, userId uint) error
+}
+
+// RepoStore はリポジトリとコラボレーター、チームに与えたリポジトリを保存します。
+type RepoStore interface {
+	CreateRepository(ownerAccountId uint, name string, private bool) (*model.Repository, error)
+	GetRepositoryById(repoId uint) (*model.Repository, error)
+	GetRepositoryByOwnerAndName(ownerAccountId uint, name string) (*model.Repository, error)
+	GetRepositoriesByOwnerAccountId(ownerAccountId uint, includePrivate bool, viewerUserId *uint, viewerTeamIds []uint, offset, limit int) ([]model.Repository, error)
+	GetRepositoryIdsByOwnerAccountId(ownerAccountId uint) ([]uint, error)
+	UpdateRepository(repoId uint, name string, private bool) error
+	DeleteRepository(repoId uint) error
+	CreateRepositoryCollaborator(repoId, userId uint, role model.RepositoryRole) (*model.RepositoryCollaborator, error)
+	GetRepositoryCollaborator(repoId, userId uint) (*model.RepositoryCollaborator, error)
+	GetRepositoryCollaborators(repoId uint, offset, limit int) ([]model.RepositoryCollaborator, error)
+	UpdateRepositoryCollaboratorRole(repoId, userId uint, role model.RepositoryRole) error
+	DeleteRepositoryCollaborator(repoId, userId uint) error
+	DeleteRepositoryCollaboratorsByUserId(userId uint) error
+	CreateRepositoryInvitation(repoId, inviteeUserId, inviterUserId uint, role model.RepositoryRole) (*model.RepositoryInvitation, error)
+	GetRepositoryInvitationById(invitationId uint) (*model.RepositoryInvitation, error)
+	GetPendingRepositoryInvitation(repoId, inviteeUserId uint) (*model.RepositoryInvitation, error)
+	GetPendingRepositoryInvitationsByRepositoryId(repoId uint, offset, limit int) ([]model.RepositoryInvitation, error)
+	GetPendingRepositoryInvitationsByInviteeUserId(inviteeUserId uint, offset, limit int) ([]model.RepositoryInvitation, error)
+	UpdateRepositoryInvitationStatus(invitationId uint, status model.InvitationStatus) error
+	DeleteRepositoryInvitation(invitationId uint) error
+	DeleteRepositoryInvitationsByUserId(userId uint) error
+	CreateTeamRepository(teamId, repoId uint, role model.RepositoryRole) (*model.TeamRepository, error)
+	GetTeamRepository(teamId, repoId uint) (*model.TeamRepository, error)
+	GetTeamRepositories(teamId uint, offset, limit int) ([]model.TeamRepository, error)
+	GetMaxTeamRepositoryRole(repoId uint, teamIds []uint) (model.RepositoryRole, error)
+	UpdateTeamRepositoryRole(teamId, repoId uint, role model.RepositoryRole) error
+	DeleteTeamRepository(teamId, repoId uint) error
+}
+
+// MailStore は送信待ちのメールを保存します。
+type MailStore interface {
+	CreateMailOutbox(to, subject, textBody, htmlBody string, nextAttemptAt time.Time) (*model.MailOutbox, error)
+	GetDueMailOutboxes(now time.Time, limit int) ([]model.MailOutbox, error)
+	ClaimMailOutbox(outboxId uint, attempts int, nextAttemptAt time.Time) (bool, error)
+	UpdateMailOutboxError(outboxId uint, lastError string, failedAt *time.Time) error
+	DeleteMailOutbox(outboxId uint) error
+}
+
+// Transactor は読み書きをまとめて行うストアです。S はトランザクションの中で使うストアで、
+// 各サービスのストアは自分自身を S にするので、トランザクションの中でも同じメソッドだけを使えます。
+type Transactor[S any] interface {
+	// Transaction は fn の中の読み書きをまとめて行います。fn がエラーを返せば変更はすべて取り消します。
+	// fn の中では引数の tx を使ってください。
+	Transaction(fn func(tx S) error) error
+}
+
+// Store はすべてのストアです。service/repository の実装はこれを満たし、各サービスのストアを返します。
+type Store interface {
+	UserStore
+	CredentialStore
+	VerificationTokenStore
+	SessionStore
+	AccessTokenStore
+	TwoFactorStore
+	WebAuthnStore
+	KeyStore
+	AccountStore
+	OrganizationStore
+	RepoStore
+	MailStore
+}
+
+// AuthServiceStore は AuthService が使うストアです。
+type AuthServiceStore interface {
+	UserStore
+	CredentialStore
+	VerificationTokenStore
+	SessionStore
+	TwoFactorStore
+	WebAuthnStore
+	AccountStore
+	MailStore
+	Transactor[AuthServiceStore]
+}
+
+// TwoFactorServiceStore は TwoFactorService が使うストアです。
+type TwoFactorServiceStore interface {
+	TwoFactorStore
+	WebAuthnStore
+	AccountStore
+	Transactor[TwoFactorServiceStore]
+}
+
+// TokenServiceStore は TokenService が使うストアです。
+type TokenServiceStore interface {
+	UserStore
+	SessionStore
+	AccessTokenStore
+	Transactor[TokenServiceStore]
+}
+
+// KeyServiceStore は KeyService が使うストアです。
+type KeyServiceStore interface {
+	KeyStore
+	GetUserById(userId uint) (*model.User, error)
+	GetPersonalAccountByUserId(userId uint) (*model.Account, error)
+	Transactor[KeyServiceStore]
+}
+
+// AccountServiceStore は AccountService が使うストアです。退会ではユーザのデータをすべて消すので、すべてのストアを使います。
+type AccountServiceStore interface {
+	Store
+	Transactor[AccountServiceStore]
+}
+
+// OrganizationServiceStore は OrganizationService が使うストアです。
+type OrganizationServiceStore interface {
+	UserStore
+	AccountStore
+	OrganizationStore
+	RepoStore
+	Transactor[OrganizationServiceStore]
+}
+
+// RepoServiceStore は RepoService が使うストアです。git over HTTPの認証にパスワードやトークンも使います。
+type RepoServiceStore interface {
+	UserStore
+	CredentialStore
+	SessionStore
+	AccessTokenStore
+	TwoFactorStore
+	WebAuthnStore
+	AccountStore
+	OrganizationStore
+	RepoStore
+	MailStore
+	Transactor[RepoServiceStore]
+}
+
+// 複数のサービスで使う関数のストアです。
+
+type userSessionStore interface {
+	UserStore
+	SessionStore
+}
+
+type userAccountStore interface {
+	UserStore
+	AccountStore
+}
+
+type twoFactorMethodStore interface {
+	TwoFactorStore
+	WebAuthnStore
+}
+
+type webAuthnUserStore interface {
+	AccountStore
+	WebAuthnStore
+}
+
+type permissionStore interface {
+	AccountStore
+	OrganizationStore
+	RepoStore
+}
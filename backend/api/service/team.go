@@ -0,0 +1,462 @@
+package service
+
+import (
+	"errors"
+	"gityard-api/model"
+)
+
+// TeamMember はチームのメンバーとハンドルネームの組です。
+type TeamMember struct {
+	UserId     uint
+	Handlename string
+}
+
+// getUserTeamIds はユーザが所属する組織内のチームのIDを、親チームまで辿って返します。
+// 子チームのメンバーは親チームに与えられたアクセス権も持ちます。
+func getUserTeamIds(tx OrganizationStore, organization *model.Account, userId uint) ([]uint, error) {
+	if organization.Kind != int(model.OrganizationAccount) {
+		return nil, nil
+	}
+
+	teamIds, err := tx.GetTeamIdsByUserId(organization.ID, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := make(map[uint]bool, len(teamIds))
+	for _, teamId := range teamIds {
+		for !visited[teamId] {
+			visited[teamId] = true
+			team, err := tx.GetTeamById(teamId)
+			if err != nil {
+				return nil, err
+			}
+			if team == nil || team.ParentTeamID == nil {
+				break
+			}
+			teamId = *team.ParentTeamID
+		}
+	}
+
+	allTeamIds := make([]uint, 0, len(visited))
+	for teamId := range visited {
+		allTeamIds = append(allTeamIds, teamId)
+	}
+	return allTeamIds, nil
+}
+
+// getTeamPermission はユーザがチームを通じてリポジトリに持つ権限を返します。
+func getTeamPermission(tx permissionStore, userId uint, owner *model.Account, repo *model.Repository) (RepositoryPermission, error) {
+	teamIds, err := getUserTeamIds(tx, owner, userId)
+	if err != nil {
+		return PermissionNone, err
+	}
+
+	role, err := tx.GetMaxTeamRepositoryRole(repo.ID, teamIds)
+	if err != nil {
+		return PermissionNone, err
+	}
+	return RepositoryPermission(role), nil
+}
+
+// getTeamOrganization はチームの操作対象の組織を取得します。
+// チームを見るには組織のメンバー、変更するにはオーナーである必要があります。
+func getTeamOrganization(tx OrganizationServiceStore, userId uint, handlename string, ownerRequired bool) (*model.Account, error) {
+	organization, err := getOrganization(tx, handlename)
+	if err != nil {
+		return nil, err
+	}
+	role, err := getOrganizationRole(tx, &userId, organization)
+	if err != nil {
+		return nil, err
+	}
+	if role == 0 || (ownerRequired && role != model.OrganizationRoleOwner) {
+		return nil, &ErrPermissionDenied{UserId: userId}
+	}
+	return organization, nil
+}
+
+func getTeam(tx OrganizationStore, organization *model.Account, name string) (*model.Team, error) {
+	team, err := tx.GetTeamByName(organization.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, &ErrTeamNotFound{OrganizationAccountId: organization.ID, Name: name}
+	}
+	return team, nil
+}
+
+// getParentTeam は team の親チームにする parentName のチームを取得します。
+// 自分自身や子孫のチームを親にすると循環するので許可しません。team が nil の場合は新規作成です。
+func getParentTeam(tx OrganizationStore, organization *model.Account, team *model.Team, parentName string) (*model.Team, error) {
+	parent, err := getTeam(tx, organization, parentName)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return parent, nil
+	}
+
+	ancestor := parent
+	for ancestor != nil {
+		if ancestor.ID == team.ID {
+			return nil, &ErrInvalidParentTeam{TeamId: team.ID, ParentTeamId: parent.ID}
+		}
+		if ancestor.ParentTeamID == nil {
+			break
+		}
+		ancestor, err = tx.GetTeamById(*ancestor.ParentTeamID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parent, nil
+}
+
+// CreateTeam は組織にチームを作成します。parentName が空でなければそのチームの子チームにします。
+func (s *OrganizationService) CreateTeam(userId uint, handlename, name, description, parentName string) (*model.Team, error) {
+	var team *model.Team
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, true)
+		if err != nil {
+			return err
+		}
+
+		var parent *model.Team
+		var parentTeamId *uint
+		if parentName != "" {
+			parent, err = getParentTeam(tx, organization, nil, parentName)
+			if err != nil {
+				return err
+			}
+			parentTeamId = &parent.ID
+		}
+
+		registeredTeam, err := tx.CreateTeam(organization.ID, name, description, parentTeamId)
+		if err != nil {
+			if errors.Is(err, ErrDuplicatedKey) {
+				return &ErrRegisteredTeamName{OrganizationAccountId: organization.ID, Name: name}
+			}
+			return err
+		}
+		registeredTeam.ParentTeam = parent
+		team = registeredTeam
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (s *OrganizationService) GetTeams(userId uint, handlename string, offset, limit int) ([]model.Team, error) {
+	var teams []model.Team
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, false)
+		if err != nil {
+			return err
+		}
+
+		teams, err = tx.GetTeamsByOrganizationAccountId(organization.ID, offset, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+func (s *OrganizationService) GetTeam(userId uint, handlename, name string) (*model.Team, error) {
+	var team *model.Team
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, false)
+		if err != nil {
+			return err
+		}
+
+		team, err = getTeam(tx, organization, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+// UpdateTeam はチームの名前と説明、親チームを更新します。nil のフィールドは変更せず、parentName が空文字なら親チームを外します。
+func (s *OrganizationService) UpdateTeam(userId uint, handlename, name string, newName, description, parentName *string) (*model.Team, error) {
+	var team *model.Team
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, true)
+		if err != nil {
+			return err
+		}
+		teamInDB, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		if newName != nil {
+			teamInDB.Name = *newName
+		}
+		if description != nil {
+			teamInDB.Description = *description
+		}
+		if parentName != nil {
+			teamInDB.ParentTeamID = nil
+			teamInDB.ParentTeam = nil
+			if *parentName != "" {
+				parent, err := getParentTeam(tx, organization, teamInDB, *parentName)
+				if err != nil {
+					return err
+				}
+				teamInDB.ParentTeamID = &parent.ID
+				teamInDB.ParentTeam = parent
+			}
+		}
+
+		err = tx.UpdateTeam(teamInDB.ID, teamInDB.Name, teamInDB.Description, teamInDB.ParentTeamID)
+		if err != nil {
+			if errors.Is(err, ErrDuplicatedKey) {
+				return &ErrRegisteredTeamName{OrganizationAccountId: organization.ID, Name: teamInDB.Name}
+			}
+			return err
+		}
+		team = teamInDB
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+// DeleteTeam はチームを削除します。子チームは削除したチームの親チームに付け替えます。
+func (s *OrganizationService) DeleteTeam(userId uint, handlename, name string) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, true)
+		if err != nil {
+			return err
+		}
+		team, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.ReparentChildTeams(team.ID, team.ParentTeamID); err != nil {
+			return err
+		}
+		return tx.DeleteTeam(team.ID)
+	})
+}
+
+func (s *OrganizationService) GetTeamMembers(userId uint, handlename, name string, offset, limit int) ([]TeamMember, error) {
+	var members []TeamMember
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, false)
+		if err != nil {
+			return err
+		}
+		team, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		membersInDB, err := tx.GetTeamMembers(team.ID, offset, limit)
+		if err != nil {
+			return err
+		}
+
+		userIds := make([]uint, 0, len(membersInDB))
+		for _, m := range membersInDB {
+			userIds = append(userIds, m.UserID)
+		}
+		accounts, err := tx.GetPersonalAccountsByUserIds(userIds)
+		if err != nil {
+			return err
+		}
+
+		members = make([]TeamMember, 0, len(membersInDB))
+		for _, m := range membersInDB {
+			members = append(members, TeamMember{
+				UserId:     m.UserID,
+				Handlename: accounts[m.UserID].Handlename.Handlename,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// AddTeamMember は組織のメンバーをチームに追加します。既にメンバーであれば何もしません。
+func (s *OrganizationService) AddTeamMember(userId uint, handlename, name, memberHandlename string) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, true)
+		if err != nil {
+			return err
+		}
+		team, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		user, err := getUserByHandlename(tx, memberHandlename)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return &ErrUserNotFound{}
+		}
+		// チームに入れるのは組織のメンバーだけ
+		role, err := getOrganizationRole(tx, &user.ID, organization)
+		if err != nil {
+			return err
+		}
+		if role == 0 {
+			return &ErrOrganizationMemberNotFound{OrganizationAccountId: organization.ID, Handlename: memberHandlename}
+		}
+
+		member, err := tx.GetTeamMember(team.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if member != nil {
+			return nil
+		}
+		_, err = tx.CreateTeamMember(team.ID, user.ID)
+		return err
+	})
+}
+
+func (s *OrganizationService) RemoveTeamMember(userId uint, handlename, name, memberHandlename string) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, true)
+		if err != nil {
+			return err
+		}
+		team, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		user, err := getUserByHandlename(tx, memberHandlename)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return &ErrTeamMemberNotFound{TeamId: team.ID, Handlename: memberHandlename}
+		}
+		member, err := tx.GetTeamMember(team.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if member == nil {
+			return &ErrTeamMemberNotFound{TeamId: team.ID, Handlename: memberHandlename}
+		}
+
+		return tx.DeleteTeamMember(team.ID, user.ID)
+	})
+}
+
+func (s *OrganizationService) GetTeamRepositories(userId uint, handlename, name string, offset, limit int) ([]model.TeamRepository, error) {
+	var teamRepos []model.TeamRepository
+	err := s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, false)
+		if err != nil {
+			return err
+		}
+		team, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		teamRepos, err = tx.GetTeamRepositories(team.ID, offset, limit)
+		if err != nil {
+			return err
+		}
+		for i := range teamRepos {
+			teamRepos[i].Repository.OwnerAccount = *organization
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return teamRepos, nil
+}
+
+// SetTeamRepository はチームに組織のリポジトリへの役割を与えます。既に与えていれば役割を変更します。
+func (s *OrganizationService) SetTeamRepository(userId uint, handlename, name, repoName string, role model.RepositoryRole) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, true)
+		if err != nil {
+			return err
+		}
+		team, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		repo, err := tx.GetRepositoryByOwnerAndName(organization.ID, repoName)
+		if err != nil {
+			return err
+		}
+		if repo == nil {
+			return &ErrRepositoryNotFound{Owner: handlename, Name: repoName}
+		}
+
+		teamRepo, err := tx.GetTeamRepository(team.ID, repo.ID)
+		if err != nil {
+			return err
+		}
+		if teamRepo == nil {
+			_, err = tx.CreateTeamRepository(team.ID, repo.ID, role)
+			return err
+		}
+		return tx.UpdateTeamRepositoryRole(team.ID, repo.ID, role)
+	})
+}
+
+func (s *OrganizationService) RemoveTeamRepository(userId uint, handlename, name, repoName string) error {
+	return s.store.Transaction(func(tx OrganizationServiceStore) error {
+		organization, err := getTeamOrganization(tx, userId, handlename, true)
+		if err != nil {
+			return err
+		}
+		team, err := getTeam(tx, organization, name)
+		if err != nil {
+			return err
+		}
+
+		repo, err := tx.GetRepositoryByOwnerAndName(organization.ID, repoName)
+		if err != nil {
+			return err
+		}
+		if repo == nil {
+			return &ErrTeamRepositoryNotFound{TeamId: team.ID, Name: repoName}
+		}
+		teamRepo, err := tx.GetTeamRepository(team.ID, repo.ID)
+		if err != nil {
+			return err
+		}
+		if teamRepo == nil {
+			return &ErrTeamRepositoryNotFound{TeamId: team.ID, Name: repoName}
+		}
+
+		return tx.DeleteTeamRepository(team.ID, repo.ID)
+	})
+}
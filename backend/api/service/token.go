@@ -0,0 +1,151 @@
+package service
+
+import (
+	"gityard-api/model"
+	"gityard-api/security"
+	"strings"
+	"time"
+)
+
+// TokenService はパーソナルアクセストークンを扱います。
+type TokenService struct {
+	store TokenServiceStore
+}
+
+// NewTokenService は TokenService を作成します。
+func NewTokenService(store TokenServiceStore) *TokenService {
+	return &TokenService{store: store}
+}
+
+// CreatePersonalAccessToken はパーソナルアクセストークンを発行します。
+// 平文のトークンは発行時に返すだけで、DBにはハッシュを保存します。expiresAt が nil なら無期限です。
+func (s *TokenService) CreatePersonalAccessToken(userId uint, name string, scopes []security.Scope, expiresAt *time.Time) (*model.UserAccessToken, string, error) {
+	token := security.GeneratePersonalAccessToken()
+	scopeNames := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeNames = append(scopeNames, string(scope))
+	}
+
+	var accessToken *model.UserAccessToken
+	err := s.store.Transaction(func(tx TokenServiceStore) error {
+		var err error
+		accessToken, err = tx.CreateUserAccessToken(userId, name, token, strings.Join(scopeNames, " "), expiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return accessToken, token, nil
+}
+
+func (s *TokenService) GetPersonalAccessTokens(userId uint, offset, limit int) ([]model.UserAccessToken, error) {
+	var accessTokens []model.UserAccessToken
+	err := s.store.Transaction(func(tx TokenServiceStore) error {
+		var err error
+		accessTokens, err = tx.GetUserAccessTokensByUserId(userId, offset, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return accessTokens, nil
+}
+
+func (s *TokenService) DeletePersonalAccessToken(userId, tokenId uint) error {
+	return s.store.Transaction(func(tx TokenServiceStore) error {
+		accessToken, err := tx.GetUserAccessTokenById(tokenId)
+		if err != nil {
+			return err
+		}
+		if accessToken == nil || accessToken.UserID != userId {
+			return &ErrAccessTokenNotFound{TokenId: tokenId}
+		}
+
+		return tx.DeleteUserAccessToken(tokenId)
+	})
+}
+
+// AuthenticatePersonalAccessToken はパーソナルアクセストークンを検証して、持ち主のユーザIDと許可されたスコープを返します。
+func (s *TokenService) AuthenticatePersonalAccessToken(token string) (uint, []security.Scope, error) {
+	var userId uint
+	var scopes []security.Scope
+	err := s.store.Transaction(func(tx TokenServiceStore) error {
+		var err error
+		userId, scopes, err = authenticatePersonalAccessToken(tx, token)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return userId, scopes, nil
+}
+
+// AuthenticateAccessToken はログインで発行したアクセストークンを検証して、中のクレームを返します。
+// 署名と期限に加えて、ログアウトで失効させていないか、パスワードの変更などでユーザのトークンの世代が進んでいないか、
+// 発行したセッションがまだ残っているかを確認します。セッションを削除すれば、そのセッションのアクセストークンもすぐに使えなくなります。
+func (s *TokenService) AuthenticateAccessToken(token string) (*security.AccessTokenClaims, error) {
+	return authenticateAccessToken(s.store, token)
+}
+
+func authenticateAccessToken(tx userSessionStore, token string) (*security.AccessTokenClaims, error) {
+	claims, ok := security.VerifyAccessToken(token)
+	if !ok {
+		return nil, &ErrInvalidAccessTokenProvided{}
+	}
+
+	userInDB, err := tx.GetUserById(claims.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if userInDB == nil || userInDB.IsDeleted || claims.Generation != userInDB.TokenGeneration {
+		return nil, &ErrRevokedAccessTokenProvided{UserId: claims.UserId, TokenId: claims.TokenId}
+	}
+
+	// ログアウト、セッションの削除、リフレッシュトークンの再利用の検知で消えたセッション
+	sessionInDB, err := tx.GetUserRefreshTokenById(claims.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	if sessionInDB == nil || sessionInDB.UserID != claims.UserId {
+		return nil, &ErrRevokedAccessTokenProvided{UserId: claims.UserId, TokenId: claims.TokenId}
+	}
+
+	revoked, err := tx.GetRevokedAccessToken(claims.TokenId)
+	if err != nil {
+		return nil, err
+	}
+	if revoked != nil {
+		return nil, &ErrRevokedAccessTokenProvided{UserId: claims.UserId, TokenId: claims.TokenId}
+	}
+
+	return claims, nil
+}
+
+func authenticatePersonalAccessToken(tx AccessTokenStore, token string) (uint, []security.Scope, error) {
+	accessToken, err := tx.GetUserAccessTokenByToken(token)
+	if err != nil {
+		return 0, nil, err
+	}
+	if accessToken == nil {
+		return 0, nil, &ErrInvalidAccessTokenProvided{}
+	}
+	now := time.Now()
+	if accessToken.ExpiresAt != nil && !now.Before(*accessToken.ExpiresAt) {
+		return 0, nil, &ErrExpiredAccessTokenProvided{TokenId: accessToken.ID}
+	}
+
+	scopes, ok := security.ParseScopes(strings.Fields(accessToken.Scopes))
+	if !ok {
+		// 発行時に検証しているので、ここに来るのはスコープを廃止した場合だけ
+		return 0, nil, &ErrInvalidAccessTokenProvided{}
+	}
+
+	if err := tx.UpdateUserAccessTokenLastUsedAt(accessToken.ID, now); err != nil {
+		return 0, nil, err
+	}
+
+	return accessToken.UserID, scopes, nil
+}
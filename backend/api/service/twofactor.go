@@ -0,0 +1,387 @@
+package service
+
+import (
+	"gityard-api/config"
+	"gityard-api/model"
+	"gityard-api/security"
+	"time"
+)
+
+// TwoFactorService は二要素認証の設定とWebAuthnのクレデンシャルの登録を扱います。
+type TwoFactorService struct {
+	store TwoFactorServiceStore
+	cfg   *config.Config
+}
+
+// NewTwoFactorService は TwoFactorService を作成します。
+func NewTwoFactorService(store TwoFactorServiceStore, cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{store: store, cfg: cfg}
+}
+
+const (
+	recoveryCodeCount             = 10
+	maxTwoFactorChallengeAttempts = 5 // これを超えて間違えたらパスワードからやり直させる
+)
+
+// TwoFactorStatus はユーザの二要素認証の状態です。TOTPかWebAuthnのどちらかがあれば有効です。
+type TwoFactorStatus struct {
+	Enabled                bool
+	TOTPEnabled            bool
+	TOTPEnabledAt          *time.Time
+	WebAuthnCredentials    int64
+	RecoveryCodesRemaining int64
+}
+
+// TwoFactorEnrollment は認証アプリに登録するための情報です。
+type TwoFactorEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// 二要素目の手段
+const (
+	TwoFactorMethodTOTP     = "totp"
+	TwoFactorMethodWebAuthn = "webauthn"
+	TwoFactorMethodRecovery = "recovery_code"
+)
+
+// TwoFactorChallenge はパスワードを確認したあと、二要素認証のコードと交換するためのトークンです。
+type TwoFactorChallenge struct {
+	Token     string
+	ExpiresIn time.Duration
+	Methods   []string // ユーザが使える二要素目の手段
+}
+
+// getTwoFactorMethods はユーザが使える二要素目の手段を返します。二要素認証が無効なら空です。
+func getTwoFactorMethods(tx twoFactorMethodStore, userId uint) ([]string, error) {
+	var methods []string
+
+	twoFactor, err := tx.GetUserTwoFactorByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor != nil && twoFactor.IsEnabled {
+		methods = append(methods, TwoFactorMethodTOTP)
+	}
+
+	count, err := tx.CountUserWebAuthnCredentials(userId)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		methods = append(methods, TwoFactorMethodWebAuthn)
+	}
+
+	if len(methods) > 0 {
+		methods = append(methods, TwoFactorMethodRecovery)
+	}
+	return methods, nil
+}
+
+// isTwoFactorEnabled はユーザが二要素認証を有効にしているかを返します。
+func isTwoFactorEnabled(tx twoFactorMethodStore, userId uint) (bool, error) {
+	methods, err := getTwoFactorMethods(tx, userId)
+	if err != nil {
+		return false, err
+	}
+	return len(methods) > 0, nil
+}
+
+// verifyTwoFactorCode はTOTPのコードかリカバリーコードを検証します。TOTPが無効なら twoFactor は nil で構いません。
+// 受け付けたコードは使用済みにするので、同じコードは二度と通りません。
+func verifyTwoFactorCode(tx TwoFactorStore, userId uint, twoFactor *model.UserTwoFactor, code string) (bool, error) {
+	if security.IsTOTPCode(code) {
+		if twoFactor == nil || !twoFactor.IsEnabled {
+			return false, nil
+		}
+		step, ok := security.VerifyTOTP(twoFactor.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return tx.UpdateUserTwoFactorLastUsedStep(userId, step)
+	}
+
+	return tx.UseUserRecoveryCode(userId, security.NormalizeRecoveryCode(code), time.Now())
+}
+
+// deleteRecoveryCodesIfUnused は二要素目の手段が残っていなければリカバリーコードを削除します。
+func deleteRecoveryCodesIfUnused(tx twoFactorMethodStore, userId uint) error {
+	enabled, err := isTwoFactorEnabled(tx, userId)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return nil
+	}
+	return tx.DeleteUserRecoveryCodesByUserId(userId)
+}
+
+// verifyTwoFactorCodeForSettings は二要素認証の設定を変更する前に、TOTPかリカバリーコードで本人であることを確認します。
+// totpRequired ならTOTPが有効でなければなりません。
+func verifyTwoFactorCodeForSettings(tx twoFactorMethodStore, userId uint, code string, totpRequired bool) error {
+	twoFactor, err := tx.GetUserTwoFactorByUserId(userId)
+	if err != nil {
+		return err
+	}
+	totpEnabled := twoFactor != nil && twoFactor.IsEnabled
+	if totpRequired && !totpEnabled {
+		return &ErrTwoFactorNotEnabled{UserId: userId}
+	}
+	if !totpEnabled {
+		enabled, err := isTwoFactorEnabled(tx, userId)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return &ErrTwoFactorNotEnabled{UserId: userId}
+		}
+	}
+
+	ok, err := verifyTwoFactorCode(tx, userId, twoFactor, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &ErrInvalidTwoFactorCode{UserId: userId}
+	}
+
+	return nil
+}
+
+// createRecoveryCodes はリカバリーコードを作り直します。以前のコードは使えなくなります。
+func createRecoveryCodes(tx TwoFactorStore, userId uint) ([]string, error) {
+	if err := tx.DeleteUserRecoveryCodesByUserId(userId); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		codes = append(codes, security.GenerateRecoveryCode())
+	}
+	if err := tx.CreateUserRecoveryCodes(userId, codes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// createTwoFactorChallenge はパスワードを確認したログインのチャレンジを作成します。
+func createTwoFactorChallenge(tx TwoFactorStore, cfg *config.Config, userId uint, methods []string) (*TwoFactorChallenge, error) {
+	expiresIn := cfg.Token.TwoFactorChallengeTTL
+	token := security.GenerateTwoFactorChallengeToken()
+
+	_, err := tx.CreateUserTwoFactorChallenge(userId, token, time.Now().Add(expiresIn))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorChallenge{
+		Token:     token,
+		ExpiresIn: expiresIn,
+		Methods:   methods,
+	}, nil
+}
+
+func (s *TwoFactorService) GetTwoFactorStatus(userId uint) (*TwoFactorStatus, error) {
+	status := new(TwoFactorStatus)
+	err := s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		twoFactor, err := tx.GetUserTwoFactorByUserId(userId)
+		if err != nil {
+			return err
+		}
+		if twoFactor != nil && twoFactor.IsEnabled {
+			status.TOTPEnabled = true
+			status.TOTPEnabledAt = twoFactor.EnabledAt
+		}
+
+		status.WebAuthnCredentials, err = tx.CountUserWebAuthnCredentials(userId)
+		if err != nil {
+			return err
+		}
+		status.Enabled = status.TOTPEnabled || status.WebAuthnCredentials > 0
+		if !status.Enabled {
+			return nil
+		}
+
+		status.RecoveryCodesRemaining, err = tx.CountUnusedUserRecoveryCodes(userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// EnrollTwoFactor は二要素認証の登録を始めます。確認コードで ConfirmTwoFactor するまでは有効になりません。
+// 登録中にもう一度呼ぶと共有鍵を作り直します。
+func (s *TwoFactorService) EnrollTwoFactor(userId uint) (*TwoFactorEnrollment, error) {
+	var enrollment *TwoFactorEnrollment
+	err := s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		twoFactor, err := tx.GetUserTwoFactorByUserId(userId)
+		if err != nil {
+			return err
+		}
+		if twoFactor != nil {
+			if twoFactor.IsEnabled {
+				return &ErrTwoFactorAlreadyEnabled{UserId: userId}
+			}
+			if err := tx.DeleteUserTwoFactor(userId); err != nil {
+				return err
+			}
+		}
+
+		account, err := tx.GetPersonalAccountByUserId(userId)
+		if err != nil {
+			return err
+		}
+		if account == nil {
+			return &ErrAccountNotFound{}
+		}
+
+		secret := security.GenerateTOTPSecret()
+		if _, err := tx.CreateUserTwoFactor(userId, secret); err != nil {
+			return err
+		}
+		enrollment = &TwoFactorEnrollment{
+			Secret:          secret,
+			ProvisioningURI: security.TOTPProvisioningURI(secret, account.Handlename.Handlename),
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+// ConfirmTwoFactor は認証アプリが表示したコードを検証して二要素認証を有効にし、リカバリーコードを返します。
+// リカバリーコードの平文を返すのはこのときだけです。
+func (s *TwoFactorService) ConfirmTwoFactor(userId uint, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		twoFactor, err := tx.GetUserTwoFactorByUserId(userId)
+		if err != nil {
+			return err
+		}
+		if twoFactor == nil {
+			return &ErrTwoFactorEnrollmentNotFound{UserId: userId}
+		}
+		if twoFactor.IsEnabled {
+			return &ErrTwoFactorAlreadyEnabled{UserId: userId}
+		}
+
+		step, ok := security.VerifyTOTP(twoFactor.Secret, code, time.Now())
+		if !ok {
+			return &ErrInvalidTwoFactorCode{UserId: userId}
+		}
+		if err := tx.EnableUserTwoFactor(userId, step, time.Now()); err != nil {
+			return err
+		}
+
+		recoveryCodes, err = createRecoveryCodes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// DisableTwoFactor はTOTPかリカバリーコードを確認してTOTPを無効にします。
+// WebAuthnのクレデンシャルが残っていればリカバリーコードはそのまま使えます。
+func (s *TwoFactorService) DisableTwoFactor(userId uint, code string) error {
+	return s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		if err := verifyTwoFactorCodeForSettings(tx, userId, code, true); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteUserTwoFactor(userId); err != nil {
+			return err
+		}
+		return deleteRecoveryCodesIfUnused(tx, userId)
+	})
+}
+
+// RegenerateRecoveryCodes はTOTPかリカバリーコードを確認してリカバリーコードを作り直します。
+func (s *TwoFactorService) RegenerateRecoveryCodes(userId uint, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		if err := verifyTwoFactorCodeForSettings(tx, userId, code, false); err != nil {
+			return err
+		}
+
+		var err error
+		recoveryCodes, err = createRecoveryCodes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// LoginWithTwoFactor はチャレンジトークンと二要素認証のコードを検証して新しいセッションを作成します。
+// 間違えた回数が上限に達したチャレンジは破棄します。
+func (s *AuthService) LoginWithTwoFactor(challengeToken, code string, client SessionClient) (*Session, error) {
+	var session *Session
+	var rejected error
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		challenge, err := tx.GetUserTwoFactorChallengeByToken(challengeToken)
+		if err != nil {
+			return err
+		}
+		if challenge == nil {
+			return &ErrInvalidTwoFactorChallengeProvided{}
+		}
+		if !time.Now().Before(challenge.ExpiresAt) {
+			// 破棄をコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrInvalidTwoFactorChallengeProvided{}
+			return tx.DeleteUserTwoFactorChallenge(challenge.ID)
+		}
+
+		enabled, err := isTwoFactorEnabled(tx, challenge.UserID)
+		if err != nil {
+			return err
+		}
+		if !enabled { // チャレンジの発行後に無効にされた
+			rejected = &ErrInvalidTwoFactorChallengeProvided{}
+			return tx.DeleteUserTwoFactorChallenge(challenge.ID)
+		}
+		twoFactor, err := tx.GetUserTwoFactorByUserId(challenge.UserID)
+		if err != nil {
+			return err
+		}
+
+		ok, err := verifyTwoFactorCode(tx, challenge.UserID, twoFactor, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			rejected = &ErrInvalidTwoFactorCode{UserId: challenge.UserID}
+			failedAttempts := challenge.FailedAttempts + 1
+			if failedAttempts >= maxTwoFactorChallengeAttempts {
+				return tx.DeleteUserTwoFactorChallenge(challenge.ID)
+			}
+			return tx.UpdateUserTwoFactorChallengeFailedAttempts(challenge.ID, failedAttempts)
+		}
+
+		if err := tx.DeleteUserTwoFactorChallenge(challenge.ID); err != nil {
+			return err
+		}
+		session, err = createSession(tx, challenge.UserID, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+
+	return session, nil
+}
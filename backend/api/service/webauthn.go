@@ -0,0 +1,440 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gityard-api/model"
+	"gityard-api/security"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// WebAuthnCeremonyStart はブラウザに渡すオプションと、完了させるときに提示するトークンです。
+type WebAuthnCeremonyStart struct {
+	Token     string
+	Options   json.RawMessage
+	ExpiresIn time.Duration
+}
+
+// toWebAuthnCredential はDBに保存したクレデンシャルを検証に使う形に変換します。
+func toWebAuthnCredential(credential *model.UserWebAuthnCredential) (webauthn.Credential, error) {
+	credentialId, err := base64.RawURLEncoding.DecodeString(credential.CredentialID)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+
+	var transports []protocol.AuthenticatorTransport
+	for _, transport := range strings.Fields(credential.Transports) {
+		transports = append(transports, protocol.AuthenticatorTransport(transport))
+	}
+
+	return webauthn.Credential{
+		ID:              credentialId,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(credential.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       credential.AAGUID,
+			SignCount:    credential.SignCount,
+			CloneWarning: credential.CloneWarning,
+		},
+	}, nil
+}
+
+// getWebAuthnUser はユーザと登録済みのクレデンシャルを取得します。ユーザがいなければ nil を返します。
+func getWebAuthnUser(tx webAuthnUserStore, userId uint) (*security.WebAuthnUser, []model.UserWebAuthnCredential, error) {
+	account, err := tx.GetPersonalAccountByUserId(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if account == nil {
+		return nil, nil, nil
+	}
+
+	credentials, err := tx.GetUserWebAuthnCredentialsByUserId(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user := &security.WebAuthnUser{
+		ID:   userId,
+		Name: account.Handlename.Handlename,
+	}
+	for i := range credentials {
+		credential, err := toWebAuthnCredential(&credentials[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		user.Credentials = append(user.Credentials, credential)
+	}
+
+	return user, credentials, nil
+}
+
+// findUsedWebAuthnCredential は検証に使われたクレデンシャルを登録済みのものから探します。
+func findUsedWebAuthnCredential(credentials []model.UserWebAuthnCredential, used *webauthn.Credential) *model.UserWebAuthnCredential {
+	credentialId := base64.RawURLEncoding.EncodeToString(used.ID)
+	for i := range credentials {
+		if credentials[i].CredentialID == credentialId {
+			return &credentials[i]
+		}
+	}
+	return nil
+}
+
+// recordWebAuthnCredentialUsage は署名カウンタと最終利用日時を更新します。
+// 署名カウンタが巻き戻っていたらクローンを疑って印を付け、cloned を返すのでログインを拒否してください。
+// 一度印が付いたクレデンシャルは、削除して登録し直すまで使えません。
+func recordWebAuthnCredentialUsage(tx WebAuthnStore, credential *model.UserWebAuthnCredential, used *webauthn.Credential) (bool, error) {
+	if credential.CloneWarning {
+		slog.Warn("security event: flagged webauthn credential used", "userId", credential.UserID, "credentialId", credential.ID)
+		return true, nil
+	}
+	if used.Authenticator.CloneWarning {
+		slog.Warn("security event: webauthn credential may be cloned", "userId", credential.UserID, "credentialId", credential.ID, "signCount", credential.SignCount)
+		return true, tx.UpdateUserWebAuthnCredentialCloneWarning(credential.ID)
+	}
+
+	return false, tx.UpdateUserWebAuthnCredentialUsage(credential.ID, used.Authenticator.SignCount, time.Now())
+}
+
+// getWebAuthnCeremony は有効なセレモニーを取得します。期限切れや種類の違うセレモニーは使えません。
+// 取得したセレモニーは一度きりなので削除します。
+func getWebAuthnCeremony(tx WebAuthnStore, token string, kind model.WebAuthnCeremonyKind) (*model.WebAuthnCeremony, error) {
+	ceremony, err := tx.GetWebAuthnCeremonyByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony == nil || ceremony.Kind != int(kind) {
+		return nil, nil
+	}
+
+	if err := tx.DeleteWebAuthnCeremony(ceremony.ID); err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(ceremony.ExpiresAt) {
+		return nil, nil
+	}
+
+	return ceremony, nil
+}
+
+func (s *TwoFactorService) GetWebAuthnCredentials(userId uint) ([]model.UserWebAuthnCredential, error) {
+	return s.store.GetUserWebAuthnCredentialsByUserId(userId)
+}
+
+// BeginWebAuthnRegistration はセキュリティキーやパスキーの登録を始めます。
+func (s *TwoFactorService) BeginWebAuthnRegistration(userId uint) (*WebAuthnCeremonyStart, error) {
+	var start *WebAuthnCeremonyStart
+	err := s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		user, _, err := getWebAuthnUser(tx, userId)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return &ErrAccountNotFound{}
+		}
+
+		ceremony, err := security.BeginWebAuthnRegistration(user)
+		if err != nil {
+			return err
+		}
+
+		expiresIn := s.cfg.Token.WebAuthnCeremonyTTL
+		token := security.GenerateWebAuthnCeremonyToken()
+		_, err = tx.CreateWebAuthnCeremony(&userId, model.WebAuthnRegistration, token, ceremony.SessionData, time.Now().Add(expiresIn))
+		if err != nil {
+			return err
+		}
+
+		start = &WebAuthnCeremonyStart{
+			Token:     token,
+			Options:   ceremony.Options,
+			ExpiresIn: expiresIn,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return start, nil
+}
+
+// FinishWebAuthnRegistration は認証器のレスポンスを検証してクレデンシャルを登録します。
+// 初めての二要素目の手段であればリカバリーコードを作成して返します。リカバリーコードの平文を返すのはこのときだけです。
+func (s *TwoFactorService) FinishWebAuthnRegistration(userId uint, ceremonyToken, name string, response []byte) (*model.UserWebAuthnCredential, []string, error) {
+	var registered *model.UserWebAuthnCredential
+	var recoveryCodes []string
+	var rejected error
+	err := s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		ceremony, err := getWebAuthnCeremony(tx, ceremonyToken, model.WebAuthnRegistration)
+		if err != nil {
+			return err
+		}
+		if ceremony == nil || ceremony.UserID == nil || *ceremony.UserID != userId {
+			// 使ったセレモニーの削除をコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrInvalidWebAuthnCeremonyProvided{}
+			return nil
+		}
+
+		user, _, err := getWebAuthnUser(tx, userId)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return &ErrAccountNotFound{}
+		}
+
+		credential, err := security.FinishWebAuthnRegistration(user, ceremony.SessionData, response)
+		if err != nil {
+			rejected = &ErrInvalidWebAuthnResponse{Detail: err}
+			return nil
+		}
+
+		twoFactorEnabled, err := isTwoFactorEnabled(tx, userId)
+		if err != nil {
+			return err
+		}
+
+		var transports []string
+		for _, transport := range credential.Transport {
+			transports = append(transports, string(transport))
+		}
+		registered = &model.UserWebAuthnCredential{
+			UserID:          userId,
+			Name:            name,
+			CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transports:      strings.Join(transports, " "),
+			AAGUID:          credential.Authenticator.AAGUID,
+			Flags:           uint8(credential.Flags.ProtocolValue()),
+			SignCount:       credential.Authenticator.SignCount,
+		}
+		if err := tx.CreateUserWebAuthnCredential(registered); err != nil {
+			// 登録済みのクレデンシャルは uq_idx_user_webauthn_credentials_credential_id で弾かれる
+			if errors.Is(err, ErrDuplicatedKey) {
+				rejected = &ErrWebAuthnCredentialAlreadyRegistered{UserId: userId}
+				return nil
+			}
+			return err
+		}
+
+		if twoFactorEnabled {
+			return nil
+		}
+		recoveryCodes, err = createRecoveryCodes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if rejected != nil {
+		return nil, nil, rejected
+	}
+
+	return registered, recoveryCodes, nil
+}
+
+// DeleteWebAuthnCredential はクレデンシャルを削除します。二要素目の手段がなくなればリカバリーコードも削除します。
+func (s *TwoFactorService) DeleteWebAuthnCredential(userId, credentialId uint) error {
+	return s.store.Transaction(func(tx TwoFactorServiceStore) error {
+		credential, err := tx.GetUserWebAuthnCredentialById(credentialId)
+		if err != nil {
+			return err
+		}
+		if credential == nil || credential.UserID != userId {
+			return &ErrWebAuthnCredentialNotFound{CredentialId: credentialId}
+		}
+
+		if err := tx.DeleteUserWebAuthnCredential(credentialId); err != nil {
+			return err
+		}
+		return deleteRecoveryCodesIfUnused(tx, userId)
+	})
+}
+
+// BeginTwoFactorWebAuthnLogin はパスワードを確認したログインのチャレンジに、二要素目としてWebAuthnで応答するためのオプションを返します。
+func (s *AuthService) BeginTwoFactorWebAuthnLogin(challengeToken string) (json.RawMessage, error) {
+	var options json.RawMessage
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		challenge, err := tx.GetUserTwoFactorChallengeByToken(challengeToken)
+		if err != nil {
+			return err
+		}
+		if challenge == nil || !time.Now().Before(challenge.ExpiresAt) {
+			return &ErrInvalidTwoFactorChallengeProvided{}
+		}
+
+		user, _, err := getWebAuthnUser(tx, challenge.UserID)
+		if err != nil {
+			return err
+		}
+		if user == nil || len(user.Credentials) == 0 {
+			return &ErrWebAuthnCredentialNotFound{}
+		}
+
+		ceremony, err := security.BeginWebAuthnLogin(user)
+		if err != nil {
+			return err
+		}
+		options = ceremony.Options
+
+		return tx.UpdateUserTwoFactorChallengeWebAuthnSessionData(challenge.ID, ceremony.SessionData)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// FinishTwoFactorWebAuthnLogin は二要素目としての認証器のレスポンスを検証して新しいセッションを作成します。
+// 失敗はTOTPのコードを間違えたのと同じように数えます。
+func (s *AuthService) FinishTwoFactorWebAuthnLogin(challengeToken string, response []byte, client SessionClient) (*Session, error) {
+	var session *Session
+	var rejected error
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		challenge, err := tx.GetUserTwoFactorChallengeByToken(challengeToken)
+		if err != nil {
+			return err
+		}
+		if challenge == nil || challenge.WebAuthnSessionData == nil {
+			return &ErrInvalidTwoFactorChallengeProvided{}
+		}
+		if !time.Now().Before(challenge.ExpiresAt) {
+			// 破棄をコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrInvalidTwoFactorChallengeProvided{}
+			return tx.DeleteUserTwoFactorChallenge(challenge.ID)
+		}
+
+		user, credentials, err := getWebAuthnUser(tx, challenge.UserID)
+		if err != nil {
+			return err
+		}
+		if user == nil || len(credentials) == 0 { // チャレンジの発行後に削除された
+			rejected = &ErrInvalidTwoFactorChallengeProvided{}
+			return tx.DeleteUserTwoFactorChallenge(challenge.ID)
+		}
+
+		used, err := security.FinishWebAuthnLogin(user, *challenge.WebAuthnSessionData, response)
+		if err != nil {
+			rejected = &ErrInvalidWebAuthnResponse{Detail: err}
+			failedAttempts := challenge.FailedAttempts + 1
+			if failedAttempts >= maxTwoFactorChallengeAttempts {
+				return tx.DeleteUserTwoFactorChallenge(challenge.ID)
+			}
+			return tx.UpdateUserTwoFactorChallengeFailedAttempts(challenge.ID, failedAttempts)
+		}
+
+		credential := findUsedWebAuthnCredential(credentials, used)
+		if credential == nil {
+			return &ErrWebAuthnCredentialNotFound{}
+		}
+		// クローンが疑われる認証器ではチャレンジもやり直させる
+		if err := tx.DeleteUserTwoFactorChallenge(challenge.ID); err != nil {
+			return err
+		}
+		cloned, err := recordWebAuthnCredentialUsage(tx, credential, used)
+		if err != nil {
+			return err
+		}
+		if cloned {
+			// 印を付けたことをコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrWebAuthnCredentialCloned{UserId: credential.UserID, CredentialId: credential.ID}
+			return nil
+		}
+
+		session, err = createSession(tx, challenge.UserID, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+
+	return session, nil
+}
+
+// BeginPasswordlessLogin はパスキーによるパスワードレスのログインを始めます。ユーザは認証器のレスポンスから特定します。
+func (s *AuthService) BeginPasswordlessLogin() (*WebAuthnCeremonyStart, error) {
+	ceremony, err := security.BeginWebAuthnPasswordlessLogin()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresIn := s.cfg.Token.WebAuthnCeremonyTTL
+	token := security.GenerateWebAuthnCeremonyToken()
+	_, err = s.store.CreateWebAuthnCeremony(nil, model.WebAuthnPasswordlessLogin, token, ceremony.SessionData, time.Now().Add(expiresIn))
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCeremonyStart{
+		Token:     token,
+		Options:   ceremony.Options,
+		ExpiresIn: expiresIn,
+	}, nil
+}
+
+// FinishPasswordlessLogin はパスキーのレスポンスを検証して新しいセッションを作成します。
+// 認証器でユーザ検証をしているので、二要素認証を有効にしていてもこれだけでログインできます。
+func (s *AuthService) FinishPasswordlessLogin(ceremonyToken string, response []byte, client SessionClient) (*Session, error) {
+	var session *Session
+	var rejected error
+	err := s.store.Transaction(func(tx AuthServiceStore) error {
+		ceremony, err := getWebAuthnCeremony(tx, ceremonyToken, model.WebAuthnPasswordlessLogin)
+		if err != nil {
+			return err
+		}
+		if ceremony == nil {
+			// 使ったセレモニーの削除をコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrInvalidWebAuthnCeremonyProvided{}
+			return nil
+		}
+
+		var credentials []model.UserWebAuthnCredential
+		user, used, err := security.FinishWebAuthnPasswordlessLogin(func(userId uint) (*security.WebAuthnUser, error) {
+			user, found, err := getWebAuthnUser(tx, userId)
+			credentials = found
+			return user, err
+		}, ceremony.SessionData, response)
+		if err != nil {
+			rejected = &ErrInvalidWebAuthnResponse{Detail: err}
+			return nil
+		}
+
+		credential := findUsedWebAuthnCredential(credentials, used)
+		if credential == nil {
+			return &ErrWebAuthnCredentialNotFound{}
+		}
+		cloned, err := recordWebAuthnCredentialUsage(tx, credential, used)
+		if err != nil {
+			return err
+		}
+		if cloned {
+			// 印を付けたことをコミットするため、エラーはトランザクションの外で返す
+			rejected = &ErrWebAuthnCredentialCloned{UserId: credential.UserID, CredentialId: credential.ID}
+			return nil
+		}
+
+		session, err = createSession(tx, user.ID, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+
+	return session, nil
+}
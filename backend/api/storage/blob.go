@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBlobNotFound は指定したキーのブロブが存在しないことを表します。
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore はアバター画像のような小さなファイルの保存先です。
+// キーは "/" 区切りの相対パスで、保存先の実装はローカルのファイルシステムに限りません。
+type BlobStore interface {
+	// Put は key に r の内容を保存します。既にあれば置き換えます。
+	Put(key string, r io.Reader) error
+	// Open は key の内容を読み出します。存在しなければ ErrBlobNotFound を返します。
+	Open(key string) (io.ReadCloser, error)
+	// Delete は key を削除します。存在しなくてもエラーにはしません。
+	Delete(key string) error
+}
+
+// NewBlobStore は kind に対応するブロブストアを作成します。今は "local" だけに対応しています。
+func NewBlobStore(kind, root string) (BlobStore, error) {
+	switch kind {
+	case "local":
+		return NewLocalBlobStore(root)
+	default:
+		return nil, fmt.Errorf("unknown blob storage: %s", kind)
+	}
+}
+
+// LocalBlobStore はブロブを root 以下のファイルとして保存します。
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if root == "" {
+		return nil, errors.New("blob storage root is empty")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalBlobStore{root: abs}, nil
+}
+
+// path はキーに対応するファイルの絶対パスを返します。root の外を指すキーは受け付けません。
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || elem == "." || elem == ".." || strings.HasPrefix(elem, ".") {
+			return "", fmt.Errorf("invalid blob key: %q", key)
+		}
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalBlobStore) Put(key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+
+	// 書きかけのファイルを読まれないよう、一時ファイルに書いてから置き換える
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalBlobStore) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, ErrBlobNotFound
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *LocalBlobStore) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package storage_test
+
+import (
+	"gityard-api/storage"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	s, err := storage.NewLocalBlobStore(t.TempDir())
+	assert.Nil(t, err)
+
+	t.Run("put, open and delete", func(t *testing.T) {
+		assert.Nil(t, s.Put("avatars/1/a.png", strings.NewReader("first")))
+		assert.Nil(t, s.Put("avatars/1/a.png", strings.NewReader("second")))
+
+		r, err := s.Open("avatars/1/a.png")
+		assert.Nil(t, err)
+		data, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Nil(t, r.Close())
+		assert.Equal(t, "second", string(data))
+
+		assert.Nil(t, s.Delete("avatars/1/a.png"))
+		_, err = s.Open("avatars/1/a.png")
+		assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+		// 存在しないキーの削除はエラーにしない
+		assert.Nil(t, s.Delete("avatars/1/a.png"))
+	})
+
+	t.Run("reject keys outside root", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "../a.png", "avatars/../../a.png", "avatars//a.png", "avatars/.tmp-1"} {
+			assert.NotNil(t, s.Put(key, strings.NewReader("x")), key)
+			_, err := s.Open(key)
+			assert.ErrorIs(t, err, storage.ErrBlobNotFound, key)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ディスク上のベアリポジトリはリポジトリIDをキーに配置する。
+// オーナーのハンドルネームやリポジトリ名はパスに含めないので、
+// リネームや所有者の移動はDBの更新だけで済み、ディスクには触れない。
+//
+//	<root>/<id%256を2桁の16進数>/<id>.git
+//	<root>/.trash/<id>-<unixnano>.git  (削除処理中)
+const trashDirName = ".trash"
+
+type RepositoryStorage struct {
+	root string
+}
+
+func NewRepositoryStorage(root string) (*RepositoryStorage, error) {
+	if root == "" {
+		return nil, errors.New("repository storage root is empty")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(abs, trashDirName), 0o750); err != nil {
+		return nil, err
+	}
+	return &RepositoryStorage{root: abs}, nil
+}
+
+// Path はリポジトリIDに対応するベアリポジトリの絶対パスを返します。
+func (s *RepositoryStorage) Path(repoId uint) string {
+	return filepath.Join(s.root, fmt.Sprintf("%02x", repoId%256), strconv.FormatUint(uint64(repoId), 10)+".git")
+}
+
+func (s *RepositoryStorage) Exists(repoId uint) bool {
+	info, err := os.Stat(s.Path(repoId))
+	return err == nil && info.IsDir()
+}
+
+// Create は空のベアリポジトリを作成します。既に存在する場合はエラーになります。
+func (s *RepositoryStorage) Create(repoId uint) error {
+	path := s.Path(repoId)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("repository already exists on disk: %s", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", "init", "--bare", "--quiet", "--initial-branch=main", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.RemoveAll(path)
+		return fmt.Errorf("git init failed: %w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// Remove はベアリポジトリを即座に削除します。Create を取り消すときに使います。
+func (s *RepositoryStorage) Remove(repoId uint) error {
+	return os.RemoveAll(s.Path(repoId))
+}
+
+// Trash はベアリポジトリをゴミ箱へ移動します。
+// DBの削除がコミットされたら Purge、ロールバックされたら Restore を呼んでください。
+func (s *RepositoryStorage) Trash(repoId uint) (*TrashedRepository, error) {
+	path := s.Path(repoId)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		// ディスクにないリポジトリはDBの行だけ消せればよい
+		return &TrashedRepository{}, nil
+	}
+
+	trashPath := filepath.Join(s.root, trashDirName, fmt.Sprintf("%d-%d.git", repoId, time.Now().UnixNano()))
+	if err := os.Rename(path, trashPath); err != nil {
+		return nil, err
+	}
+	return &TrashedRepository{originalPath: path, trashPath: trashPath}, nil
+}
+
+// TrashedRepository はゴミ箱へ移動されたリポジトリを表します。
+type TrashedRepository struct {
+	originalPath string
+	trashPath    string
+}
+
+// Restore はゴミ箱から元の場所へ戻します。
+func (t *TrashedRepository) Restore() error {
+	if t.trashPath == "" {
+		return nil
+	}
+	return os.Rename(t.trashPath, t.originalPath)
+}
+
+// Purge はゴミ箱のリポジトリを完全に削除します。
+func (t *TrashedRepository) Purge() error {
+	if t.trashPath == "" {
+		return nil
+	}
+	return os.RemoveAll(t.trashPath)
+}
@@ -0,0 +1,52 @@
+package storage_test
+
+import (
+	"gityard-api/storage"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryStorage(t *testing.T) {
+	s, err := storage.NewRepositoryStorage(t.TempDir())
+	assert.Nil(t, err)
+
+	t.Run("create bare repository keyed by id", func(t *testing.T) {
+		assert.Nil(t, s.Create(258))
+		assert.True(t, s.Exists(258))
+		assert.Equal(t, filepath.Join("02", "258.git"), filepath.Join(filepath.Base(filepath.Dir(s.Path(258))), filepath.Base(s.Path(258))))
+
+		_, err := os.Stat(filepath.Join(s.Path(258), "HEAD"))
+		assert.Nil(t, err)
+
+		// 同じIDで二重に作成はできない
+		assert.NotNil(t, s.Create(258))
+	})
+
+	t.Run("trash and restore", func(t *testing.T) {
+		assert.Nil(t, s.Create(1))
+		trashed, err := s.Trash(1)
+		assert.Nil(t, err)
+		assert.False(t, s.Exists(1))
+
+		assert.Nil(t, trashed.Restore())
+		assert.True(t, s.Exists(1))
+	})
+
+	t.Run("trash and purge", func(t *testing.T) {
+		assert.Nil(t, s.Create(2))
+		trashed, err := s.Trash(2)
+		assert.Nil(t, err)
+		assert.Nil(t, trashed.Purge())
+		assert.False(t, s.Exists(2))
+	})
+
+	t.Run("trash missing repository", func(t *testing.T) {
+		trashed, err := s.Trash(3)
+		assert.Nil(t, err)
+		assert.Nil(t, trashed.Restore())
+		assert.Nil(t, trashed.Purge())
+	})
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// APIAuthorizer はgityard-apiの内部APIに認証・認可の判断を問い合わせます。
+// アクセス判定をAPIサーバに一本化するため、SSHサーバはデータベースを直接参照しない。
+type APIAuthorizer struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewAPIAuthorizer(baseURL, token string) *APIAuthorizer {
+	return &APIAuthorizer{
+		baseURL: baseURL,
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *APIAuthorizer) LookupUserByFingerprint(fingerprint string) (*User, error) {
+	var res struct {
+		UserId     uint   `json:"user_id"`
+		Handlename string `json:"handlename"`
+	}
+	found, err := a.get("/api/v1/internal/keys?fingerprint="+url.QueryEscape(fingerprint), &res)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &User{ID: res.UserId, Handlename: res.Handlename}, nil
+}
+
+func (a *APIAuthorizer) CheckRepositoryAccess(userId uint, owner, name string) (*RepositoryAccess, error) {
+	var res struct {
+		RepositoryId      uint   `json:"repository_id"`
+		Owner             string `json:"owner"`
+		Name              string `json:"name"`
+		CanRead           bool   `json:"can_read"`
+		CanWrite          bool   `json:"can_write"`
+		TwoFactorRequired bool   `json:"two_factor_required"`
+	}
+	path := fmt.Sprintf(
+		"/api/v1/internal/repos/%s/%s/access?user_id=%s",
+		url.PathEscape(owner),
+		url.PathEscape(name),
+		strconv.FormatUint(uint64(userId), 10),
+	)
+	found, err := a.get(path, &res)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &RepositoryAccess{
+		RepositoryID:      res.RepositoryId,
+		Owner:             res.Owner,
+		Name:              res.Name,
+		CanRead:           res.CanRead,
+		CanWrite:          res.CanWrite,
+		TwoFactorRequired: res.TwoFactorRequired,
+	}, nil
+}
+
+// get は内部APIを呼び出してレスポンスを v に読み込みます。404 の場合は found=false を返します。
+func (a *APIAuthorizer) get(path string, v any) (found bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, a.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, json.NewDecoder(res.Body).Decode(v)
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("internal api returned unexpected status: %s %d", path, res.StatusCode)
+	}
+}
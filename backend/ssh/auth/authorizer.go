@@ -0,0 +1,30 @@
+package auth
+
+// User は公開鍵から特定されたユーザを表します。
+type User struct {
+	ID         uint
+	Handlename string
+}
+
+// RepositoryAccess はユーザがリポジトリに対して持つ権限を表します。
+type RepositoryAccess struct {
+	RepositoryID uint
+	// Owner と Name は今の所有者とリポジトリ名です。改名前のハンドルネームでアクセスすると問い合わせた owner とは異なります。
+	Owner    string
+	Name     string
+	CanRead  bool
+	CanWrite bool
+	// TwoFactorRequired は書き込み権限があるのに二要素認証を有効にしていないためpushできないことを表します。
+	TwoFactorRequired bool
+}
+
+// Authorizer はSSHサーバが認証・認可の判断を問い合わせる先です。
+type Authorizer interface {
+	// LookupUserByFingerprint は ssh.FingerprintSHA256 の値から鍵の持ち主を返します。
+	// 登録されていない鍵の場合は nil を返します。
+	LookupUserByFingerprint(fingerprint string) (*User, error)
+
+	// CheckRepositoryAccess は owner/name のリポジトリに対するユーザの権限を返します。
+	// リポジトリが存在しない場合は nil を返します。
+	CheckRepositoryAccess(userId uint, owner, name string) (*RepositoryAccess, error)
+}
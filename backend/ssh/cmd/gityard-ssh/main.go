@@ -1,5 +1,27 @@
 package main
 
+import (
+	"gityard-ssh/auth"
+	"gityard-ssh/config"
+	"gityard-ssh/server"
+	"log"
+)
+
 func main() {
+	hostKey, err := server.LoadOrGenerateHostKey(config.ConfigOrDefault("SSH_HOST_KEY_PATH", "./data/ssh_host_ed25519_key"))
+	if err != nil {
+		log.Fatal("failed to load host key: ", err)
+	}
+
+	apiToken := config.Config("INTERNAL_API_TOKEN")
+	if apiToken == "" {
+		log.Fatal("INTERNAL_API_TOKEN is not set")
+	}
 
+	s := server.NewServer(
+		hostKey,
+		auth.NewAPIAuthorizer(config.ConfigOrDefault("GITYARD_API_URL", "http://localhost:8000"), apiToken),
+		config.ConfigOrDefault("GIT_STORAGE_ROOT", "./data/repositories"),
+	)
+	log.Fatal(s.ListenAndServe(config.ConfigOrDefault("SSH_LISTEN_ADDR", ":2222")))
 }
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// Config func to get env value
+func Config(key string) string {
+	// load .env file
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug("failed to load .env, load os env directly")
+	}
+	return os.Getenv(key)
+}
+
+// ConfigOrDefault は環境変数が空のときに既定値を返します。
+func ConfigOrDefault(key, defaultValue string) string {
+	if v := Config(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	ServiceUploadPack  = "git-upload-pack"
+	ServiceReceivePack = "git-receive-pack"
+)
+
+var ErrUnsupportedCommand = errors.New("unsupported command")
+
+// handlename とリポジトリ名に使える文字。APIサーバのバリデーションと揃えている
+var pathElementPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// GitCommand はクライアントが exec で要求した git のコマンドを表します。
+type GitCommand struct {
+	Service string
+	Owner   string
+	Name    string
+}
+
+// IsWrite はリポジトリへの書き込みを伴うコマンドかを返します。
+func (c *GitCommand) IsWrite() bool {
+	return c.Service == ServiceReceivePack
+}
+
+// ParseGitCommand は "git-upload-pack 'owner/repo.git'" のようなコマンドを解釈します。
+func ParseGitCommand(cmdline string) (*GitCommand, error) {
+	service, arg, ok := strings.Cut(strings.TrimSpace(cmdline), " ")
+	if !ok {
+		return nil, ErrUnsupportedCommand
+	}
+	// "git upload-pack" 形式も受け付ける
+	if service == "git" {
+		service, arg, ok = strings.Cut(strings.TrimSpace(arg), " ")
+		if !ok {
+			return nil, ErrUnsupportedCommand
+		}
+		service = "git-" + service
+	}
+	if service != ServiceUploadPack && service != ServiceReceivePack {
+		return nil, ErrUnsupportedCommand
+	}
+
+	path, err := unquote(strings.TrimSpace(arg))
+	if err != nil {
+		return nil, err
+	}
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimSuffix(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+
+	owner, name, ok := strings.Cut(path, "/")
+	if !ok || !isValidPathElement(owner) || !isValidPathElement(name) {
+		return nil, ErrUnsupportedCommand
+	}
+
+	return &GitCommand{Service: service, Owner: owner, Name: name}, nil
+}
+
+func isValidPathElement(s string) bool {
+	return pathElementPattern.MatchString(s) && s != "." && s != ".."
+}
+
+// unquote は git が付けるシングルクォート ('\” によるエスケープを含む) を外します。
+func unquote(s string) (string, error) {
+	if !strings.HasPrefix(s, "'") {
+		if strings.ContainsAny(s, " '\"\\") {
+			return "", ErrUnsupportedCommand
+		}
+		return s, nil
+	}
+	if len(s) < 2 || !strings.HasSuffix(s, "'") {
+		return "", ErrUnsupportedCommand
+	}
+
+	inner := s[1 : len(s)-1]
+	unescaped := strings.ReplaceAll(inner, `'\''`, `'`)
+	if strings.Count(unescaped, "'") != strings.Count(inner, `'\''`) {
+		return "", ErrUnsupportedCommand
+	}
+	return unescaped, nil
+}
@@ -0,0 +1,54 @@
+package server_test
+
+import (
+	"gityard-ssh/server"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGitCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmdline  string
+		expected *server.GitCommand
+	}{
+		{
+			"upload-pack with quoted path",
+			"git-upload-pack 'alice/hello.git'",
+			&server.GitCommand{Service: server.ServiceUploadPack, Owner: "alice", Name: "hello"},
+		},
+		{
+			"receive-pack with leading slash",
+			"git-receive-pack '/alice/hello.git'",
+			&server.GitCommand{Service: server.ServiceReceivePack, Owner: "alice", Name: "hello"},
+		},
+		{
+			"space separated git subcommand",
+			"git upload-pack 'alice/hello-world.v2'",
+			&server.GitCommand{Service: server.ServiceUploadPack, Owner: "alice", Name: "hello-world.v2"},
+		},
+		{
+			"unquoted path",
+			"git-upload-pack alice/hello.git",
+			&server.GitCommand{Service: server.ServiceUploadPack, Owner: "alice", Name: "hello"},
+		},
+		{"shell command", "ls -la", nil},
+		{"missing path", "git-upload-pack", nil},
+		{"path traversal", "git-upload-pack '../../etc/passwd'", nil},
+		{"too deep path", "git-upload-pack 'alice/hello/world.git'", nil},
+		{"injected quote", `git-upload-pack 'alice/hello'; rm -rf /'`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := server.ParseGitCommand(tt.cmdline)
+			if tt.expected == nil {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, cmd)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// repositoryPath はAPIサーバの storage.RepositoryStorage と同じ配置でベアリポジトリのパスを返します。
+//
+//	<root>/<id%256を2桁の16進数>/<id>.git
+func repositoryPath(root string, repoId uint) string {
+	return filepath.Join(root, fmt.Sprintf("%02x", repoId%256), strconv.FormatUint(uint64(repoId), 10)+".git")
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"gityard-ssh/auth"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// クローンURL git@host:owner/repo.git のユーザ名
+const loginUser = "git"
+
+const (
+	extensionUserId      = "user-id"
+	extensionHandlename  = "handlename"
+	extensionFingerprint = "fingerprint"
+)
+
+type Server struct {
+	config         *ssh.ServerConfig
+	authorizer     auth.Authorizer
+	repositoryRoot string
+}
+
+func NewServer(hostKey ssh.Signer, authorizer auth.Authorizer, repositoryRoot string) *Server {
+	s := &Server{
+		authorizer:     authorizer,
+		repositoryRoot: repositoryRoot,
+	}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: s.authenticate,
+		ServerVersion:     "SSH-2.0-gityard",
+	}
+	s.config.AddHostKey(hostKey)
+	return s
+}
+
+// authenticate は公開鍵のフィンガープリントを user_publickeys から探して利用者を特定します。
+func (s *Server) authenticate(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	if conn.User() != loginUser {
+		return nil, fmt.Errorf("unknown login user: %s", conn.User())
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+	user, err := s.authorizer.LookupUserByFingerprint(fingerprint)
+	if err != nil {
+		slog.Error("failed to lookup pubkey", "detail", err)
+		return nil, err
+	}
+	if user == nil {
+		slog.Info("ssh auth rejected", "reason", "unknown pubkey", "fingerprint", fingerprint, "remote", conn.RemoteAddr().String())
+		return nil, errors.New("unknown public key")
+	}
+
+	return &ssh.Permissions{
+		Extensions: map[string]string{
+			extensionUserId:      strconv.FormatUint(uint64(user.ID), 10),
+			extensionHandlename:  user.Handlename,
+			extensionFingerprint: fingerprint,
+		},
+	}, nil
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("ssh server listening", "addr", listener.Addr().String())
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		slog.Debug("ssh handshake failed", "remote", conn.RemoteAddr().String(), "detail", err)
+		return
+	}
+	defer sshConn.Close()
+
+	userId64, err := strconv.ParseUint(sshConn.Permissions.Extensions[extensionUserId], 10, 64)
+	if err != nil {
+		slog.Error("invalid user id in permissions", "detail", err)
+		return
+	}
+	user := &auth.User{
+		ID:         uint(userId64),
+		Handlename: sshConn.Permissions.Extensions[extensionHandlename],
+	}
+	slog.Info("ssh user authenticated", "userId", user.ID, "fingerprint", sshConn.Permissions.Extensions[extensionFingerprint])
+
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			slog.Error("failed to accept channel", "detail", err)
+			continue
+		}
+		go s.handleSession(user, channel, requests)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"gityard-ssh/auth"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func (s *Server) handleSession(user *auth.User, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	var env []string
+	for req := range requests {
+		switch req.Type {
+		case "env":
+			// プロトコルv2を使うために GIT_PROTOCOL だけは受け取る
+			var kv struct{ Name, Value string }
+			if err := ssh.Unmarshal(req.Payload, &kv); err != nil || kv.Name != "GIT_PROTOCOL" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			env = append(env, "GIT_PROTOCOL="+kv.Value)
+			_ = req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			sendExitStatus(channel, s.runGitCommand(user, payload.Command, env, channel))
+			return
+		case "shell":
+			_ = req.Reply(true, nil)
+			fmt.Fprintf(channel.Stderr(), "Hi %s! You've successfully authenticated, but gityard does not provide shell access.\n", user.Handlename)
+			sendExitStatus(channel, 1)
+			return
+		default:
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// runGitCommand はアクセス権を確認してから git のプロセスを起動し、終了コードを返します。
+func (s *Server) runGitCommand(user *auth.User, cmdline string, env []string, channel ssh.Channel) uint32 {
+	stderr := channel.Stderr()
+
+	cmd, err := ParseGitCommand(cmdline)
+	if err != nil {
+		slog.Info("ssh exec rejected", "reason", "unsupported command", "userId", user.ID, "command", cmdline)
+		fmt.Fprintln(stderr, "ERROR: Unsupported command.")
+		return 1
+	}
+
+	access, err := s.authorizer.CheckRepositoryAccess(user.ID, cmd.Owner, cmd.Name)
+	if err != nil {
+		slog.Error("failed to check repository access", "detail", err)
+		fmt.Fprintln(stderr, "ERROR: Internal error.")
+		return 1
+	}
+	// 読めないリポジトリは存在を明かさない
+	if access == nil || !access.CanRead {
+		slog.Info("ssh exec rejected", "reason", "repository not found", "userId", user.ID, "owner", cmd.Owner, "name", cmd.Name)
+		fmt.Fprintln(stderr, "ERROR: Repository not found.")
+		return 1
+	}
+	if cmd.IsWrite() && access.TwoFactorRequired {
+		slog.Warn("ssh exec rejected", "reason", "two-factor authentication required", "userId", user.ID, "repositoryId", access.RepositoryID)
+		fmt.Fprintln(stderr, "ERROR: Two-factor authentication is required to push. Enable it in your account settings.")
+		return 1
+	}
+	if cmd.IsWrite() && !access.CanWrite {
+		slog.Warn("ssh exec rejected", "reason", "permission denied", "userId", user.ID, "repositoryId", access.RepositoryID)
+		fmt.Fprintf(stderr, "ERROR: Permission to %s/%s denied to %s.\n", cmd.Owner, cmd.Name, user.Handlename)
+		return 1
+	}
+
+	// 改名前のハンドルネームでもアクセスできるが、転送には期限があるのでリモートの更新を促す
+	if access.Owner != "" && access.Owner != cmd.Owner {
+		fmt.Fprintf(stderr, "WARNING: This repository has moved. Please update your remote to %s/%s.git.\n", access.Owner, access.Name)
+	}
+
+	path := repositoryPath(s.repositoryRoot, access.RepositoryID)
+	if _, err := os.Stat(path); err != nil {
+		slog.Error("repository not found on disk", "repositoryId", access.RepositoryID, "path", path, "detail", err)
+		fmt.Fprintln(stderr, "ERROR: Repository not found.")
+		return 1
+	}
+
+	git := exec.Command("git", strings.TrimPrefix(cmd.Service, "git-"), path)
+	git.Env = append(os.Environ(), env...)
+	git.Stdout = channel
+	git.Stderr = stderr
+
+	// channel は Close されるまで EOF にならないので、Wait がブロックしないよう自前でコピーする
+	stdin, err := git.StdinPipe()
+	if err != nil {
+		slog.Error("failed to open stdin pipe", "detail", err)
+		return 1
+	}
+	if err := git.Start(); err != nil {
+		slog.Error("failed to start git", "detail", err)
+		fmt.Fprintln(stderr, "ERROR: Internal error.")
+		return 1
+	}
+	go func() {
+		defer stdin.Close()
+		_, _ = io.Copy(stdin, channel)
+	}()
+
+	slog.Info("ssh git command started", "userId", user.ID, "service", cmd.Service, "repositoryId", access.RepositoryID)
+	if err := git.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return uint32(exitErr.ExitCode())
+		}
+		slog.Error("git command failed", "detail", err)
+		return 1
+	}
+	return 0
+}
+
+func sendExitStatus(channel ssh.Channel, code uint32) {
+	status := struct{ Status uint32 }{code}
+	_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(&status))
+}